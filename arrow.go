@@ -16,6 +16,29 @@ type Arrow struct {
 	Color      color.Color
 }
 
+// Connect wires an output port to an input port. A new wire replaces the one
+// already going into a port that takes a single wire; ports taking many wires
+// keep them all.
+func (g *Game) Connect(from *Card, fromPort string, to *Card, toPort string) *Arrow {
+	multi := to.inputPort(toPort).Multi
+	kept := g.arrows[:0]
+	for _, a := range g.arrows {
+		into := a.ToCardID == to.ID && a.ToPort == toPort
+		same := into && a.FromCardID == from.ID && a.FromPort == fromPort
+		if into && (!multi || same) {
+			g.UnregisterSubscription(a.FromCardID, a.ToCardID, a.ToPort)
+			continue
+		}
+		kept = append(kept, a)
+	}
+	g.arrows = kept
+
+	arrow := &Arrow{FromCardID: from.ID, FromPort: fromPort, ToCardID: to.ID, ToPort: toPort, Color: ColorArrowDefault}
+	g.arrows = append(g.arrows, arrow)
+	g.RegisterSubscription(from.ID, to.ID, toPort)
+	return arrow
+}
+
 func (a *Arrow) Draw(screen *ebiten.Image, g *Game, cw, ch float64) {
 	fromCard := g.getCardByID(a.FromCardID)
 	toCard := g.getCardByID(a.ToCardID)
@@ -60,6 +83,11 @@ func (a *Arrow) Draw(screen *ebiten.Image, g *Game, cw, ch float64) {
 		thickness = 1
 	}
 
+	clr := a.Color
+	if g.engine != nil && g.engine.arrowInactive(a) {
+		clr = ColorArrowInactive
+	}
+
 	for i := 1; i <= segments; i++ {
 		t := float64(i) / float64(segments)
 
@@ -75,7 +103,7 @@ func (a *Arrow) Draw(screen *ebiten.Image, g *Game, cw, ch float64) {
 
 		curX, curY := float32(px), float32(py)
 
-		vector.StrokeLine(screen, prevX, prevY, curX, curY, thickness, a.Color, true)
+		vector.StrokeLine(screen, prevX, prevY, curX, curY, thickness, clr, true)
 		prevX, prevY = curX, curY
 	}
 }
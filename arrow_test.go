@@ -105,3 +105,43 @@ func TestWiringOverwrite(t *testing.T) {
 		t.Errorf("Expected arrow source to be c3, got %s", g.arrows[0].FromCardID)
 	}
 }
+
+func TestConnectSingleAndMultiPorts(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	right := g.AddTextCard(500, 100)
+	right.Text = "right"
+	left := g.AddTextCard(100, 100)
+	left.Text = "left"
+	single := g.AddFormulaCard(300, 400)
+	single.Text = "=input"
+	merge := g.AddMergeCard(300, 600)
+
+	// A port taking one wire keeps only the newest
+	g.Connect(left, "text", single, "input")
+	g.Connect(right, "text", single, "input")
+	if len(g.arrows) != 1 || g.arrows[0].FromCardID != right.ID {
+		t.Fatalf("Expected the new wire to replace the old one, got %d arrows", len(g.arrows))
+	}
+
+	// A port taking many wires keeps them all, once each, listed left to right
+	g.arrows = nil
+	collect := g.AddFormulaCard(300, 800)
+	collect.Inputs[0].Multi = true
+	collect.Text = "=input"
+	g.Connect(right, "text", collect, "input")
+	g.Connect(left, "text", collect, "input")
+	g.Connect(right, "text", collect, "input")
+	if len(g.arrows) != 2 {
+		t.Fatalf("Expected two wires into the multi port, got %d", len(g.arrows))
+	}
+	g.engine.Run()
+	got, _ := g.engine.Memory[collect.ID+":result"].([]interface{})
+	if len(got) != 2 || got[0] != "left" || got[1] != "right" {
+		t.Errorf("Expected [left right], got %v", g.engine.Memory[collect.ID+":result"])
+	}
+	if !merge.Inputs[0].Multi {
+		t.Errorf("Expected the merge card to take many wires")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"card-flows/engine"
+)
+
+// AddIfCard adds a card routing its value to "then" when the condition holds and
+// to "else" otherwise. Following the canvas layout, the happy path continues down
+// from the bottom edge and the else branch leaves sideways from the right edge.
+func (g *Game) AddIfCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "if",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth,
+		Height: DefaultCardHeight,
+		Color:  ColorCardDefault,
+		Title:  "Logic:if",
+		Inputs: []Port{
+			{Name: "condition", Type: "any"},
+			{Name: "value", Type: "any"},
+		},
+		Outputs: []Port{
+			{Name: "then", Type: "any"},
+			{Name: "else", Type: "any", Lateral: true},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// AddMergeCard adds a card joining branches back together: it outputs the value
+// of whichever branch wired into it ran
+func (g *Game) AddMergeCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "merge",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth,
+		Height: DefaultCardHeight,
+		Color:  ColorCardDefault,
+		Title:  "Logic:merge",
+		Inputs: []Port{
+			{Name: "values", Type: "any", Multi: true},
+		},
+		Outputs: []Port{
+			{Name: "value", Type: "any"},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// IsBranch reports whether the card runs only some of its outputs
+func (c *Card) IsBranch() bool {
+	return c.Type == "if"
+}
+
+// truthy reports whether a condition value holds. Besides the Starlark rules,
+// text typed into a card as "false", "no" or "0" is false.
+func truthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case int:
+		return val != 0
+	case int64:
+		return val != 0
+	case float64:
+		return val != 0
+	case string:
+		switch strings.ToLower(strings.TrimSpace(val)) {
+		case "", "false", "no", "0":
+			return false
+		}
+		return true
+	case []interface{}:
+		return len(val) > 0
+	case []string:
+		return len(val) > 0
+	case map[string]interface{}:
+		return len(val) > 0
+	case *engine.Table:
+		return val.NumRows() > 0
+	}
+	return true
+}
+
+// executeIf outputs the value on the branch chosen by the condition only; the other
+// branch is left without a value so the cards after it are skipped
+func executeIf(inputs map[string]interface{}) map[string]interface{} {
+	cond := inputs["condition"]
+	value, ok := inputs["value"]
+	if !ok {
+		value = cond
+	}
+	if truthy(cond) {
+		return map[string]interface{}{"then": value}
+	}
+	return map[string]interface{}{"else": value}
+}
+
+// executeMerge outputs the first value that arrived, in port order; branches not
+// taken send none
+func executeMerge(c *Card, inputs map[string]interface{}) (map[string]interface{}, error) {
+	for _, p := range c.Inputs {
+		v, ok := inputs[p.Name]
+		if !ok {
+			continue
+		}
+		if !p.Multi {
+			return map[string]interface{}{"value": v}, nil
+		}
+		if values, _ := v.([]interface{}); len(values) > 0 {
+			return map[string]interface{}{"value": values[0]}, nil
+		}
+	}
+	return nil, fmt.Errorf("no branch produced a value")
+}
+
+// arrowInactive reports whether an arrow carries nothing in the current run
+// because it leaves a branch not taken or a skipped card
+func (e *Engine) arrowInactive(a *Arrow) bool {
+	return e.inactive[a.FromCardID+":"+a.FromPort] || e.skipped[a.FromCardID]
+}
+
+// skippedByBranch reports whether every arrow into the card comes from an inactive
+// branch, so that it is reachable only through a branch not taken
+func (e *Engine) skippedByBranch(c *Card) bool {
+	wired := false
+	for _, a := range e.game.arrows {
+		if a.ToCardID != c.ID || e.game.getCardByID(a.FromCardID) == nil {
+			continue
+		}
+		if !e.arrowInactive(a) {
+			return false
+		}
+		wired = true
+	}
+	return wired
+}
+
+// IsSkipped reports whether the card was skipped in the last run
+func (e *Engine) IsSkipped(c *Card) bool {
+	return e != nil && e.skipped[c.ID]
+}
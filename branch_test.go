@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestIfSkipsInactiveBranch(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	cond := g.AddTextCard(100, 100)
+	value := g.AddTextCard(300, 100)
+	value.Text = "Hello"
+	branch := g.AddIfCard(200, 300)
+	upper := g.AddFormulaCard(100, 500)
+	lower := g.AddFormulaCard(500, 300)
+	lower.Text = "=LOWER(input)"
+	merge := g.AddMergeCard(200, 700)
+	// A merge card saved before ports took many wires keeps its a and b ports
+	saved := g.AddMergeCard(600, 700)
+	saved.Inputs = []Port{{Name: "a", Type: "any"}, {Name: "b", Type: "any"}}
+	g.arrows = []*Arrow{
+		{FromCardID: cond.ID, FromPort: "text", ToCardID: branch.ID, ToPort: "condition"},
+		{FromCardID: value.ID, FromPort: "text", ToCardID: branch.ID, ToPort: "value"},
+		{FromCardID: branch.ID, FromPort: "then", ToCardID: upper.ID, ToPort: "input"},
+		{FromCardID: branch.ID, FromPort: "else", ToCardID: lower.ID, ToPort: "input"},
+		{FromCardID: upper.ID, FromPort: "result", ToCardID: merge.ID, ToPort: "values"},
+		{FromCardID: lower.ID, FromPort: "result", ToCardID: merge.ID, ToPort: "values"},
+		{FromCardID: upper.ID, FromPort: "result", ToCardID: saved.ID, ToPort: "a"},
+		{FromCardID: lower.ID, FromPort: "result", ToCardID: saved.ID, ToPort: "b"},
+	}
+
+	for _, tc := range []struct {
+		cond    string
+		skipped *Card
+		ran     *Card
+		want    string
+	}{
+		{"yes", lower, upper, "HELLO"},
+		{"false", upper, lower, "hello"},
+		{"", upper, lower, "hello"},
+	} {
+		cond.Text = tc.cond
+		g.engine.Run()
+		if !g.engine.IsSkipped(tc.skipped) || g.engine.IsSkipped(tc.ran) {
+			t.Errorf("condition %q: expected only %s to be skipped", tc.cond, tc.skipped.Text)
+		}
+		if v, ok := g.engine.Memory[tc.skipped.ID+":result"]; ok {
+			t.Errorf("condition %q: expected the skipped card's result from the last run to be cleared, got %v", tc.cond, v)
+		}
+		for _, m := range []*Card{merge, saved} {
+			if g.engine.IsSkipped(m) || m.LastError != "" {
+				t.Errorf("condition %q: expected the merge card to run, got error %q", tc.cond, m.LastError)
+			}
+			if got := g.engine.Memory[m.ID+":value"]; got != tc.want {
+				t.Errorf("condition %q: expected %q, got %v", tc.cond, tc.want, got)
+			}
+		}
+	}
+}
+
+func TestLateralOutputPort(t *testing.T) {
+	g := NewGame()
+	branch := g.AddIfCard(0, 0)
+	if x, y := branch.GetOutputPortPosition("then"); x != branch.Width/2 || y != branch.Height {
+		t.Errorf("Expected then at the middle of the bottom edge, got (%v, %v)", x, y)
+	}
+	if x, y := branch.GetOutputPortPosition("else"); x != branch.Width || y <= HeaderHeight || y >= branch.Height {
+		t.Errorf("Expected else on the right edge, got (%v, %v)", x, y)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// calledFlow is the flow file last loaded by a call_flow card, kept so that its
+// inner cards stay cached between runs
+type calledFlow struct {
+	path   string
+	hash   string
+	engine *Engine
+}
+
+// AddFlowInputCard adds a card that receives a value when its flow is called from
+// another flow. Run on its own it passes on its default input.
+func (g *Game) AddFlowInputCard(x, y float64) *Card {
+	card := &Card{
+		ID:      NewID(),
+		Type:    "flow_input",
+		X:       math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:       math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:   DefaultCardWidth,
+		Height:  DefaultCardHeight,
+		Color:   ColorCardDefault,
+		Title:   "Flow:input",
+		Inputs:  []Port{{Name: "default", Type: "any"}},
+		Outputs: []Port{{Name: "value", Type: "any"}},
+		Params:  map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// AddFlowOutputCard adds a card whose input is returned to flows calling this one
+func (g *Game) AddFlowOutputCard(x, y float64) *Card {
+	card := &Card{
+		ID:      NewID(),
+		Type:    "flow_output",
+		X:       math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:       math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:   DefaultCardWidth,
+		Height:  DefaultCardHeight,
+		Color:   ColorCardDefault,
+		Title:   "Flow:output",
+		Inputs:  []Port{{Name: "value", Type: "any"}},
+		Outputs: []Port{{Name: "value", Type: "any"}},
+		Params:  map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// AddCallFlowCard adds a card that runs another saved flow file. Its ports are the
+// flow input and output cards of that flow.
+func (g *Game) AddCallFlowCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "call_flow",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth * 1.5,
+		Height: DefaultCardHeight * 1.5,
+		Color:  ColorCardComposite,
+		Title:  "Flow:call",
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// executeFlowInput outputs the value passed in by a calling flow, or the default input
+func (e *Engine) executeFlowInput(c *Card, inputs map[string]interface{}) map[string]interface{} {
+	if v, ok := e.args[fmt.Sprint(c.Param("name"))]; ok {
+		return map[string]interface{}{"value": v}
+	}
+	return map[string]interface{}{"value": inputs["default"]}
+}
+
+// resolveFlowPath makes a flow path absolute. Paths inside a called flow are
+// relative to that flow's file.
+func (e *Engine) resolveFlowPath(path string) (string, error) {
+	if !filepath.IsAbs(path) && len(e.calls) > 0 {
+		path = filepath.Join(filepath.Dir(e.calls[len(e.calls)-1]), path)
+	}
+	return filepath.Abs(path)
+}
+
+// calledFlowStamp identifies the version of every file a call_flow card's run
+// reads: its flow file, the flows that one calls in turn and their workbooks
+func (e *Engine) calledFlowStamp(c *Card) string {
+	flow, err := e.loadCalledFlow(c)
+	if err != nil {
+		return ""
+	}
+	stamps := []string{flow.hash}
+	flow.engine.fileStamps(flow.engine.game.cards, &stamps)
+	return strings.Join(stamps, " ")
+}
+
+// fileStamps appends the stamps of the files read by cards, also inside composites
+func (e *Engine) fileStamps(cards []*Card, stamps *[]string) {
+	for _, c := range cards {
+		switch c.Type {
+		case "xlsx_import":
+			*stamps = append(*stamps, fileStamp(c.ParamText("path")))
+		case "call_flow":
+			*stamps = append(*stamps, e.calledFlowStamp(c))
+		}
+		if c.Subflow != nil {
+			e.fileStamps(c.Subflow.Cards, stamps)
+		}
+	}
+}
+
+// loadCalledFlow reads the flow file of a call_flow card, reusing the last load
+// while the file is unchanged
+func (e *Engine) loadCalledFlow(c *Card) (*calledFlow, error) {
+	path, err := e.resolveFlowPath(c.ParamText("path"))
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(e.calls, path) {
+		chain := []string{}
+		for _, p := range append(slices.Clone(e.calls), path) {
+			chain = append(chain, filepath.Base(p))
+		}
+		return nil, fmt.Errorf("recursive flow call: %s", strings.Join(chain, " -> "))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256(data))
+	if c.called != nil && c.called.path == path && c.called.hash == hash {
+		return c.called, nil
+	}
+	var state AppState
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	cards := cardsFromStates(state.Cards)
+	vars := state.Variables
+	if vars == nil {
+		vars = &Variables{}
+	}
+	child := NewEngine(&Game{cards: cards, arrows: arrowsFromStates(state.Arrows, cards), variables: vars})
+	child.calls = append(slices.Clone(e.calls), path)
+	c.called = &calledFlow{path: path, hash: hash, engine: child}
+	return c.called, nil
+}
+
+// flowPorts returns the flow input and output cards of a flow, in canvas order
+// from top to bottom
+func flowPorts(cards []*Card) (inputs, outputs []*Card) {
+	for _, c := range cards {
+		switch c.Type {
+		case "flow_input":
+			inputs = append(inputs, c)
+		case "flow_output":
+			outputs = append(outputs, c)
+		}
+	}
+	byPosition := func(list []*Card) {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Y != list[j].Y {
+				return list[i].Y < list[j].Y
+			}
+			return list[i].X < list[j].X
+		})
+	}
+	byPosition(inputs)
+	byPosition(outputs)
+	return inputs, outputs
+}
+
+// setCallFlowPorts makes the card's ports match the flow it calls
+func (c *Card) setCallFlowPorts(flow *calledFlow) {
+	inputs, outputs := flowPorts(flow.engine.game.cards)
+	c.Inputs, c.Outputs = nil, nil
+	for _, in := range inputs {
+		c.Inputs = append(c.Inputs, Port{Name: fmt.Sprint(in.Param("name")), Type: "any"})
+	}
+	for _, out := range outputs {
+		c.Outputs = append(c.Outputs, Port{Name: fmt.Sprint(out.Param("name")), Type: "any"})
+	}
+	rows := max(len(c.Inputs), len(c.Outputs), 2)
+	c.Height = math.Max(c.Height, HeaderHeight+FooterHeight+float64(rows+1)*FormRowHeight+2*CardPaddingY)
+}
+
+// executeCallFlow runs the flow file of a call_flow card in its own engine, with
+// the card's inputs passed to the flow input cards of the same name
+func (e *Engine) executeCallFlow(c *Card, inputs map[string]interface{}) (map[string]interface{}, error) {
+	flow, err := e.loadCalledFlow(c)
+	if err != nil {
+		return nil, err
+	}
+	c.setCallFlowPorts(flow)
+
+	child := flow.engine
+	child.args = inputs
+	// The called flow follows the caller's profile when it has one of that name
+	if v := child.game.variables; e.vars != nil {
+		v.override = ""
+		if v.Profiles[e.vars.profile] != nil {
+			v.override = e.vars.profile
+		}
+	}
+	child.Run()
+	for _, ic := range child.game.cards {
+		if ic.LastError != "" {
+			return nil, fmt.Errorf("%s: %s: %s", filepath.Base(flow.path), ic.Title, ic.LastError)
+		}
+	}
+	_, outs := flowPorts(child.game.cards)
+	outputs := map[string]interface{}{}
+	for _, out := range outs {
+		outputs[fmt.Sprint(out.Param("name"))] = child.Memory[out.ID+":value"]
+	}
+	return outputs, nil
+}
+
+// refreshCallFlow loads the ports of a call_flow card after its path changes
+// and removes wires to ports the new flow doesn't have
+func (g *Game) refreshCallFlow(c *Card) {
+	flow, err := g.engine.loadCalledFlow(c)
+	if err != nil {
+		c.LastError = err.Error()
+		return
+	}
+	c.LastError = ""
+	c.setCallFlowPorts(flow)
+	g.removeStaleArrows(c)
+}
+
+func (c *Card) flowPortFields(g *Game) []formField {
+	return []formField{
+		{Label: "Name", Value: fmt.Sprint(c.Param("name")), Edit: true, Set: func(v string) {
+			c.setParams(map[string]interface{}{"name": strings.TrimSpace(v)})
+		}},
+	}
+}
+
+func (c *Card) callFlowFields(g *Game) []formField {
+	return []formField{
+		{Label: "Flow", Value: fmt.Sprint(c.Param("path")), Edit: true, Set: func(v string) {
+			c.setParams(map[string]interface{}{"path": v})
+			g.refreshCallFlow(c)
+		}},
+	}
+}
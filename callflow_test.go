@@ -0,0 +1,172 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// saveSharedFlow saves a flow replacing "World" with "Universe" in its "text" input
+func saveSharedFlow(t *testing.T, path string) {
+	t.Helper()
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	in := g.AddFlowInputCard(100, 100)
+	in.setParams(map[string]interface{}{"name": "text"})
+	fallback := g.AddTextCard(100, 300)
+	fallback.Text = "Hello World"
+	fr := g.AddFindReplaceCard(400, 100)
+	find := g.AddTextCard(400, 300)
+	find.Text = "World"
+	replace := g.AddTextCard(400, 500)
+	replace.Text = "Universe"
+	out := g.AddFlowOutputCard(700, 100)
+	out.setParams(map[string]interface{}{"name": "result"})
+	g.arrows = []*Arrow{
+		{FromCardID: fallback.ID, FromPort: "text", ToCardID: in.ID, ToPort: "default"},
+		{FromCardID: in.ID, FromPort: "value", ToCardID: fr.ID, ToPort: "input"},
+		{FromCardID: find.ID, FromPort: "text", ToCardID: fr.ID, ToPort: "find"},
+		{FromCardID: replace.ID, FromPort: "text", ToCardID: fr.ID, ToPort: "replace"},
+		{FromCardID: fr.ID, FromPort: "result", ToCardID: out.ID, ToPort: "value"},
+	}
+
+	// On its own the flow uses the default input
+	g.engine.Run()
+	if got := g.engine.Memory[out.ID+":value"]; got != "Hello Universe" {
+		t.Fatalf("Expected the flow to run on its own, got %v", got)
+	}
+	if err := SaveState(g, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCallFlowCard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.yaml")
+	saveSharedFlow(t, path)
+
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	text := g.AddTextCard(100, 100)
+	text.Text = "World peace"
+	call := g.AddCallFlowCard(400, 100)
+	call.setParams(map[string]interface{}{"path": path})
+	g.refreshCallFlow(call)
+	if len(call.Inputs) != 1 || call.Inputs[0].Name != "text" || len(call.Outputs) != 1 || call.Outputs[0].Name != "result" {
+		t.Fatalf("Expected the flow's input and output cards as ports, got %+v and %+v", call.Inputs, call.Outputs)
+	}
+	g.arrows = append(g.arrows, &Arrow{FromCardID: text.ID, FromPort: "text", ToCardID: call.ID, ToPort: "text"})
+
+	g.engine.Run()
+	if call.LastError != "" {
+		t.Fatal(call.LastError)
+	}
+	if got := g.engine.Memory[call.ID+":result"]; got != "Universe peace" {
+		t.Errorf("Expected %q, got %v", "Universe peace", got)
+	}
+
+	// Unchanged inputs and file use the cache; editing the file reruns the call
+	ran := g.engine.ExecutionCache[call.ID].ExecutedAt
+	g.engine.Run()
+	if g.engine.ExecutionCache[call.ID].ExecutedAt != ran {
+		t.Errorf("Expected a cache hit while the flow file is unchanged")
+	}
+	shared := NewGame()
+	if err := LoadState(shared, path); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range shared.cards {
+		if c.Text == "Universe" {
+			c.Text = "Gophers"
+		}
+	}
+	if err := SaveState(shared, path); err != nil {
+		t.Fatal(err)
+	}
+	g.engine.Run()
+	if got := g.engine.Memory[call.ID+":result"]; got != "Gophers peace" {
+		t.Errorf("Expected the edited flow to run, got %v", got)
+	}
+}
+
+func TestCallFlowDetectsRecursion(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")
+	for _, f := range []struct{ path, calls string }{{a, "b.yaml"}, {b, "a.yaml"}} {
+		g := NewGame()
+		g.cards = []*Card{}
+		g.arrows = []*Arrow{}
+		g.AddCallFlowCard(0, 0).setParams(map[string]interface{}{"path": f.calls})
+		if err := SaveState(g, f.path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	call := g.AddCallFlowCard(0, 0)
+	call.setParams(map[string]interface{}{"path": a})
+	g.engine.Run()
+	if !strings.Contains(call.LastError, "recursive flow call: a.yaml -> b.yaml -> a.yaml") {
+		t.Errorf("Expected the recursion to be reported, got %q", call.LastError)
+	}
+}
+
+func TestCallFlowRerunsWhenANestedFlowChanges(t *testing.T) {
+	dir := t.TempDir()
+	shared, wrapper := filepath.Join(dir, "shared.yaml"), filepath.Join(dir, "wrapper.yaml")
+	saveSharedFlow(t, shared)
+
+	// wrapper.yaml passes its input through shared.yaml
+	w := NewGame()
+	w.cards = []*Card{}
+	w.arrows = []*Arrow{}
+	in := w.AddFlowInputCard(100, 100)
+	in.setParams(map[string]interface{}{"name": "text"})
+	inner := w.AddCallFlowCard(400, 100)
+	inner.setParams(map[string]interface{}{"path": shared})
+	w.refreshCallFlow(inner)
+	out := w.AddFlowOutputCard(700, 100)
+	out.setParams(map[string]interface{}{"name": "result"})
+	w.arrows = []*Arrow{
+		{FromCardID: in.ID, FromPort: "value", ToCardID: inner.ID, ToPort: "text"},
+		{FromCardID: inner.ID, FromPort: "result", ToCardID: out.ID, ToPort: "value"},
+	}
+	if err := SaveState(w, wrapper); err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	text := g.AddTextCard(100, 100)
+	text.Text = "World peace"
+	call := g.AddCallFlowCard(400, 100)
+	call.setParams(map[string]interface{}{"path": wrapper})
+	g.refreshCallFlow(call)
+	g.arrows = append(g.arrows, &Arrow{FromCardID: text.ID, FromPort: "text", ToCardID: call.ID, ToPort: "text"})
+	g.engine.Run()
+	if got := g.engine.Memory[call.ID+":result"]; got != "Universe peace" {
+		t.Fatalf("Expected %q, got %v (%s)", "Universe peace", got, call.LastError)
+	}
+
+	// Only shared.yaml changes, wrapper.yaml stays the same
+	edited := NewGame()
+	if err := LoadState(edited, shared); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range edited.cards {
+		if c.Text == "Universe" {
+			c.Text = "Gophers"
+		}
+	}
+	if err := SaveState(edited, shared); err != nil {
+		t.Fatal(err)
+	}
+	g.engine.Run()
+	if got := g.engine.Memory[call.ID+":result"]; got != "Gophers peace" {
+		t.Errorf("Expected the edited nested flow to run, got %v", got)
+	}
+}
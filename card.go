@@ -12,8 +12,10 @@ import (
 
 // Port represents an input or output on a block
 type Port struct {
-	Name string
-	Type string
+	Name    string
+	Type    string
+	Lateral bool // output on the right edge, for a branch leaving the main downward flow
+	Multi   bool // input accepting many wires, whose values arrive as a list
 }
 
 // Subscription represents a card subscribing to this card's output
@@ -35,9 +37,153 @@ type Card struct {
 	IsCommit         bool
 	Inputs           []Port
 	Outputs          []Port
-	Subscribers      []Subscription // Cards subscribed to this card's output
-	LastSuccessFlash time.Time      // When the last success flash occurred
-	LastErrorFlash   time.Time      // When the last error flash occurred
+	Params           map[string]interface{} // Per-card settings that are not wired in
+	Cells            [][]string             // Grid card contents; the first row is the header
+	SelRow, SelCol   int                    // Selected grid cell
+	FormField        int                    // Form text field being edited
+	HidePreview      bool                   // Hide the ghost data preview under this card
+	Markdown         bool                   // Render the text as markdown
+	AttachedTo       string                 // ID of the card a note is pinned to
+	Subflow          *Subflow               // Cards run inside a composite card
+	markdown         *markdownLayout        // Cached markdown layout of the text
+	chart            *chartSeries           // Data plotted by chart cards, set when the card runs
+	sheetNames       []string               // Sheets found by the last xlsx import
+	called           *calledFlow            // Flow file last run by a call_flow card
+	portEdit         int                    // 1 + index of the input port being renamed, 0 when none
+	portDraft        string                 // Name typed so far for the port being renamed
+	settingsOpen     bool                   // The settings panel is shown instead of the body
+	vars             *runVars               // Flow variables of the last run, expanded in parameters
+	status           string                 // Outcome of the last run shown under a form, e.g. the file written
+	llmCall          *llmCall               // Request to a language model not yet used by a run
+	Subscribers      []Subscription         // Cards subscribed to this card's output
+	LastSuccessFlash time.Time              // When the last success flash occurred
+	LastErrorFlash   time.Time              // When the last error flash occurred
+	LastError        string                 // Message from the last failed execution
+}
+
+// cardParamDefaults holds the default parameter values for each card type
+var cardParamDefaults = map[string]map[string]interface{}{
+	"find_replace": {
+		"regex":       false,
+		"ignore_case": false,
+		"whole_word":  false,
+		"max_count":   0,
+	},
+	"join": {
+		"keys":         "",
+		"mode":         "inner",
+		"left_suffix":  "_left",
+		"right_suffix": "_right",
+	},
+	"groupby": {
+		"group_by":     []interface{}{},
+		"aggregations": []interface{}{},
+		"pivot":        "",
+	},
+	"filter": {
+		"conditions": []interface{}{},
+		"match":      "all",
+	},
+	"sort": {
+		"keys": []interface{}{},
+	},
+	"chart": {
+		"kind": "bar",
+		"x":    "",
+		"y":    "",
+	},
+	"export": {
+		"path":   "output.csv",
+		"format": "csv",
+		"mode":   "overwrite",
+	},
+	"xlsx_import": {
+		"path":   "input.xlsx",
+		"sheet":  "",
+		"header": "yes",
+	},
+	"template": {
+		"syntax": "go",
+		"inputs": "data",
+	},
+	"note": {
+		"color": "yellow",
+		"size":  "normal",
+	},
+	"composite": {
+		"type": "",
+	},
+	"concat": {
+		"separator": "",
+	},
+	"map": {
+		"parallel": "no",
+	},
+	"llm": {
+		"task":         "summarize",
+		"tone":         "neutral",
+		"instructions": "",
+		"provider":     "openai",
+		"model":        "gpt-4o-mini",
+		"base_url":     "https://api.openai.com/v1",
+		"api_key":      "",
+		"temperature":  0.2,
+	},
+	"flow_input": {
+		"name": "input",
+	},
+	"flow_output": {
+		"name": "output",
+	},
+	"call_flow": {
+		"path": "flow.yaml",
+	},
+	"xlsx_export": {
+		"path":  "output.xlsx",
+		"sheet": "Sheet1",
+		"mode":  "overwrite",
+	},
+}
+
+// inputPort returns the input port with the name, or a zero Port if there is none
+func (c *Card) inputPort(name string) Port {
+	for _, p := range c.Inputs {
+		if p.Name == name {
+			return p
+		}
+	}
+	return Port{}
+}
+
+// Param returns the card's value for a parameter, falling back to the type default
+func (c *Card) Param(name string) interface{} {
+	if v, ok := c.Params[name]; ok {
+		return v
+	}
+	return cardParamDefaults[c.Type][name]
+}
+
+// ResolvedParams returns every parameter of the card with defaults applied and
+// the flow variables of the run expanded in its text
+func (c *Card) ResolvedParams() map[string]interface{} {
+	params := c.paramsWithDefaults()
+	for k, v := range params {
+		params[k] = c.vars.expandValue(v)
+	}
+	return params
+}
+
+// paramsWithDefaults returns every parameter of the card with defaults applied,
+// as forms edit them
+func (c *Card) paramsWithDefaults() map[string]interface{} {
+	params := make(map[string]interface{})
+	for k, v := range cardParamDefaults[c.Type] {
+		params[k] = v
+	}
+	for k, v := range c.Params {
+		params[k] = v
+	}
+	return params
 }
 
 func (g *Game) AddTextCard(x, y float64) *Card {
@@ -80,7 +226,10 @@ func (g *Game) AddFindReplaceCard(x, y float64) *Card {
 		},
 		Outputs: []Port{
 			{Name: "result", Type: "string"},
+			{Name: "count", Type: "int"},
+			{Name: "matches", Type: "list"},
 		},
+		Params: map[string]interface{}{},
 	}
 	g.cards = append(g.cards, card)
 	return card
@@ -105,9 +254,31 @@ func (c *Card) Draw(screen *ebiten.Image, g *Game, cw, ch float64, hovered bool)
 		footerHeight = FooterHeight
 	}
 
-	c.drawContent(screen, g, sx, sy, headerHeight)
+	if c.Type == "grid" {
+		c.drawGrid(screen, g, cw, ch)
+	} else if c.IsNote() && !c.settingsOpen {
+		c.drawNote(screen, g, sx, sy, sw, sh)
+	} else if c.formFields(g) != nil {
+		c.drawForm(screen, g, cw, ch)
+		if c.Type == "chart" {
+			c.drawChart(screen, g, cw, ch)
+		} else if c.Type == "template" {
+			c.drawTemplateText(screen, g, cw, ch)
+		}
+	} else {
+		c.drawContent(screen, g, sx, sy, headerHeight)
+	}
 	c.drawDividers(screen, g, sx, sy, sw, sh, headerHeight, footerHeight, cw, ch)
 	c.drawPorts(screen, g, sx, sy, sw, sh, headerHeight, footerHeight, cw, ch)
+	if c.IsVariadic() {
+		c.drawAddPortButton(screen, g, cw, ch)
+	}
+	c.drawGhostPreview(screen, g, cw, ch)
+
+	// Cards on a branch not taken are dimmed
+	if g.engine.IsSkipped(c) {
+		vector.DrawFilledRect(screen, float32(sx), float32(sy), float32(sw), float32(sh), ColorSkippedOverlay, false)
+	}
 }
 
 func (c *Card) drawBody(screen *ebiten.Image, g *Game, sx, sy, sw, sh float64) {
@@ -139,6 +310,12 @@ func (c *Card) drawSelectionBorder(screen *ebiten.Image, g *Game, hovered bool,
 	} else if hovered && g.input.ActiveCard == nil {
 		showBorder = true
 		borderColor = ColorCardHover
+	} else if g.isSelected(c) {
+		showBorder = true
+		borderColor = ColorCardSelected
+	} else if c.ID == g.inspector.CardID && !g.inspector.Hidden {
+		showBorder = true
+		borderColor = ColorCardActive
 	}
 
 	if showBorder {
@@ -190,7 +367,11 @@ func (c *Card) drawHeader(screen *ebiten.Image, g *Game, sx, sy, sw float64, wx,
 	} else {
 		msg = c.Title
 	}
-	DrawTextLines(screen, g.FontFace, msg, int(sx+CardPaddingX*g.camera.Zoom), int(sy+CardPaddingY*g.camera.Zoom)+2, color.White)
+	titleColor := color.Color(color.White)
+	if c.IsNote() {
+		titleColor = ColorNoteText
+	}
+	DrawTextLines(screen, g.FontFace, msg, int(sx+CardPaddingX*g.camera.Zoom), int(sy+CardPaddingY*g.camera.Zoom)+2, titleColor)
 
 	// Buttons
 	zoom := g.camera.Zoom
@@ -227,6 +408,19 @@ func (c *Card) drawHeader(screen *ebiten.Image, g *Game, sx, sy, sw float64, wx,
 	}
 	vector.DrawFilledRect(screen, float32(dBtnX), float32(dBtnY), float32(btnW), float32(btnH), dColor, false)
 	DrawTextLines(screen, g.FontFace, "++", int(dBtnX+btnW/2-6), int(dBtnY+btnH/2-8), color.White)
+
+	// * (Settings)
+	if c.HasSettings() {
+		x, y, w, h := c.settingsButton()
+		sBtnX := dBtnX - btnW - btnMargin
+		sColor := ColorButtonBackground
+		sColor.A = 30
+		if c.settingsOpen || (wx >= x && wx <= x+w && wy >= y && wy <= y+h) {
+			sColor.A = 200
+		}
+		vector.DrawFilledRect(screen, float32(sBtnX), float32(dBtnY), float32(btnW), float32(btnH), sColor, false)
+		DrawTextLines(screen, g.FontFace, "*", int(sBtnX+btnW/2-3), int(dBtnY+btnH/2-8), color.White)
+	}
 }
 
 func (c *Card) drawContent(screen *ebiten.Image, g *Game, sx, sy, headerHeight float64) {
@@ -237,7 +431,7 @@ func (c *Card) drawContent(screen *ebiten.Image, g *Game, sx, sy, headerHeight f
 		isPortConnected := g.IsInputPortConnected(c.ID, "text")
 		if isPortConnected {
 			// Get the actual input value from the connected source
-			textContent = g.GetInputValue(c.ID, "text")
+			textContent = secretStore.Redact(g.GetInputValue(c.ID, "text"))
 		} else {
 			textContent = c.Text
 		}
@@ -252,12 +446,41 @@ func (c *Card) drawContent(screen *ebiten.Image, g *Game, sx, sy, headerHeight f
 		textContent = c.Text
 	}
 
+	// Formula cards show the computed value under the formula
+	if c.Type == "formula" && c.LastError == "" && g.engine != nil {
+		if val, ok := g.engine.Memory[c.ID+":result"]; ok {
+			textContent += "\n= " + secretStore.Redact(fmt.Sprint(val))
+		}
+	}
+
 	zoom := g.camera.Zoom
 	paddingX := CardPaddingX * zoom
 	// Port panel is 1/3 of card width
 	portPanelWidth := (c.Width / 3.0) * zoom
 	paddingY := CardPaddingY * zoom
-	DrawTextLines(screen, g.FontFace, textContent, int(sx+portPanelWidth+paddingX), int(sy+headerHeight+paddingY), color.White)
+	contentHeight := float64(len(splitLines(textContent))) * TextLineHeight * zoom
+	if c.Markdown && !c.IsEditing {
+		// Markdown fills the body; the error goes at the bottom
+		x, y := sx+portPanelWidth+paddingX, sy+headerHeight+paddingY
+		w := c.Width*zoom - portPanelWidth - 2*paddingX
+		h := (c.Height-HeaderHeight)*zoom - 2*paddingY
+		if len(c.Outputs) > 0 {
+			h -= FooterHeight * zoom
+		}
+		if c.LastError != "" {
+			h -= TextLineHeight * zoom
+		}
+		c.drawRichText(screen, g, textContent, textStyle{Markdown: true, Size: MarkdownFontSize, Color: color.White}, x, y, w, h)
+		contentHeight = h
+	} else {
+		DrawTextLines(screen, g.FontFace, textContent, int(sx+portPanelWidth+paddingX), int(sy+headerHeight+paddingY), color.White)
+	}
+
+	// Last execution error, below the content
+	if c.LastError != "" {
+		errY := sy + headerHeight + paddingY + contentHeight
+		DrawTextLines(screen, g.FontFace, c.LastError, int(sx+portPanelWidth+paddingX), int(errY), ColorErrorText)
+	}
 }
 
 func (c *Card) drawDividers(screen *ebiten.Image, g *Game, sx, sy, sw, sh, headerHeight, footerHeight float64, cw, ch float64) {
@@ -320,6 +543,11 @@ func (c *Card) drawPorts(screen *ebiten.Image, g *Game, sx, sy, sw, sh, headerHe
 				dotColor = ColorPortHover
 			}
 
+			// Ports taking many wires are drawn as a stack
+			if port.Multi {
+				off := float32(3 * zoom)
+				vector.StrokeRect(screen, float32(spx-portSize/2)-off, float32(spy-portSize/2)-off, float32(portSize), float32(portSize), 1, portColor, false)
+			}
 			vector.DrawFilledRect(screen, float32(spx-portSize/2), float32(spy-portSize/2), float32(portSize), float32(portSize), portColor, false)
 			vector.DrawFilledCircle(screen, float32(spx), float32(spy), float32(3*zoom), dotColor, false)
 
@@ -328,7 +556,7 @@ func (c *Card) drawPorts(screen *ebiten.Image, g *Game, sx, sy, sw, sh, headerHe
 				vector.StrokeCircle(screen, float32(spx), float32(spy), float32(portSize/2+2*zoom), 2*float32(zoom), ColorPortHighlight, false)
 			}
 
-			label := fmt.Sprintf("%s:%s", port.Name, port.Type)
+			label := c.portLabel(g, i)
 			// Choose label color: bright when hovered/selected/active, dim otherwise
 			labelColor := ColorPortLabelDim
 			if selected || hovered {
@@ -341,12 +569,11 @@ func (c *Card) drawPorts(screen *ebiten.Image, g *Game, sx, sy, sw, sh, headerHe
 		}
 	}
 
-	// Outputs (Bottom)
+	// Outputs (Bottom, lateral ones on the right)
 	if len(c.Outputs) > 0 {
-		xSpacing := c.Width / float64(len(c.Outputs)+1)
-		for i, port := range c.Outputs {
-			px := c.X + xSpacing*float64(i+1)
-			spx, spy := g.camera.WorldToScreen(px, c.Y+c.Height, cw, ch)
+		for _, port := range c.Outputs {
+			px, py := c.GetOutputPortPosition(port.Name)
+			spx, spy := g.camera.WorldToScreen(px, py, cw, ch)
 
 			// Determine port color
 			portColor := ColorPortBody
@@ -379,7 +606,11 @@ func (c *Card) drawPorts(screen *ebiten.Image, g *Game, sx, sy, sw, sh, headerHe
 			if portColor == ColorPortActive {
 				labelColor = color.RGBA{255, 255, 255, 255}
 			}
-			DrawTextLines(screen, g.FontFace, label, int(spx-20*zoom), int(spy-20*zoom), labelColor)
+			lx, ly := spx-20*zoom, spy-20*zoom
+			if port.Lateral {
+				lx, ly = spx+portSize, spy-8*zoom
+			}
+			DrawTextLines(screen, g.FontFace, label, int(lx), int(ly), labelColor)
 		}
 	}
 }
@@ -439,8 +670,23 @@ func (c *Card) GetOutputPortPosition(name string) (float64, float64) {
 		return c.X + c.Width, c.Y + c.Height // Fallback
 	}
 
-	xSpacing := c.Width / float64(len(c.Outputs)+1)
-	px := c.X + xSpacing*float64(index+1)
+	// Lateral outputs are spread down the right edge, the others along the bottom
+	lateral := c.Outputs[index].Lateral
+	n, pos := 0, 0
+	for i, p := range c.Outputs {
+		if p.Lateral == lateral {
+			if i < index {
+				pos++
+			}
+			n++
+		}
+	}
+	if lateral {
+		ySpacing := (c.Height - HeaderHeight) / float64(n+1)
+		return c.X + c.Width, c.Y + HeaderHeight + ySpacing*float64(pos+1)
+	}
+	xSpacing := c.Width / float64(n+1)
+	px := c.X + xSpacing*float64(pos+1)
 	return px, c.Y + c.Height
 }
 
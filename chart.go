@@ -0,0 +1,369 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"strconv"
+
+	"card-flows/engine"
+	"card-flows/transform"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// ChartKinds lists the supported chart types in form order
+var ChartKinds = []string{"bar", "line", "scatter"}
+
+func (g *Game) AddChartCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "chart",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth * 2,
+		Height: DefaultCardHeight * 3,
+		Color:  ColorCardDefault,
+		Title:  "Chart",
+		Inputs: []Port{
+			{Name: "table", Type: "table"},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// chartSeries is the data a chart plots: one point per table row
+type chartSeries struct {
+	Labels   []string  // x value of each point as text
+	X        []float64 // numeric x, or the row index when the x column is not numeric
+	Y        []float64
+	NumericX bool
+}
+
+// buildChartSeries extracts the x and y columns of a table. Rows whose y value
+// is not a number are skipped. An empty x column plots against the row number.
+func buildChartSeries(t *engine.Table, xCol, yCol string) (*chartSeries, error) {
+	yi := t.ColumnIndex(yCol)
+	if yi < 0 {
+		return nil, &transform.ColumnError{Column: yCol, Columns: t.Columns}
+	}
+	xi := -1
+	if xCol != "" {
+		if xi = t.ColumnIndex(xCol); xi < 0 {
+			return nil, &transform.ColumnError{Column: xCol, Columns: t.Columns}
+		}
+	}
+
+	s := &chartSeries{NumericX: true}
+	for r := 0; r < t.NumRows(); r++ {
+		y, ok := chartNumber(t.Value(r, yi))
+		if !ok {
+			continue
+		}
+		label := strconv.Itoa(r + 1)
+		x := float64(r + 1)
+		if xi >= 0 {
+			v := t.Value(r, xi)
+			label = fmt.Sprint(v)
+			if v == nil {
+				label = ""
+			}
+			if n, ok := chartNumber(v); ok {
+				x = n
+			} else {
+				s.NumericX = false
+			}
+		}
+		s.Labels = append(s.Labels, label)
+		s.X = append(s.X, x)
+		s.Y = append(s.Y, y)
+	}
+	// Text x values are plotted as evenly spaced categories
+	if !s.NumericX {
+		for i := range s.X {
+			s.X[i] = float64(i + 1)
+		}
+	}
+	return s, nil
+}
+
+func chartNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// executeChart prepares the series for drawing; charts have no outputs
+func executeChart(c *Card, inputs map[string]interface{}) (map[string]interface{}, error) {
+	c.chart = nil
+	t, err := tableInput(inputs, "table")
+	if err != nil {
+		return nil, err
+	}
+	x := fmt.Sprint(c.Param("x"))
+	if x == transform.None {
+		x = ""
+	}
+	y := fmt.Sprint(c.Param("y"))
+	if y == "" || y == transform.None {
+		return nil, fmt.Errorf("choose a column for the y axis")
+	}
+	series, err := buildChartSeries(t, x, y)
+	if err != nil {
+		return nil, err
+	}
+	c.chart = series
+	return map[string]interface{}{}, nil
+}
+
+type chartRect struct{ X, Y, W, H float64 }
+
+type chartPoint struct{ X, Y float64 }
+
+type chartLabel struct {
+	X, Y float64 // top-left of the text
+	Text string
+}
+
+// chartGeometry is a chart laid out in a w x h box with the origin at the top left
+type chartGeometry struct {
+	Plot    chartRect // area inside the axes
+	Bars    []chartRect
+	Points  []chartPoint
+	Line    bool // connect Points in order
+	XLabels []chartLabel
+	YLabels []chartLabel
+}
+
+// layoutChart positions the marks and axis labels of a chart. It only does
+// arithmetic, so drawing on the canvas and PNG export share the same layout.
+func layoutChart(s *chartSeries, kind string, w, h float64) chartGeometry {
+	plot := chartRect{X: ChartAxisMarginLeft, Y: ChartPadding, W: w - ChartAxisMarginLeft - ChartPadding, H: h - ChartAxisMarginBottom - ChartPadding}
+	geo := chartGeometry{Plot: plot, Line: kind == "line"}
+	if s == nil || len(s.Y) == 0 || plot.W <= 0 || plot.H <= 0 {
+		return geo
+	}
+
+	minY, maxY := s.Y[0], s.Y[0]
+	for _, y := range s.Y {
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	if kind == "bar" {
+		// Bars grow from zero
+		minY, maxY = math.Min(minY, 0), math.Max(maxY, 0)
+	}
+	if minY == maxY {
+		minY, maxY = minY-1, maxY+1
+	}
+	yPos := func(y float64) float64 {
+		return plot.Y + plot.H - (y-minY)/(maxY-minY)*plot.H
+	}
+	for i, v := range []float64{maxY, (minY + maxY) / 2, minY} {
+		geo.YLabels = append(geo.YLabels, chartLabel{X: 2, Y: plot.Y + float64(i)*plot.H/2 - ChartLabelHeight/2, Text: formatTick(v)})
+	}
+
+	n := len(s.Y)
+	labelEvery := int(math.Ceil(float64(n) * ChartMinLabelSpacing / plot.W))
+	if labelEvery < 1 {
+		labelEvery = 1
+	}
+
+	if kind == "bar" {
+		slot := plot.W / float64(n)
+		zero := yPos(0)
+		for i, y := range s.Y {
+			top := math.Min(yPos(y), zero)
+			geo.Bars = append(geo.Bars, chartRect{
+				X: plot.X + float64(i)*slot + slot*0.1,
+				Y: top,
+				W: slot * 0.8,
+				H: math.Abs(yPos(y) - zero),
+			})
+			if i%labelEvery == 0 {
+				geo.XLabels = append(geo.XLabels, chartLabel{X: plot.X + float64(i)*slot, Y: plot.Y + plot.H + 2, Text: s.Labels[i]})
+			}
+		}
+		return geo
+	}
+
+	minX, maxX := s.X[0], s.X[0]
+	for _, x := range s.X {
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+	}
+	if minX == maxX {
+		minX, maxX = minX-1, maxX+1
+	}
+	xPos := func(x float64) float64 {
+		return plot.X + (x-minX)/(maxX-minX)*plot.W
+	}
+	for i := range s.Y {
+		geo.Points = append(geo.Points, chartPoint{X: xPos(s.X[i]), Y: yPos(s.Y[i])})
+	}
+	if s.NumericX {
+		for i, v := range []float64{minX, (minX + maxX) / 2, maxX} {
+			geo.XLabels = append(geo.XLabels, chartLabel{X: plot.X + float64(i)*plot.W/2 - 10, Y: plot.Y + plot.H + 2, Text: formatTick(v)})
+		}
+	} else {
+		for i := 0; i < n; i += labelEvery {
+			geo.XLabels = append(geo.XLabels, chartLabel{X: xPos(s.X[i]), Y: plot.Y + plot.H + 2, Text: s.Labels[i]})
+		}
+	}
+	return geo
+}
+
+// formatTick formats an axis value compactly
+func formatTick(v float64) string {
+	if math.Abs(v) >= 1e6 || (v != 0 && math.Abs(v) < 1e-3) {
+		return strconv.FormatFloat(v, 'g', 3, 64)
+	}
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// chartArea returns the world rectangle the chart is drawn in, below the settings form
+func (c *Card) chartArea(g *Game) chartRect {
+	x, y := c.formOrigin()
+	y += float64(len(c.formFields(g)))*FormRowHeight + CardPaddingY
+	return chartRect{X: x, Y: y, W: c.X + c.Width - CardPaddingX - x, H: c.Y + c.Height - CardPaddingY - y}
+}
+
+func (c *Card) chartFields(g *Game) []formField {
+	columns := g.inputColumns(c, "table")
+	x := fmt.Sprint(c.Param("x"))
+	if x == "" {
+		x = transform.None
+	}
+	y := fmt.Sprint(c.Param("y"))
+	if y == "" {
+		y = transform.None
+	}
+	set := func(name string) func(string) {
+		return func(v string) { c.setParams(map[string]interface{}{name: v}) }
+	}
+	return []formField{
+		{Label: "Chart", Value: fmt.Sprint(c.Param("kind")), Options: ChartKinds, Set: set("kind")},
+		columnField("X", x, columns, true, set("x")),
+		columnField("Y", y, columns, false, set("y")),
+		{Label: "Export PNG", Action: "export_png"},
+	}
+}
+
+func (c *Card) drawChart(screen *ebiten.Image, g *Game, cw, ch float64) {
+	if c.chart == nil {
+		return
+	}
+	zoom := g.camera.Zoom
+	area := c.chartArea(g)
+	geo := layoutChart(c.chart, fmt.Sprint(c.Param("kind")), area.W, area.H)
+	toScreen := func(x, y float64) (float32, float32) {
+		sx, sy := g.camera.WorldToScreen(area.X+x, area.Y+y, cw, ch)
+		return float32(sx), float32(sy)
+	}
+
+	// Axes
+	ox, oy := toScreen(geo.Plot.X, geo.Plot.Y+geo.Plot.H)
+	tx, ty := toScreen(geo.Plot.X, geo.Plot.Y)
+	ex, _ := toScreen(geo.Plot.X+geo.Plot.W, 0)
+	vector.StrokeLine(screen, ox, oy, tx, ty, 1, ColorChartAxis, false)
+	vector.StrokeLine(screen, ox, oy, ex, oy, 1, ColorChartAxis, false)
+
+	for _, b := range geo.Bars {
+		bx, by := toScreen(b.X, b.Y)
+		vector.DrawFilledRect(screen, bx, by, float32(b.W*zoom), float32(b.H*zoom), ColorChartMark, false)
+	}
+	for i, p := range geo.Points {
+		px, py := toScreen(p.X, p.Y)
+		if geo.Line && i > 0 {
+			qx, qy := toScreen(geo.Points[i-1].X, geo.Points[i-1].Y)
+			vector.StrokeLine(screen, qx, qy, px, py, float32(2*zoom), ColorChartMark, true)
+		} else if !geo.Line {
+			vector.DrawFilledCircle(screen, px, py, float32(3*zoom), ColorChartMark, true)
+		}
+	}
+
+	for _, l := range append(geo.XLabels, geo.YLabels...) {
+		lx, ly := toScreen(l.X, l.Y)
+		DrawTextLines(screen, g.FontFace, l.Text, int(lx), int(ly), ColorPortLabel)
+	}
+}
+
+// renderChart draws a chart into an image of w x h pixels
+func renderChart(s *chartSeries, kind string, w, h int, face font.Face) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(ColorCardDefault), image.Point{}, draw.Src)
+	geo := layoutChart(s, kind, float64(w), float64(h))
+
+	plot := geo.Plot
+	fillRect(img, plot.X, plot.Y, 1, plot.H, ColorChartAxis)
+	fillRect(img, plot.X, plot.Y+plot.H, plot.W, 1, ColorChartAxis)
+	for _, b := range geo.Bars {
+		fillRect(img, b.X, b.Y, b.W, b.H, ColorChartMark)
+	}
+	for i, p := range geo.Points {
+		if geo.Line && i > 0 {
+			drawLine(img, geo.Points[i-1], p, ColorChartMark)
+		} else if !geo.Line {
+			fillRect(img, p.X-2, p.Y-2, 5, 5, ColorChartMark)
+		}
+	}
+
+	if face == nil {
+		face = basicfont.Face7x13
+	}
+	ascent := face.Metrics().Ascent
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(ColorPortLabel), Face: face}
+	for _, l := range append(geo.XLabels, geo.YLabels...) {
+		d.Dot = fixed.Point26_6{X: fixed.I(int(l.X)), Y: fixed.I(int(l.Y)) + ascent}
+		d.DrawString(l.Text)
+	}
+	return img
+}
+
+func fillRect(img *image.RGBA, x, y, w, h float64, clr color.Color) {
+	r := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+	draw.Draw(img, r, image.NewUniform(clr), image.Point{}, draw.Over)
+}
+
+// drawLine draws a two pixel wide line by stepping along its longer axis
+func drawLine(img *image.RGBA, a, b chartPoint, clr color.Color) {
+	steps := int(math.Max(math.Abs(b.X-a.X), math.Abs(b.Y-a.Y)))
+	for i := 0; i <= steps; i++ {
+		t := 0.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+		fillRect(img, a.X+(b.X-a.X)*t, a.Y+(b.Y-a.Y)*t, 2, 2, clr)
+	}
+}
+
+// ExportChartPNG writes the chart card's current chart to a PNG file
+func (g *Game) ExportChartPNG(c *Card, filename string) error {
+	if c.chart == nil {
+		return fmt.Errorf("the chart has no data to export")
+	}
+	area := c.chartArea(g)
+	img := renderChart(c.chart, fmt.Sprint(c.Param("kind")), int(area.W*ChartExportScale), int(area.H*ChartExportScale), g.FontFace)
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	// Closing flushes the file, so its error means the PNG may be incomplete
+	return f.Close()
+}
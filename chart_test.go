@@ -0,0 +1,108 @@
+package main
+
+import (
+	"image/png"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestChartLayoutBars(t *testing.T) {
+	s := &chartSeries{Labels: []string{"a", "b", "c"}, X: []float64{1, 2, 3}, Y: []float64{10, 5, -5}}
+	geo := layoutChart(s, "bar", 300, 200)
+
+	if len(geo.Bars) != 3 {
+		t.Fatalf("Expected 3 bars, got %d", len(geo.Bars))
+	}
+	// Heights are proportional to the values and negative bars hang below zero
+	if math.Abs(geo.Bars[0].H-2*geo.Bars[1].H) > 1e-9 || math.Abs(geo.Bars[1].H-geo.Bars[2].H) > 1e-9 {
+		t.Errorf("Expected bar heights in ratio 2:1:1, got %v", geo.Bars)
+	}
+	zero := geo.Bars[1].Y + geo.Bars[1].H
+	if geo.Bars[2].Y != zero {
+		t.Errorf("Expected the negative bar to start at the zero line %v, got %v", zero, geo.Bars[2].Y)
+	}
+	for _, b := range geo.Bars {
+		if b.X < geo.Plot.X || b.X+b.W > geo.Plot.X+geo.Plot.W+1e-9 {
+			t.Errorf("Bar %v outside plot %v", b, geo.Plot)
+		}
+	}
+	if len(geo.XLabels) != 3 || geo.XLabels[2].Text != "c" {
+		t.Errorf("Expected a label per bar, got %v", geo.XLabels)
+	}
+	if geo.YLabels[0].Text != "10" || geo.YLabels[2].Text != "-5" {
+		t.Errorf("Expected y labels from 10 to -5, got %v", geo.YLabels)
+	}
+}
+
+func TestChartLayoutScalesWithSize(t *testing.T) {
+	s := &chartSeries{Labels: []string{"1", "2", "3"}, X: []float64{0, 5, 10}, Y: []float64{0, 1, 2}, NumericX: true}
+	small := layoutChart(s, "line", 200, 100)
+	large := layoutChart(s, "line", 400, 200)
+
+	if !small.Line || len(small.Points) != 3 {
+		t.Fatalf("Expected a line through 3 points, got %+v", small)
+	}
+	last := small.Points[2]
+	if last.X != small.Plot.X+small.Plot.W || last.Y != small.Plot.Y {
+		t.Errorf("Expected the last point at the top right of the plot, got %v", last)
+	}
+	if large.Plot.W <= small.Plot.W || large.Points[1].X <= small.Points[1].X {
+		t.Errorf("Expected the layout to grow with the card, got %v and %v", small.Plot, large.Plot)
+	}
+	if small.XLabels[1].Text != "5" {
+		t.Errorf("Expected numeric x ticks, got %v", small.XLabels)
+	}
+}
+
+func TestChartLayoutThinsLabels(t *testing.T) {
+	s := &chartSeries{}
+	for i := 0; i < 100; i++ {
+		s.Labels = append(s.Labels, "x")
+		s.X = append(s.X, float64(i))
+		s.Y = append(s.Y, float64(i))
+	}
+	geo := layoutChart(s, "bar", 300, 200)
+	if len(geo.XLabels) >= 10 {
+		t.Errorf("Expected labels to be thinned out, got %d", len(geo.XLabels))
+	}
+}
+
+func TestChartCard(t *testing.T) {
+	g, cc := newTableFlow(func(g *Game) *Card { return g.AddChartCard(100, 400) })
+
+	g.engine.Run()
+	if !strings.Contains(cc.LastError, "choose a column for the y axis") {
+		t.Errorf("Expected a missing y column error, got %q", cc.LastError)
+	}
+
+	cc.setParams(map[string]interface{}{"kind": "scatter", "x": "region", "y": "total"})
+	g.engine.Run()
+	if cc.LastError != "" {
+		t.Fatalf("Unexpected error: %s", cc.LastError)
+	}
+	// The blank total is skipped and text x values become categories
+	if cc.chart == nil || len(cc.chart.Y) != 4 || cc.chart.NumericX {
+		t.Fatalf("Unexpected series %+v", cc.chart)
+	}
+
+	filename := "test_chart.png"
+	defer os.Remove(filename)
+	if err := g.ExportChartPNG(cc, filename); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open PNG: %v", err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("Invalid PNG: %v", err)
+	}
+	area := cc.chartArea(g)
+	if b := img.Bounds(); b.Dx() != int(area.W*ChartExportScale) || b.Dy() != int(area.H*ChartExportScale) {
+		t.Errorf("Unexpected PNG size %v for chart area %v", b, area)
+	}
+}
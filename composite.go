@@ -0,0 +1,478 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"card-flows/canvas"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"gopkg.in/yaml.v3"
+)
+
+// subflowInputID stands in for the source card of arrows carrying a composite
+// card's inputs into its subflow
+const subflowInputID = "$input"
+
+// chooseType is the value shown by the composite type field before a type is loaded
+const chooseType = "(choose)"
+
+// Subflow is the flow inside a composite card. Its input ports feed the arrows
+// starting at subflowInputID and each output port reads one inner card's output.
+type Subflow struct {
+	Cards   []*Card
+	Arrows  []*Arrow
+	Frames  []*Frame
+	Outputs []SubflowPort
+
+	engine *Engine // runs the subflow; its game is the canvas while the subflow is open
+	open   bool
+}
+
+// SubflowPort connects a composite output port to the inner port it reads from
+type SubflowPort struct {
+	Name   string
+	CardID string
+	Port   string
+}
+
+type SubflowPortState struct {
+	Name   string `yaml:"name"`
+	CardID string `yaml:"card_id"`
+	Port   string `yaml:"port"`
+}
+
+type SubflowState struct {
+	Cards   []CardState        `yaml:"cards"`
+	Arrows  []ArrowState       `yaml:"arrows"`
+	Frames  []FrameState       `yaml:"frames,omitempty"`
+	Outputs []SubflowPortState `yaml:"outputs"`
+}
+
+// CompositeType is a composite card saved to the library for reuse
+type CompositeType struct {
+	Name    string       `yaml:"name"`
+	Inputs  []PortState  `yaml:"inputs"`
+	Outputs []PortState  `yaml:"outputs"`
+	Subflow SubflowState `yaml:"subflow"`
+}
+
+// flowView is a flow left behind when a composite card is opened
+type flowView struct {
+	composite *Card // the card that was opened
+	cards     []*Card
+	arrows    []*Arrow
+	frames    []*Frame
+	engine    *Engine
+	camera    canvas.Camera
+}
+
+func subflowState(s *Subflow) SubflowState {
+	state := SubflowState{
+		Cards:  cardStates(s.Cards),
+		Arrows: arrowStates(s.Arrows),
+		Frames: frameStates(s.Frames),
+	}
+	for _, p := range s.Outputs {
+		state.Outputs = append(state.Outputs, SubflowPortState{Name: p.Name, CardID: p.CardID, Port: p.Port})
+	}
+	return state
+}
+
+func subflowFromState(state SubflowState) *Subflow {
+	s := &Subflow{Cards: cardsFromStates(state.Cards), Frames: framesFromStates(state.Frames)}
+	s.Arrows = arrowsFromStates(state.Arrows, s.Cards)
+	for _, p := range state.Outputs {
+		s.Outputs = append(s.Outputs, SubflowPort{Name: p.Name, CardID: p.CardID, Port: p.Port})
+	}
+	return s
+}
+
+// clone returns a deep copy of the subflow that runs separately
+func (s *Subflow) clone() *Subflow {
+	return subflowFromState(subflowState(s))
+}
+
+// Engine returns the engine running the subflow, creating it on first use
+func (s *Subflow) Engine() *Engine {
+	if s.engine == nil {
+		s.engine = NewEngine(&Game{})
+	}
+	if !s.open {
+		s.engine.game.cards, s.engine.game.arrows, s.engine.game.frames = s.Cards, s.Arrows, s.Frames
+	}
+	return s.engine
+}
+
+func (g *Game) AddCompositeCard(x, y float64) *Card {
+	card := &Card{
+		ID:      NewID(),
+		Type:    "composite",
+		X:       math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:       math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:   DefaultCardWidth * 1.5,
+		Height:  DefaultCardHeight * 1.5,
+		Color:   ColorCardComposite,
+		Title:   "Group",
+		Params:  map[string]interface{}{},
+		Subflow: &Subflow{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// GroupCards replaces the cards with one composite card running them as a subflow.
+// Wires crossing into the group become its input ports and wires leaving it become
+// its output ports, so the rest of the flow is unchanged.
+func (g *Game) GroupCards(cards []*Card) *Card {
+	inside := map[string]*Card{}
+	minX, minY := math.Inf(1), math.Inf(1)
+	for _, c := range cards {
+		inside[c.ID] = c
+		minX, minY = math.Min(minX, c.X), math.Min(minY, c.Y)
+	}
+	comp := g.AddCompositeCard(minX, minY)
+	sub := comp.Subflow
+	sub.Cards = cards
+
+	inputs := map[string]string{} // "cardID:port" of an inner input -> composite input port
+	outputs := map[string]string{}
+	used := map[string]bool{}
+	var outer []*Arrow
+	for _, a := range g.arrows {
+		from, to := inside[a.FromCardID], inside[a.ToCardID]
+		switch {
+		case from != nil && to != nil:
+			sub.Arrows = append(sub.Arrows, a)
+		case to != nil:
+			key := a.ToCardID + ":" + a.ToPort
+			name, ok := inputs[key]
+			if !ok {
+				name = uniquePortName(a.ToPort, used)
+				inputs[key] = name
+				comp.Inputs = append(comp.Inputs, Port{Name: name, Type: portType(to.Inputs, a.ToPort)})
+				sub.Arrows = append(sub.Arrows, &Arrow{FromCardID: subflowInputID, FromPort: name, ToCardID: a.ToCardID, ToPort: a.ToPort, Color: ColorArrowDefault})
+			}
+			g.UnregisterSubscription(a.FromCardID, a.ToCardID, a.ToPort)
+			a.ToCardID, a.ToPort = comp.ID, name
+			g.RegisterSubscription(a.FromCardID, a.ToCardID, a.ToPort)
+			outer = append(outer, a)
+		case from != nil:
+			key := a.FromCardID + ":" + a.FromPort
+			name, ok := outputs[key]
+			if !ok {
+				name = uniquePortName(a.FromPort, used)
+				outputs[key] = name
+				comp.Outputs = append(comp.Outputs, Port{Name: name, Type: portType(from.Outputs, a.FromPort)})
+				sub.Outputs = append(sub.Outputs, SubflowPort{Name: name, CardID: a.FromCardID, Port: a.FromPort})
+			}
+			g.UnregisterSubscription(a.FromCardID, a.ToCardID, a.ToPort)
+			a.FromCardID, a.FromPort = comp.ID, name
+			g.RegisterSubscription(a.FromCardID, a.ToCardID, a.ToPort)
+			outer = append(outer, a)
+		default:
+			outer = append(outer, a)
+		}
+	}
+	g.arrows = outer
+	g.cards = slices.DeleteFunc(g.cards, func(c *Card) bool { return inside[c.ID] != nil })
+	// Notes pinned across the group's edge pin to the composite, or come loose inside it
+	for _, c := range g.cards {
+		if inside[c.AttachedTo] != nil {
+			c.AttachedTo = comp.ID
+		}
+	}
+	for _, c := range cards {
+		if c.AttachedTo != "" && inside[c.AttachedTo] == nil {
+			c.AttachedTo = ""
+		}
+	}
+	comp.Height = math.Max(comp.Height, HeaderHeight+FooterHeight+float64(max(len(comp.Inputs), 4))*FormRowHeight+2*CardPaddingY)
+	g.selection = nil
+	return comp
+}
+
+// uniquePortName returns name, or name with a number when it is already used
+func uniquePortName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+func portType(ports []Port, name string) string {
+	for _, p := range ports {
+		if p.Name == name {
+			return p.Type
+		}
+	}
+	return "any"
+}
+
+// cardText returns the text a text card outputs: its own, or inside a composite
+// the value wired into it from the composite's inputs in this run
+func (e *Engine) cardText(c *Card) string {
+	for _, a := range e.game.arrows {
+		if a.ToCardID == c.ID && a.FromCardID == subflowInputID {
+			if v, ok := e.Memory[subflowInputID+":"+a.FromPort]; ok {
+				return fmt.Sprint(v)
+			}
+		}
+	}
+	return c.Text
+}
+
+// executeComposite runs the subflow with the card's inputs and returns the inner
+// outputs its ports expose. An error in any inner card fails the composite.
+func executeComposite(c *Card, inputs map[string]interface{}) (map[string]interface{}, error) {
+	sub := c.Subflow
+	if sub == nil {
+		return nil, fmt.Errorf("the composite has no inner flow")
+	}
+	child := sub.Engine()
+	for _, p := range c.Inputs {
+		key := subflowInputID + ":" + p.Name
+		v, ok := inputs[p.Name]
+		if !ok {
+			delete(child.Memory, key)
+			continue
+		}
+		child.Memory[key] = v
+	}
+
+	child.vars = c.vars
+	child.Run()
+	for _, ic := range child.game.cards {
+		if ic.LastError != "" {
+			return nil, fmt.Errorf("%s: %s", ic.Title, ic.LastError)
+		}
+	}
+	outputs := map[string]interface{}{}
+	for _, p := range sub.Outputs {
+		outputs[p.Name] = child.Memory[p.CardID+":"+p.Port]
+	}
+	return outputs, nil
+}
+
+// OpenCard opens a composite card's subflow on the canvas. It returns false for
+// other cards, whose text is edited on double-click instead.
+func (g *Game) OpenCard(card interface{}) bool {
+	c, ok := card.(*Card)
+	if !ok || c.Subflow == nil {
+		return false
+	}
+	g.OpenComposite(c)
+	return true
+}
+
+// OpenComposite shows the subflow of a composite card in place of the current flow
+func (g *Game) OpenComposite(c *Card) {
+	g.views = append(g.views, flowView{composite: c, cards: g.cards, arrows: g.arrows, frames: g.frames, engine: g.engine, camera: g.camera})
+	// The outer engine keeps running the flow it belongs to
+	g.engine.game = &Game{cards: g.cards, arrows: g.arrows, frames: g.frames, variables: g.variables}
+
+	sub := c.Subflow
+	child := sub.Engine()
+	sub.open = true
+	child.game = g
+	g.cards, g.arrows, g.frames, g.engine = sub.Cards, sub.Arrows, sub.Frames, child
+	g.selection = nil
+}
+
+// CloseComposite returns to the flow containing the open composite card
+func (g *Game) CloseComposite() {
+	if len(g.views) == 0 {
+		return
+	}
+	v := g.views[len(g.views)-1]
+	g.views = g.views[:len(g.views)-1]
+
+	sub := v.composite.Subflow
+	sub.Cards, sub.Arrows, sub.Frames = g.cards, g.arrows, g.frames
+	sub.open = false
+	g.engine.game = &Game{}
+	sub.Engine()
+
+	v.engine.game = g
+	g.cards, g.arrows, g.frames, g.engine, g.camera = v.cards, v.arrows, v.frames, v.engine, v.camera
+	g.selection = nil
+	g.inspector.Select(v.composite)
+	g.RunEngine()
+}
+
+// rootFlow returns the top-level flow, first writing the open subflows back to
+// their composite cards
+func (g *Game) rootFlow() ([]*Card, []*Arrow, []*Frame) {
+	cards, arrows, frames := g.cards, g.arrows, g.frames
+	for i := len(g.views) - 1; i >= 0; i-- {
+		v := g.views[i]
+		sub := v.composite.Subflow
+		sub.Cards, sub.Arrows, sub.Frames = cards, arrows, frames
+		cards, arrows, frames = v.cards, v.arrows, v.frames
+	}
+	return cards, arrows, frames
+}
+
+// rootEngine returns the engine of the top-level flow
+func (g *Game) rootEngine() *Engine {
+	if len(g.views) > 0 {
+		return g.views[0].engine
+	}
+	return g.engine
+}
+
+// ToggleSelected adds a card to the selection grouped by Ctrl+G, or removes it.
+// A nil card clears the selection.
+func (g *Game) ToggleSelected(card interface{}) {
+	c, ok := card.(*Card)
+	if !ok || c == nil {
+		g.selection = nil
+		return
+	}
+	if i := slices.Index(g.selection, c); i >= 0 {
+		g.selection = slices.Delete(g.selection, i, i+1)
+	} else {
+		g.selection = append(g.selection, c)
+	}
+}
+
+func (g *Game) isSelected(c *Card) bool {
+	return slices.Contains(g.selection, c)
+}
+
+// updateComposites groups the selected cards with Ctrl+G and leaves an open
+// composite with Escape
+func (g *Game) updateComposites() {
+	if g.input.EditingCard != nil {
+		return
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyG) {
+		if len(g.selection) > 0 {
+			g.GroupCards(g.selection)
+		} else {
+			// An empty group to load a saved type into
+			mx, my := ebiten.CursorPosition()
+			g.AddCompositeCard(g.screenToWorld(float64(mx), float64(my)))
+		}
+		g.RunEngine()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) && len(g.views) > 0 {
+		g.CloseComposite()
+	}
+}
+
+// drawBreadcrumb shows which composite is open and how to leave it
+func (g *Game) drawBreadcrumb(screen *ebiten.Image) {
+	if len(g.views) == 0 {
+		return
+	}
+	path := []string{"Flow"}
+	for _, v := range g.views {
+		path = append(path, v.composite.Title)
+	}
+	DrawTextLines(screen, g.FontFace, strings.Join(path, " > ")+"   (Esc to go back)", g.screenWidth/2-150, 10, color.White)
+}
+
+// compositeTypeFile returns the library file of a composite type name
+func compositeTypeFile(name string) string {
+	slug := strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		slug = "group"
+	}
+	return filepath.Join(CompositeLibraryDir, slug+".yaml")
+}
+
+// SaveCompositeType stores a composite card in the library under its title
+func SaveCompositeType(c *Card) error {
+	t := CompositeType{Name: c.Title, Subflow: subflowState(c.Subflow)}
+	for _, p := range c.Inputs {
+		t.Inputs = append(t.Inputs, PortState{Name: p.Name, Type: p.Type})
+	}
+	for _, p := range c.Outputs {
+		t.Outputs = append(t.Outputs, PortState{Name: p.Name, Type: p.Type})
+	}
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(CompositeLibraryDir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(compositeTypeFile(c.Title), data, 0o644)
+}
+
+// CompositeTypes returns the names of the composite types in the library
+func CompositeTypes() []string {
+	files, _ := filepath.Glob(filepath.Join(CompositeLibraryDir, "*.yaml"))
+	var names []string
+	for _, f := range files {
+		if t, err := readCompositeType(f); err == nil {
+			names = append(names, t.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readCompositeType(path string) (*CompositeType, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t CompositeType
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return &t, nil
+}
+
+// LoadCompositeType replaces the card's subflow and ports with a library type.
+// Wires to ports the type does not have are removed.
+func (g *Game) LoadCompositeType(c *Card, name string) error {
+	t, err := readCompositeType(compositeTypeFile(name))
+	if err != nil {
+		return err
+	}
+	c.Title = t.Name
+	c.Subflow = subflowFromState(t.Subflow)
+	c.Inputs, c.Outputs = nil, nil
+	for _, p := range t.Inputs {
+		c.Inputs = append(c.Inputs, Port{Name: p.Name, Type: p.Type})
+	}
+	for _, p := range t.Outputs {
+		c.Outputs = append(c.Outputs, Port{Name: p.Name, Type: p.Type})
+	}
+	g.removeStaleArrows(c)
+	c.setParams(map[string]interface{}{"type": t.Name})
+	return nil
+}
+
+func (c *Card) compositeFields(g *Game) []formField {
+	current := fmt.Sprint(c.Param("type"))
+	if current == "" {
+		current = chooseType
+	}
+	return []formField{
+		{Label: "Name", Value: c.Title, Edit: true, Set: func(v string) { c.Title = v }},
+		{Label: "Type", Value: current, Options: append([]string{chooseType}, CompositeTypes()...), Set: func(v string) {
+			if v == chooseType {
+				return
+			}
+			if err := g.LoadCompositeType(c, v); err != nil {
+				c.LastError = err.Error()
+			}
+		}},
+		{Label: "Save as type", Action: "save_composite_type"},
+		{Label: "Open", Action: "open_composite"},
+	}
+}
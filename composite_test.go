@@ -0,0 +1,178 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// groupedFindReplace builds text -> find_replace -> text and groups the
+// find_replace card with its find and replace text cards
+func groupedFindReplace(t *testing.T) (*Game, *Card, *Card) {
+	t.Helper()
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	input := g.AddTextCard(100, 100)
+	input.Text = "Hello World"
+	fr := g.AddFindReplaceCard(400, 100)
+	find := g.AddTextCard(400, 300)
+	find.Text = "World"
+	replace := g.AddTextCard(400, 500)
+	replace.Text = "Universe"
+	output := g.AddTextCard(800, 100)
+	for _, a := range []*Arrow{
+		{FromCardID: input.ID, FromPort: "text", ToCardID: fr.ID, ToPort: "input"},
+		{FromCardID: find.ID, FromPort: "text", ToCardID: fr.ID, ToPort: "find"},
+		{FromCardID: replace.ID, FromPort: "text", ToCardID: fr.ID, ToPort: "replace"},
+		{FromCardID: fr.ID, FromPort: "result", ToCardID: output.ID, ToPort: "text"},
+	} {
+		a.Color = ColorArrowDefault
+		g.arrows = append(g.arrows, a)
+	}
+	return g, g.GroupCards([]*Card{fr, find, replace}), input
+}
+
+func TestGroupCardsKeepsWiring(t *testing.T) {
+	g, comp, input := groupedFindReplace(t)
+
+	if len(g.cards) != 3 || len(comp.Subflow.Cards) != 3 {
+		t.Fatalf("Expected 3 cards outside and 3 inside, got %d and %d", len(g.cards), len(comp.Subflow.Cards))
+	}
+	if len(comp.Inputs) != 1 || comp.Inputs[0] != (Port{Name: "input", Type: "string"}) {
+		t.Errorf("Expected one string input port, got %+v", comp.Inputs)
+	}
+	if len(comp.Outputs) != 1 || comp.Outputs[0].Name != "result" {
+		t.Errorf("Expected one result output port, got %+v", comp.Outputs)
+	}
+	if len(g.arrows) != 2 || g.arrows[0].FromCardID != input.ID || g.arrows[0].ToCardID != comp.ID ||
+		g.arrows[1].FromCardID != comp.ID {
+		t.Errorf("Expected the outer wires to connect to the composite, got %+v", g.arrows)
+	}
+
+	g.engine.Run()
+	if comp.LastError != "" {
+		t.Fatal(comp.LastError)
+	}
+	if got := g.engine.Memory[comp.ID+":result"]; got != "Hello Universe" {
+		t.Errorf("Expected the composite to output %q, got %v", "Hello Universe", got)
+	}
+
+	// Changing the outer input reruns the inner flow
+	input.Text = "World peace"
+	g.engine.Run()
+	if got := g.engine.Memory[comp.ID+":result"]; got != "Universe peace" {
+		t.Errorf("Expected %q after the input changed, got %v", "Universe peace", got)
+	}
+}
+
+func TestGroupCardsMovesSubscriptionsAndNotes(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	input := g.AddTextCard(100, 100)
+	input.Text = "Hello"
+	inner := g.AddTextCard(400, 100)
+	output := g.AddTextCard(800, 100)
+	g.Connect(input, "text", inner, "text")
+	g.Connect(inner, "text", output, "text")
+	outsideNote := g.AddNoteCard(0, 0)
+	outsideNote.AttachedTo = inner.ID
+	insideNote := g.AddNoteCard(0, 0)
+	insideNote.AttachedTo = input.ID
+
+	comp := g.GroupCards([]*Card{inner, insideNote})
+	if want := []Subscription{{CardID: comp.ID, Port: "text"}}; !slices.Equal(input.Subscribers, want) {
+		t.Errorf("Expected the input to feed the composite, got %+v", input.Subscribers)
+	}
+	if want := []Subscription{{CardID: output.ID, Port: "text"}}; !slices.Equal(comp.Subscribers, want) || len(inner.Subscribers) != 0 {
+		t.Errorf("Expected the composite to feed the output, got %+v and inner %+v", comp.Subscribers, inner.Subscribers)
+	}
+	if outsideNote.AttachedTo != comp.ID || insideNote.AttachedTo != "" {
+		t.Errorf("Expected the notes to pin to the composite and come loose, got %q and %q", outsideNote.AttachedTo, insideNote.AttachedTo)
+	}
+
+	// The wired value passes through the inner text card without changing it
+	inner.Text = "saved"
+	g.engine.Run()
+	if got := g.engine.Memory[comp.ID+":"+comp.Outputs[0].Name]; got != "Hello" || inner.Text != "saved" {
+		t.Errorf("Expected the composite to output the input, got %v and inner text %q", got, inner.Text)
+	}
+}
+
+func TestCompositeSaveAndLibrary(t *testing.T) {
+	g, comp, _ := groupedFindReplace(t)
+	comp.Title = "Greeting"
+
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	if err := SaveState(g, path); err != nil {
+		t.Fatal(err)
+	}
+	g2 := NewGame()
+	if err := LoadState(g2, path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := g2.getCardByID(comp.ID)
+	if loaded == nil || loaded.Subflow == nil || len(loaded.Subflow.Cards) != 3 || len(loaded.Subflow.Outputs) != 1 {
+		t.Fatalf("Expected the inner flow to be saved, got %+v", loaded)
+	}
+	g2.engine.Run()
+	if got := g2.engine.Memory[comp.ID+":result"]; got != "Hello Universe" {
+		t.Errorf("Expected the loaded composite to run, got %v", got)
+	}
+
+	t.Chdir(t.TempDir())
+	if err := SaveCompositeType(comp); err != nil {
+		t.Fatal(err)
+	}
+	if types := CompositeTypes(); len(types) != 1 || types[0] != "Greeting" {
+		t.Fatalf("Expected the type in the library, got %v", types)
+	}
+	empty := g.AddCompositeCard(0, 600)
+	if err := g.LoadCompositeType(empty, "Greeting"); err != nil {
+		t.Fatal(err)
+	}
+	if len(empty.Inputs) != 1 || len(empty.Subflow.Cards) != 3 || empty.Subflow.Cards[0] == comp.Subflow.Cards[0] {
+		t.Errorf("Expected a separate copy of the saved type, got %+v", empty.Subflow)
+	}
+}
+
+func TestOpenAndCloseComposite(t *testing.T) {
+	g, comp, _ := groupedFindReplace(t)
+	g.engine.Run()
+	outer := g.cards
+
+	g.OpenComposite(comp)
+	if len(g.cards) != 3 || g.cards[0] != comp.Subflow.Cards[0] {
+		t.Fatalf("Expected the inner cards on the canvas")
+	}
+	// Editing inside reruns the outer flow
+	for _, c := range g.cards {
+		if c.Text == "Universe" {
+			c.Text = "Gophers"
+		}
+	}
+	g.RunEngine()
+	if got := g.rootEngine().Memory[comp.ID+":result"]; got != "Hello Gophers" {
+		t.Errorf("Expected the edit to reach the outer flow, got %v", got)
+	}
+
+	// Saving while inside stores the whole flow
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	if err := SaveState(g, path); err != nil {
+		t.Fatal(err)
+	}
+
+	g.CloseComposite()
+	if len(g.views) != 0 || len(g.cards) != len(outer) || g.cards[0] != outer[0] {
+		t.Fatalf("Expected to be back in the outer flow")
+	}
+
+	g2 := NewGame()
+	if err := LoadState(g2, path); err != nil {
+		t.Fatal(err)
+	}
+	if len(g2.cards) != 3 || g2.getCardByID(comp.ID) == nil {
+		t.Errorf("Expected the top-level flow to be saved, got %d cards", len(g2.cards))
+	}
+}
@@ -26,6 +26,9 @@ const (
 	HeaderHeight      = 50.0
 	FooterHeight      = 30.0
 	PortSize          = 10.0
+	PortButtonSize    = 14.0 // "+" adding an input port
+	PortLabelWidth    = 80.0 // clickable width of an input port label
+	PortRowHeight     = 20.0
 	ShadowOffset      = 5.0
 	BorderThickness   = 3.0
 	BorderOffset      = 2.0
@@ -33,12 +36,76 @@ const (
 	CornerThreshold   = 15.0
 	CardPaddingX      = 10.0
 	CardPaddingY      = 8.0
+	TextLineHeight    = 18.0
 
 	CardActionButtonWidth  = 30.0
 	CardActionButtonHeight = 20.0
 	DuplicateOffset        = 20.0
 	// PortPanelWidth is now calculated as 1/3 of card width (not a constant)
 
+	// --- Grid Cards ---
+	GridCellWidth          = 80.0
+	GridCellHeight         = 22.0
+	GridPadding            = 4.0
+	GridToolbarHeight      = 20.0
+	GridToolbarButtonWidth = 28.0
+
+	// --- Form Cards ---
+	FormRowHeight = 22.0
+
+	// --- Chart Cards ---
+	ChartPadding          = 8.0
+	ChartAxisMarginLeft   = 44.0
+	ChartAxisMarginBottom = 20.0
+	ChartLabelHeight      = 14.0
+	ChartMinLabelSpacing  = 50.0 // minimum distance between x axis labels
+	ChartExportScale      = 2.0
+
+	// --- Markdown ---
+	MarkdownFontSize    = 14.0
+	MarkdownBlockGap    = 6.0
+	MarkdownIndent      = 16.0
+	MarkdownCellPadding = 4.0
+
+	// --- Frames ---
+	FrameDefaultWidth  = 600.0
+	FrameDefaultHeight = 400.0
+	FrameMinSize       = 100.0
+	FrameTitleHeight   = 28.0
+	FrameButtonWidth   = 36.0
+	FrameGripSize      = 14.0
+	FrameBodyAlpha     = 40
+	FrameTitleAlpha    = 160
+
+	// --- Notes ---
+	NotePinRadius = 5.0
+
+	// --- Composites ---
+	CompositeLibraryDir = "composites" // saved composite types, one yaml file each
+
+	// --- Ghost Previews ---
+	GhostPreviewRows     = 3
+	GhostPreviewMaxChars = 40
+	GhostPreviewMinZoom  = 0.6 // previews are hidden when zoomed out further
+
+	// --- Engine ---
+	RunLogLimit       = 200
+	LLMTimeoutSeconds = 30 // longest wait for a language model's answer
+
+	// --- Inspector Panel ---
+	InspectorHeight         = 220.0 // at most half the window
+	InspectorTabHeight      = 22.0
+	InspectorTabWidth       = 90.0
+	InspectorTitleWidth     = 160.0
+	InspectorRowHeight      = 18.0
+	InspectorGutterWidth    = 56.0 // row numbers
+	InspectorColumnWidth    = 100.0
+	InspectorMinColumnWidth = 24.0
+	InspectorResizeGrab     = 4.0 // px either side of a column border
+	InspectorScrollbarWidth = 10.0
+	InspectorMinThumb       = 16.0
+	InspectorWheelRows      = 3
+
 	// --- Input ---
 	DoubleClickThreshold = 500 // ms
 	DoubleClickDistance  = 25  // px squared (5px)
@@ -79,4 +146,26 @@ var (
 	ColorPortHighlight       = color.RGBA{100, 200, 255, 255}
 	ColorPortActive          = color.RGBA{255, 200, 50, 255}
 	ColorPortHover           = color.RGBA{150, 255, 150, 255}
+	ColorErrorText           = color.RGBA{255, 120, 120, 255}
+	ColorGridCell            = color.RGBA{35, 35, 40, 255}
+	ColorGridHeader          = color.RGBA{60, 60, 75, 255}
+	ColorGridLine            = color.RGBA{80, 80, 90, 255}
+	ColorChartAxis           = color.RGBA{180, 180, 190, 255}
+	ColorChartMark           = color.RGBA{100, 149, 237, 255}
+	ColorGhostBackground     = color.RGBA{0, 0, 0, 90}
+	ColorGhostText           = color.RGBA{200, 200, 210, 170}
+	ColorInspectorBackground = color.RGBA{25, 25, 30, 245}
+	ColorInspectorDim        = color.RGBA{150, 150, 160, 255}
+	ColorStatusText          = color.RGBA{170, 200, 170, 255}
+	ColorMarkdownCode        = color.RGBA{25, 25, 30, 255}
+	ColorMarkdownDim         = color.RGBA{140, 140, 150, 255}
+	ColorNoteText            = color.RGBA{50, 45, 30, 255}
+	ColorNotePin             = color.RGBA{220, 60, 60, 255}
+	ColorCardComposite       = color.RGBA{55, 50, 75, 255}
+	ColorCardSelected        = color.RGBA{255, 215, 0, 255}
+	ColorSkippedOverlay      = color.RGBA{20, 20, 25, 150}
+	ColorArrowInactive       = color.RGBA{90, 90, 95, 255}
+
+	// MarkdownHeadingSizes are the font sizes of #, ## and ### headings
+	MarkdownHeadingSizes = [3]float64{22, 18, 16}
 )
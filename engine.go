@@ -1,27 +1,52 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"card-flows/engine"
+	"card-flows/formula"
 	"card-flows/graph"
+	"card-flows/transform"
 )
 
 // CacheEntry stores execution results with metadata
 type CacheEntry struct {
 	InputHash  string
-	Output     interface{}
+	Output     interface{}            // Value of the card's first output port
+	Outputs    map[string]interface{} // Values keyed by output port name
 	ExecutedAt time.Time
 }
 
+// RunLogEntry records a side effect of a run, such as a file written by an export card
+type RunLogEntry struct {
+	Time    time.Time
+	CardID  string
+	Title   string
+	Message string
+	Data    map[string]interface{}
+}
+
+// errPending is returned by cards whose result is still being computed in the background
+var errPending = errors.New("waiting for the result")
+
 // Engine handles the execution of the flow
 type Engine struct {
 	game           *Game
 	Memory         map[string]interface{} // Cache outputs: Key = CardID + InputValuesHash
 	ExecutionCache map[string]CacheEntry  // Cache with metadata
+	RunLog         []RunLogEntry          // Most recent entries last, at most RunLogLimit
+	args           map[string]interface{} // Values for the flow input cards when this flow is called
+	calls          []string               // Flow files being called, outermost first, to detect recursion
+	skipped        map[string]bool        // Cards reached only through a branch not taken in the last run
+	inactive       map[string]bool        // Branch outputs not taken in the last run, keyed CardID:port
+	vars           *runVars               // Flow variables of the run; inner flows get their caller's
+	background     bool                   // Ask models without blocking the canvas; inner flows wait for the answer
 }
 
 func NewEngine(g *Game) *Engine {
@@ -29,6 +54,8 @@ func NewEngine(g *Game) *Engine {
 		game:           g,
 		Memory:         make(map[string]interface{}),
 		ExecutionCache: make(map[string]CacheEntry),
+		skipped:        make(map[string]bool),
+		inactive:       make(map[string]bool),
 	}
 }
 
@@ -42,20 +69,59 @@ func (e *Engine) Run() {
 	}
 
 	// 2. Execute in Order
+	e.resolveVars()
+	clear(e.skipped)
+	clear(e.inactive)
 	for _, card := range order {
 		e.executeCard(card)
 	}
 }
 
+// RunCards runs only the given cards, in dependency order. Inputs from other cards
+// use their results from the last run.
+func (e *Engine) RunCards(cards []*Card) {
+	order, err := e.getExecutionOrder()
+	if err != nil {
+		fmt.Println("Execution Error:", err)
+		return
+	}
+	e.resolveVars()
+	for _, card := range order {
+		if slices.Contains(cards, card) {
+			e.executeCard(card)
+		}
+	}
+}
+
+// resolveVars picks up the flow variables of the current profile for a run
+func (e *Engine) resolveVars() {
+	if e.game.variables != nil {
+		e.vars = e.game.variables.resolve()
+	}
+}
+
 func (e *Engine) getExecutionOrder() ([]*Card, error) {
 	// Build lightweight node/arrow lists for the graph package
+	// Notes are annotations and are never run
 	nodes := []graph.Node{}
+	cards := []*Card{}
 	for _, c := range e.game.cards {
+		if c.IsNote() {
+			continue
+		}
 		nodes = append(nodes, graph.Node{ID: c.ID, X: c.X, Y: c.Y})
+		cards = append(cards, c)
 	}
+	idToCard := make(map[string]*Card)
+	for _, c := range cards {
+		idToCard[c.ID] = c
+	}
+	// Arrows from a composite card's inputs start outside its subflow and don't order it
 	arrows := []graph.Arrow{}
 	for _, a := range e.game.arrows {
-		arrows = append(arrows, graph.Arrow{FromID: a.FromCardID, ToID: a.ToCardID})
+		if idToCard[a.FromCardID] != nil && idToCard[a.ToCardID] != nil {
+			arrows = append(arrows, graph.Arrow{FromID: a.FromCardID, ToID: a.ToCardID})
+		}
 	}
 
 	orderedIDs, err := graph.TopologicalSort(nodes, arrows)
@@ -64,10 +130,6 @@ func (e *Engine) getExecutionOrder() ([]*Card, error) {
 	}
 
 	// Map ordered IDs back to card pointers
-	idToCard := make(map[string]*Card)
-	for _, c := range e.game.cards {
-		idToCard[c.ID] = c
-	}
 	result := []*Card{}
 	for _, id := range orderedIDs {
 		if c, ok := idToCard[id]; ok {
@@ -76,13 +138,13 @@ func (e *Engine) getExecutionOrder() ([]*Card, error) {
 	}
 
 	// Deterministic fallback: ensure all cards included (shouldn't be necessary)
-	if len(result) != len(e.game.cards) {
+	if len(result) != len(cards) {
 		missing := []*Card{}
 		present := make(map[string]bool)
 		for _, c := range result {
 			present[c.ID] = true
 		}
-		for _, c := range e.game.cards {
+		for _, c := range cards {
 			if !present[c.ID] {
 				missing = append(missing, c)
 			}
@@ -100,74 +162,197 @@ func (e *Engine) getExecutionOrder() ([]*Card, error) {
 
 // Note: input hashing moved to the `engine` package (engine.ComputeInputHash).
 
+// incomingArrows returns the arrows carrying values into the card in this run,
+// ordered by the position of their source cards, left to right then top to bottom,
+// so that lists gathered by ports taking many wires follow the canvas
+func (e *Engine) incomingArrows(c *Card) []*Arrow {
+	var arrows []*Arrow
+	for _, a := range e.game.arrows {
+		if a.ToCardID == c.ID && !e.arrowInactive(a) {
+			arrows = append(arrows, a)
+		}
+	}
+	position := func(a *Arrow) (float64, float64) {
+		if src := e.game.getCardByID(a.FromCardID); src != nil {
+			return src.X, src.Y
+		}
+		return math.Inf(-1), math.Inf(-1)
+	}
+	sort.SliceStable(arrows, func(i, j int) bool {
+		xi, yi := position(arrows[i])
+		xj, yj := position(arrows[j])
+		if xi != xj {
+			return xi < xj
+		}
+		return yi < yj
+	})
+	return arrows
+}
+
+// arrowValue returns the value an arrow carries from its source card's output
+func (e *Engine) arrowValue(a *Arrow) (interface{}, bool) {
+	key := fmt.Sprintf("%s:%s", a.FromCardID, a.FromPort)
+	if val, ok := e.Memory[key]; ok {
+		return val, true
+	}
+	// Try to get from source card directly (for text cards)
+	sourceCard := e.game.getCardByID(a.FromCardID)
+	if sourceCard != nil && strings.HasPrefix(sourceCard.Title, "Text Card") {
+		return sourceCard.Text, true
+	}
+	return nil, false
+}
+
 func (e *Engine) executeCard(c *Card) {
+	c.vars = e.vars
+
+	// Cards reachable only through a branch not taken don't run
+	delete(e.skipped, c.ID)
+	for _, p := range c.Outputs {
+		delete(e.inactive, c.ID+":"+p.Name)
+	}
+	if e.skippedByBranch(c) {
+		// Drop the results of the last run so previews don't show them
+		e.skipped[c.ID] = true
+		c.LastError = ""
+		for _, p := range c.Outputs {
+			delete(e.Memory, c.ID+":"+p.Name)
+		}
+		return
+	}
+
 	// 1. Gather Inputs
 	inputs := make(map[string]interface{})
 
-	// Find arrows pointing to this card
-	for _, arrow := range e.game.arrows {
-		if arrow.ToCardID == c.ID {
-			// Get value from source card's output
-			key := fmt.Sprintf("%s:%s", arrow.FromCardID, arrow.FromPort)
-			if val, ok := e.Memory[key]; ok {
-				inputs[arrow.ToPort] = val
-			} else {
-				// Try to get from source card directly (for text cards)
-				sourceCard := e.game.getCardByID(arrow.FromCardID)
-				if sourceCard != nil && strings.HasPrefix(sourceCard.Title, "Text Card") {
-					inputs[arrow.ToPort] = sourceCard.Text
-				}
-			}
+	// Find arrows pointing to this card; ports taking many wires get a list
+	for _, arrow := range e.incomingArrows(c) {
+		val, ok := e.arrowValue(arrow)
+		if !ok {
+			continue
+		}
+		if c.inputPort(arrow.ToPort).Multi {
+			list, _ := inputs[arrow.ToPort].([]interface{})
+			inputs[arrow.ToPort] = append(list, val)
+		} else {
+			inputs[arrow.ToPort] = val
 		}
 	}
 
 	// 2. Check Cache
 	// For text cards, include their text in the cache key since they're "input" nodes
-	cacheInputs := inputs
-	if strings.HasPrefix(c.Title, "Text Card") {
-		cacheInputs = map[string]interface{}{"_text": c.Text}
+	cacheInputs := make(map[string]interface{}, len(inputs))
+	for k, v := range inputs {
+		cacheInputs[k] = v
+	}
+	switch c.Type {
+	case "text":
+		cacheInputs = map[string]interface{}{"_text": e.cardText(c)}
+	case "grid":
+		cacheInputs = map[string]interface{}{"_cells": c.Cells}
+	case "formula", "script", "template":
+		// The card's text is its source code
+		cacheInputs["_source"] = c.Text
+	case "xlsx_import":
+		// Reading a file: rerun when it changes on disk
+		cacheInputs["_file"] = fileStamp(c.ParamText("path"))
+	case "flow_input":
+		cacheInputs["_arg"] = e.args[fmt.Sprint(c.Param("name"))]
+	case "call_flow":
+		// Rerun when the called flow file, or a file it reads, changes
+		cacheInputs["_file"] = e.calledFlowStamp(c)
+	case "composite", "map":
+		// Rerun when the inner flow is edited
+		if c.Subflow != nil {
+			cacheInputs["_inner"] = subflowState(c.Subflow)
+		}
+	}
+	if params := c.ResolvedParams(); len(params) > 0 {
+		cacheInputs["_params"] = params
+	}
+	if c.hasSecrets() {
+		cacheInputs["_secrets"] = c.secretDigest()
+	}
+	if e.vars != nil && len(e.vars.values) > 0 {
+		// Switching profile or editing a variable reruns the flow
+		cacheInputs["_vars"] = e.vars.values
 	}
 	inputHash := engine.ComputeInputHash(c.ID, cacheInputs)
 	if cached, ok := e.ExecutionCache[c.ID]; ok && cached.InputHash == inputHash {
 		fmt.Printf("[%s] Cache hit - using cached result\n", c.Title)
 		// Use cached result
-		for _, p := range c.Outputs {
-			key := fmt.Sprintf("%s:%s", c.ID, p.Name)
-			e.Memory[key] = cached.Output
-		}
-		// Update card text with cached result for display
-		if c.Title == "String:find_replace" {
-			if str, ok := cached.Output.(string); ok {
-				c.Text = str
-				// Propagate to subscribers
-				e.game.PropagateText(c)
-			}
-		}
+		e.storeOutputs(c, cached.Outputs, cached.Output)
 		return
 	}
 
 	// 3. Log execution start
-	fmt.Printf("[%s] Executing with inputs: %v\n", c.Title, inputs)
+	fmt.Printf("[%s] Executing with inputs: %s\n", c.Title, secretStore.Redact(fmt.Sprint(inputs)))
 
 	// 4. Execute based on card type
-	var result interface{}
+	var outputs map[string]interface{}
 	var err error
 
-	if c.Type == "text" {
+	switch c.Type {
+	case "text":
 		// Text cards just output their text
-		result = c.Text
-	} else {
+		outputs = map[string]interface{}{}
+		for _, p := range c.Outputs {
+			outputs[p.Name] = e.cardText(c)
+		}
+	case "grid":
+		// Grid cards output their cells as a table
+		outputs = map[string]interface{}{"table": GridTable(c.Cells)}
+	case "join":
+		outputs, err = executeJoin(c, inputs)
+	case "chart":
+		outputs, err = executeChart(c, inputs)
+	case "export":
+		outputs, err = e.executeExport(c, inputs)
+	case "xlsx_import":
+		outputs, err = executeXLSXImport(c)
+	case "xlsx_export":
+		outputs, err = e.executeXLSXExport(c, inputs)
+	case "template":
+		outputs, err = executeTemplate(c, inputs)
+	case "composite":
+		outputs, err = executeComposite(c, inputs)
+	case "map":
+		outputs, err = executeMap(c, inputs)
+	case "if":
+		outputs = executeIf(inputs)
+	case "merge":
+		outputs, err = executeMerge(c, inputs)
+	case "flow_input":
+		outputs = e.executeFlowInput(c, inputs)
+	case "flow_output":
+		outputs = map[string]interface{}{"value": inputs["value"]}
+	case "call_flow":
+		outputs, err = e.executeCallFlow(c, inputs)
+	case "llm":
+		outputs, err = e.executeLLM(c, inputs)
+	default:
 		// Execute Starlark for functional cards
-		result, err = e.executeStarlark(c, inputs)
-		if err != nil {
-			fmt.Printf("[%s] Execution error: %v\n", c.Title, err)
-			c.LastErrorFlash = time.Now()
-			return
+		outputs, err = e.executeStarlark(c, inputs)
+	}
+	if errors.Is(err, errPending) {
+		// The cards after it are skipped until the answer arrives
+		e.skipped[c.ID] = true
+		c.LastError = ""
+		for _, p := range c.Outputs {
+			delete(e.Memory, c.ID+":"+p.Name)
 		}
+		return
 	}
+	if err != nil {
+		c.LastError = secretStore.Redact(err.Error())
+		fmt.Printf("[%s] Execution error: %s\n", c.Title, c.LastError)
+		c.LastErrorFlash = time.Now()
+		return
+	}
+	result := primaryOutput(c, outputs)
+	c.LastError = ""
 
 	// 5. Log execution result
-	fmt.Printf("[%s] Result: %v\n", c.Title, result)
+	fmt.Printf("[%s] Result: %s\n", c.Title, secretStore.Redact(fmt.Sprint(result)))
 
 	// 6. Success flash (200ms)
 	c.LastSuccessFlash = time.Now()
@@ -176,17 +361,60 @@ func (e *Engine) executeCard(c *Card) {
 	e.ExecutionCache[c.ID] = CacheEntry{
 		InputHash:  inputHash,
 		Output:     result,
+		Outputs:    outputs,
 		ExecutedAt: time.Now(),
 	}
 
-	// 8. Store Outputs in Memory
+	// 8. Store Outputs in Memory and update the card display
+	e.storeOutputs(c, outputs, result)
+}
+
+// logRun adds an entry to the run log, dropping the oldest entries past RunLogLimit
+func (e *Engine) logRun(c *Card, message string, data map[string]interface{}) {
+	message = secretStore.Redact(message)
+	for k, v := range data {
+		if s, ok := v.(string); ok {
+			data[k] = secretStore.Redact(s)
+		}
+	}
+	fmt.Printf("[%s] %s\n", c.Title, message)
+	e.RunLog = append(e.RunLog, RunLogEntry{
+		Time:    time.Now(),
+		CardID:  c.ID,
+		Title:   c.Title,
+		Message: message,
+		Data:    data,
+	})
+	if len(e.RunLog) > RunLogLimit {
+		e.RunLog = e.RunLog[len(e.RunLog)-RunLogLimit:]
+	}
+}
+
+// primaryOutput returns the value of the card's first output port
+func primaryOutput(c *Card, outputs map[string]interface{}) interface{} {
+	if len(c.Outputs) == 0 {
+		return nil
+	}
+	return outputs[c.Outputs[0].Name]
+}
+
+// storeOutputs writes each output port value to Memory and refreshes the card display
+func (e *Engine) storeOutputs(c *Card, outputs map[string]interface{}, result interface{}) {
 	for _, p := range c.Outputs {
 		key := fmt.Sprintf("%s:%s", c.ID, p.Name)
-		e.Memory[key] = result
+		if v, ok := outputs[p.Name]; ok {
+			e.Memory[key] = v
+		} else if c.IsBranch() {
+			// A branch not taken carries no value
+			e.inactive[key] = true
+			delete(e.Memory, key)
+		} else {
+			e.Memory[key] = result
+		}
 	}
 
-	// 9. Update card text with result for display and propagate
-	if c.Title == "String:find_replace" {
+	// Update card text with result for display and propagate
+	if c.Type == "find_replace" {
 		if str, ok := result.(string); ok {
 			c.Text = str
 			// Propagate to subscribers using pub-sub
@@ -195,8 +423,9 @@ func (e *Engine) executeCard(c *Card) {
 	}
 }
 
-// executeStarlark runs Starlark code for a card via enginepkg helper
-func (e *Engine) executeStarlark(c *Card, inputs map[string]interface{}) (interface{}, error) {
+// executeStarlark runs Starlark code for a card via enginepkg helper and
+// returns the values of the card's output ports
+func (e *Engine) executeStarlark(c *Card, inputs map[string]interface{}) (map[string]interface{}, error) {
 	// Ensure defaults for find_replace
 	if c.Type == "find_replace" {
 		if _, ok := inputs["input"]; !ok {
@@ -210,42 +439,90 @@ func (e *Engine) executeStarlark(c *Card, inputs map[string]interface{}) (interf
 		}
 	}
 
-	script := e.getCardScript(c)
+	if err := validateForm(c, inputs); err != nil {
+		return nil, err
+	}
+	script, err := e.getCardScript(c)
+	if err != nil {
+		return nil, err
+	}
 	if script == "" {
 		return nil, fmt.Errorf("no script defined for card type: %s", c.Title)
 	}
 
-	outputs, err := engine.ExecuteStarlark(c.Title, script, inputs)
+	// Parameters and the flow variables, as the vars dict, are globals too;
+	// wired inputs take precedence and unconnected input ports are None
+	globals, err := c.ScriptParams()
 	if err != nil {
 		return nil, err
 	}
+	globals["vars"] = c.vars.dict()
+	for _, p := range c.Inputs {
+		globals[p.Name] = nil
+	}
+	for k, v := range inputs {
+		globals[k] = v
+	}
+	if c.Type == "concat" {
+		globals["values"] = concatValues(c, inputs)
+	}
 
+	outputs, err := engine.ExecuteStarlark(c.Title, script, globals)
+	if c.Type == "formula" {
+		err = formula.Explain(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{})
 	for _, p := range c.Outputs {
 		if v, ok := outputs[p.Name]; ok {
-			return v, nil
+			result[p.Name] = v
 		}
 	}
-	return nil, fmt.Errorf("no output found")
-}
-
-func (e *Engine) getCardScript(c *Card) string {
-	if c.Type == "text" {
-		// Pass through or literal text
-		// If 'text' input is connected, it passes through.
-		// Else it uses c.Text
-		return `
-if "text" not in locals():
-    text = "` + c.Text + `"
-` // If input 'text' exists (in globals), this script does nothing, preserving it.
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no output found")
 	}
+	return result, nil
+}
 
+func (e *Engine) getCardScript(c *Card) (string, error) {
 	if c.Type == "find_replace" {
 		return `
 # Perform find and replace operation
-result = input.replace(find, replace) if input and find else input
-`
+result, count, matches = input, 0, []
+if input and find:
+    opts = dict(count = max_count, ignore_case = ignore_case, whole_word = whole_word, literal = not regex)
+    result, count = re.subn(find, replace, input, **opts)
+    matches = re.findall(find, input, **opts)
+`, nil
+	}
+
+	if c.Type == "formula" {
+		return formulaScript(c)
+	}
+
+	if c.Type == "concat" {
+		return concatScript, nil
+	}
+
+	if c.Type == "groupby" {
+		return transform.GroupByFromParams(c.ResolvedParams()).Script("table", "result"), nil
+	}
+
+	if c.Type == "filter" {
+		return transform.FilterFromParams(c.ResolvedParams()).Script("table", "result"), nil
+	}
+
+	if c.Type == "sort" {
+		return transform.SortFromParams(c.ResolvedParams()).Script("table", "result"), nil
+	}
+
+	if c.Type == "script" {
+		return c.Text, nil
 	}
-	return ""
+	return "", nil
 }
 
 // Note: Starlark conversion helpers moved to enginepkg package.
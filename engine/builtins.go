@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	starlarkmath "go.starlark.net/lib/math"
+	starlarktime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// builtins are predeclared in every card script. Inputs with the same name shadow them.
+var builtins = starlark.StringDict{
+	"re":   reModule,
+	"math": starlarkmath.Module,
+	"time": starlarktime.Module,
+}
+
+// keywords are the words of the Starlark language, including those it reserves
+var keywords = []string{
+	"and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del",
+	"elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in",
+	"is", "lambda", "load", "nonlocal", "not", "or", "pass", "raise", "return", "try",
+	"while", "with", "yield",
+}
+
+// IsReservedName reports whether a script global of this name would be a syntax
+// error or shadow a builtin such as str or re
+func IsReservedName(name string) bool {
+	if slices.Contains(keywords, name) {
+		return true
+	}
+	return builtins.Has(name) || starlark.Universe.Has(name)
+}
+
+// reModule exposes Go's regexp package to scripts, since Starlark has no regex support.
+var reModule = &starlarkstruct.Module{
+	Name: "re",
+	Members: starlark.StringDict{
+		"subn":    starlark.NewBuiltin("re.subn", reSubn),
+		"findall": starlark.NewBuiltin("re.findall", reFindall),
+	},
+}
+
+// MatchOptions controls how a find pattern is interpreted.
+type MatchOptions struct {
+	Literal    bool // treat the pattern as plain text
+	IgnoreCase bool
+	WholeWord  bool
+	Count      int // maximum number of matches; 0 means all
+}
+
+// CompilePattern builds a regexp from a user pattern according to opts.
+func CompilePattern(pattern string, opts MatchOptions) (*regexp.Regexp, error) {
+	if opts.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.WholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if opts.IgnoreCase {
+		pattern = `(?i)` + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// ReplaceN replaces up to opts.Count matches of pattern in s and returns the new
+// string, the number of replacements and the matched substrings.
+// In regex mode the replacement may reference groups as $1, ${name}, \1 or \g<name>.
+func ReplaceN(s, pattern, repl string, opts MatchOptions) (string, int, []string, error) {
+	re, err := CompilePattern(pattern, opts)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	template := replacementTemplate(repl, opts.Literal)
+	n := opts.Count
+	if n <= 0 {
+		n = -1
+	}
+
+	var b []byte
+	var matches []string
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, n) {
+		b = append(b, s[last:m[0]]...)
+		b = re.ExpandString(b, template, s, m)
+		matches = append(matches, s[m[0]:m[1]])
+		last = m[1]
+	}
+	if matches == nil {
+		return s, 0, []string{}, nil
+	}
+	b = append(b, s[last:]...)
+	return string(b), len(matches), matches, nil
+}
+
+var pythonGroupRef = regexp.MustCompile(`\\(\d+)|\\g<(\w+)>`)
+
+// replacementTemplate converts a user replacement string into a regexp.Expand template.
+func replacementTemplate(repl string, literal bool) string {
+	if literal {
+		return strings.ReplaceAll(repl, "$", "$$")
+	}
+	return pythonGroupRef.ReplaceAllStringFunc(repl, func(ref string) string {
+		m := pythonGroupRef.FindStringSubmatch(ref)
+		if m[1] != "" {
+			return "${" + m[1] + "}"
+		}
+		return "${" + m[2] + "}"
+	})
+}
+
+func unpackMatchArgs(b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple, withRepl bool) (pattern, repl, s string, opts MatchOptions, err error) {
+	if withRepl {
+		err = starlark.UnpackArgs(b.Name(), args, kwargs,
+			"pattern", &pattern, "repl", &repl, "string", &s,
+			"count?", &opts.Count, "ignore_case?", &opts.IgnoreCase,
+			"whole_word?", &opts.WholeWord, "literal?", &opts.Literal)
+		return
+	}
+	err = starlark.UnpackArgs(b.Name(), args, kwargs,
+		"pattern", &pattern, "string", &s,
+		"count?", &opts.Count, "ignore_case?", &opts.IgnoreCase,
+		"whole_word?", &opts.WholeWord, "literal?", &opts.Literal)
+	return
+}
+
+// re.subn(pattern, repl, string, count=0, ignore_case=False, whole_word=False, literal=False) -> (string, n)
+func reSubn(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	pattern, repl, s, opts, err := unpackMatchArgs(b, args, kwargs, true)
+	if err != nil {
+		return nil, err
+	}
+	out, n, _, err := ReplaceN(s, pattern, repl, opts)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.Tuple{starlark.String(out), starlark.MakeInt(n)}, nil
+}
+
+// re.findall(pattern, string, count=0, ignore_case=False, whole_word=False, literal=False) -> list of matches
+func reFindall(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	pattern, _, s, opts, err := unpackMatchArgs(b, args, kwargs, false)
+	if err != nil {
+		return nil, err
+	}
+	re, err := CompilePattern(pattern, opts)
+	if err != nil {
+		return nil, err
+	}
+	n := opts.Count
+	if n <= 0 {
+		n = -1
+	}
+	found := re.FindAllString(s, n)
+	elems := make([]starlark.Value, len(found))
+	for i, m := range found {
+		elems[i] = starlark.String(m)
+	}
+	return starlark.NewList(elems), nil
+}
@@ -4,10 +4,21 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"sort"
 
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
+// scriptOptions allows top-level control flow and reassignment so card scripts
+// can be written as plain sequential code.
+var scriptOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+}
+
 // computeInputHash creates a hash of card inputs for cache key
 func ComputeInputHash(cardID string, inputs map[string]interface{}) string {
 	data := map[string]interface{}{
@@ -24,13 +35,16 @@ func ExecuteStarlark(threadName string, script string, inputs map[string]interfa
 	thread := &starlark.Thread{Name: threadName, Print: func(_ *starlark.Thread, msg string) { fmt.Println(msg) }}
 
 	globals := starlark.StringDict{}
+	for k, v := range builtins {
+		globals[k] = v
+	}
 	for k, v := range inputs {
 		if val, err := toStarlarkValue(v); err == nil {
 			globals[k] = val
 		}
 	}
 
-	resultGlobals, err := starlark.ExecFile(thread, threadName, script, globals)
+	resultGlobals, err := starlark.ExecFileOptions(scriptOptions, thread, threadName, script, globals)
 	if err != nil {
 		return nil, err
 	}
@@ -52,10 +66,47 @@ func toStarlarkValue(v interface{}) (starlark.Value, error) {
 		return starlark.String(val), nil
 	case int:
 		return starlark.MakeInt(val), nil
+	case int64:
+		return starlark.MakeInt64(val), nil
 	case float64:
 		return starlark.Float(val), nil
 	case bool:
 		return starlark.Bool(val), nil
+	case *Table:
+		return tableToStarlark(val)
+	case []string:
+		elems := make([]starlark.Value, len(val))
+		for i, s := range val {
+			elems[i] = starlark.String(s)
+		}
+		return starlark.NewList(elems), nil
+	case []interface{}:
+		elems := make([]starlark.Value, 0, len(val))
+		for _, item := range val {
+			sv, err := toStarlarkValue(item)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, sv)
+		}
+		return starlark.NewList(elems), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(val))
+		for _, k := range keys {
+			sv, err := toStarlarkValue(val[k])
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(k), sv); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
 	}
 	return starlark.None, fmt.Errorf("unsupported type: %T", v)
 }
@@ -71,6 +122,31 @@ func FromStarlarkValue(v starlark.Value) interface{} {
 		return float64(val)
 	case starlark.Bool:
 		return bool(val)
+	case *starlark.List:
+		out := make([]interface{}, val.Len())
+		for i := 0; i < val.Len(); i++ {
+			out[i] = FromStarlarkValue(val.Index(i))
+		}
+		return out
+	case starlark.Tuple:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = FromStarlarkValue(item)
+		}
+		return out
+	case *starlark.Dict:
+		if t, ok := tableFromStarlark(val); ok {
+			return t
+		}
+		out := make(map[string]interface{}, val.Len())
+		for _, item := range val.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				continue
+			}
+			out[string(key)] = FromStarlarkValue(item[1])
+		}
+		return out
 	}
 	return nil
 }
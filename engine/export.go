@@ -0,0 +1,350 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormats and ExportModes list the supported export settings in form order
+var (
+	ExportFormats = []string{"csv", "jsonl", "json"}
+	ExportModes   = []string{"overwrite", "append", "timestamped"}
+)
+
+// ExportResult describes a completed export
+type ExportResult struct {
+	Path string
+	Rows int
+}
+
+// Export writes a value to a file as CSV, JSON Lines or pretty JSON.
+// Overwrite replaces the file, append adds rows to it and timestamped writes a
+// new file whose name includes now. The file is replaced atomically in every mode.
+func Export(v interface{}, path, format, mode string, now time.Time) (ExportResult, error) {
+	if strings.TrimSpace(path) == "" {
+		return ExportResult{}, errors.New("set a file path to export to")
+	}
+	var existing []byte
+	switch mode {
+	case "overwrite":
+	case "timestamped":
+		path = TimestampedPath(path, now)
+	case "append":
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return ExportResult{}, err
+		}
+		existing = data
+	default:
+		return ExportResult{}, fmt.Errorf("unknown export mode %q (use %s)", mode, strings.Join(ExportModes, ", "))
+	}
+
+	var data []byte
+	var err error
+	switch format {
+	case "csv":
+		data, err = appendCSV(existing, v)
+	case "jsonl":
+		data, err = appendJSONLines(existing, v)
+	case "json":
+		data, err = appendJSON(existing, v)
+	default:
+		err = fmt.Errorf("unknown export format %q (use %s)", format, strings.Join(ExportFormats, ", "))
+	}
+	if err != nil {
+		return ExportResult{}, err
+	}
+	if err := WriteFileAtomic(path, data); err != nil {
+		return ExportResult{}, err
+	}
+	return ExportResult{Path: path, Rows: exportRows(v)}, nil
+}
+
+// TimestampedPath inserts the time before the extension, e.g. out_20240131-150405.csv
+func TimestampedPath(path string, now time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "_" + now.Format("20060102-150405") + ext
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it into
+// place, so readers never see a partly written file. An existing file keeps its mode.
+func WriteFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// A replaced file keeps its permissions
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// exportRows counts the rows an export writes: table rows, list items or one value
+func exportRows(v interface{}) int {
+	switch val := v.(type) {
+	case *Table:
+		return val.NumRows()
+	case []interface{}:
+		return len(val)
+	}
+	return 1
+}
+
+// exportTable converts a value to a table for CSV: lists of maps become one row per
+// map, other lists one row per item and single values a one-row table
+func exportTable(v interface{}) *Table {
+	switch val := v.(type) {
+	case *Table:
+		return val
+	case []interface{}:
+		var columns []string
+		seen := map[string]bool{}
+		for _, item := range val {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				columns = nil
+				break
+			}
+			for _, k := range sortedKeys(m) {
+				if !seen[k] {
+					seen[k] = true
+					columns = append(columns, k)
+				}
+			}
+		}
+		if columns == nil {
+			t := NewTable("value")
+			for _, item := range val {
+				t.AppendRow(item)
+			}
+			return t
+		}
+		t := NewTable(columns...)
+		for _, item := range val {
+			m := item.(map[string]interface{})
+			row := make([]interface{}, len(columns))
+			for i, c := range columns {
+				row[i] = m[c]
+			}
+			t.AppendRow(row...)
+		}
+		return t
+	case map[string]interface{}:
+		return exportTable([]interface{}{val})
+	}
+	t := NewTable("value")
+	t.AppendRow(v)
+	return t
+}
+
+// CSV formats a table, or a list or value turned into one, as CSV text
+func CSV(v interface{}) (string, error) {
+	data, err := appendCSV(nil, v)
+	return string(data), err
+}
+
+// appendCSV adds the value's rows to existing CSV data, writing the header only
+// for a new file. Appending requires the same columns as the file.
+func appendCSV(existing []byte, v interface{}) ([]byte, error) {
+	t := exportTable(v)
+	var buf bytes.Buffer
+	buf.Write(existing)
+	w := csv.NewWriter(&buf)
+	if len(bytes.TrimSpace(existing)) == 0 {
+		buf.Reset()
+		if err := w.Write(t.Columns); err != nil {
+			return nil, err
+		}
+	} else {
+		header, err := csv.NewReader(bytes.NewReader(existing)).Read()
+		if err != nil {
+			return nil, fmt.Errorf("cannot append to the existing file: %v", err)
+		}
+		if strings.Join(header, ",") != strings.Join(t.Columns, ",") {
+			return nil, fmt.Errorf("cannot append: the file has columns %s but the input has %s",
+				strings.Join(header, ", "), strings.Join(t.Columns, ", "))
+		}
+		if !bytes.HasSuffix(existing, []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+	}
+
+	record := make([]string, len(t.Columns))
+	for r := 0; r < t.NumRows(); r++ {
+		for c := range t.Columns {
+			record[c] = csvValue(t.Value(r, c))
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvValue formats a cell; nested maps, lists and tables are written as JSON
+func csvValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case map[string]interface{}, []interface{}, *Table:
+		if b, err := json.Marshal(jsonValue(val)); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// appendJSONLines adds one JSON object per table row or list item
+func appendJSONLines(existing []byte, v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(existing)
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	for _, item := range exportItems(v) {
+		line, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// appendJSON writes the value as indented JSON. Appending adds its rows or items to
+// the array already in the file.
+func appendJSON(existing []byte, v interface{}) ([]byte, error) {
+	out := jsonValue(v)
+	if len(bytes.TrimSpace(existing)) > 0 {
+		var items []json.RawMessage
+		if err := json.Unmarshal(existing, &items); err != nil {
+			return nil, errors.New("cannot append: the file does not hold a JSON array")
+		}
+		for _, item := range exportItems(v) {
+			b, err := json.Marshal(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, b)
+		}
+		out = items
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// exportItems splits a value into the items written as JSON lines or array elements
+func exportItems(v interface{}) []interface{} {
+	switch val := jsonValue(v).(type) {
+	case []interface{}:
+		return val
+	case []record:
+		items := make([]interface{}, len(val))
+		for i, r := range val {
+			items[i] = r
+		}
+		return items
+	default:
+		return []interface{}{val}
+	}
+}
+
+// record is a table row that keeps its column order in JSON
+type record struct {
+	columns []string
+	values  []interface{}
+}
+
+func (r record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, c := range r.columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(c)
+		value, err := json.Marshal(jsonValue(r.values[i]))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonValue converts tables, including nested ones, to lists of ordered records
+func jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case *Table:
+		records := make([]record, val.NumRows())
+		for r := range records {
+			records[r] = record{columns: val.Columns, values: val.Row(r)}
+		}
+		return records
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = jsonValue(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = jsonValue(item)
+		}
+		return out
+	}
+	return v
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// JoinOptions configures a table join.
+type JoinOptions struct {
+	LeftKeys    []string // key columns of the left table
+	RightKeys   []string // key columns of the right table, paired with LeftKeys
+	Mode        string   // "inner", "left", "right" or "outer"
+	LeftSuffix  string   // appended to left columns whose names clash
+	RightSuffix string   // appended to right columns whose names clash
+}
+
+// JoinStats counts how the rows of both tables were matched.
+type JoinStats struct {
+	LeftRows       int
+	RightRows      int
+	Matched        int // result rows built from a left and a right row
+	LeftUnmatched  int // left rows without a partner
+	RightUnmatched int // right rows without a partner
+	Rows           int // rows in the result
+}
+
+// Map returns the statistics as a map for card outputs
+func (s JoinStats) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"left_rows":       s.LeftRows,
+		"right_rows":      s.RightRows,
+		"matched":         s.Matched,
+		"left_unmatched":  s.LeftUnmatched,
+		"right_unmatched": s.RightUnmatched,
+		"rows":            s.Rows,
+	}
+}
+
+// ParseJoinKeys parses a comma-separated key list. Each entry is either a column
+// present in both tables or "left_col=right_col".
+// An empty spec joins on every column the two tables have in common.
+func ParseJoinKeys(spec string, left, right *Table) (leftKeys, rightKeys []string) {
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		l, r := part, part
+		if i := strings.Index(part, "="); i >= 0 {
+			l, r = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		leftKeys = append(leftKeys, l)
+		rightKeys = append(rightKeys, r)
+	}
+	if len(leftKeys) == 0 && left != nil && right != nil {
+		for _, c := range left.Columns {
+			if right.ColumnIndex(c) >= 0 {
+				leftKeys = append(leftKeys, c)
+				rightKeys = append(rightKeys, c)
+			}
+		}
+	}
+	return leftKeys, rightKeys
+}
+
+// Join combines two tables on their key columns. Rows keep the order of the left
+// table; right rows without a partner follow at the end in right and outer mode.
+// Key values are compared by value, so 1 and 1.0 match; empty keys never match.
+func Join(left, right *Table, opts JoinOptions) (*Table, JoinStats, error) {
+	var stats JoinStats
+	if left == nil || right == nil {
+		return nil, stats, fmt.Errorf("join needs a table on both the left and right inputs")
+	}
+	mode := opts.Mode
+	if mode == "" {
+		mode = "inner"
+	}
+	if mode != "inner" && mode != "left" && mode != "right" && mode != "outer" {
+		return nil, stats, fmt.Errorf("unknown join mode %q (use inner, left, right or outer)", mode)
+	}
+	if len(opts.LeftKeys) == 0 {
+		return nil, stats, fmt.Errorf("no join keys: the tables have no columns in common")
+	}
+	if len(opts.LeftKeys) != len(opts.RightKeys) {
+		return nil, stats, fmt.Errorf("join needs the same number of left and right keys")
+	}
+
+	leftIdx, err := columnIndexes(left, opts.LeftKeys, "left")
+	if err != nil {
+		return nil, stats, err
+	}
+	rightIdx, err := columnIndexes(right, opts.RightKeys, "right")
+	if err != nil {
+		return nil, stats, err
+	}
+
+	// Result columns: all left columns, then right columns that are not keys
+	isRightKey := make(map[int]bool)
+	for _, i := range rightIdx {
+		isRightKey[i] = true
+	}
+	var rightCols []int
+	for i := range right.Columns {
+		if !isRightKey[i] {
+			rightCols = append(rightCols, i)
+		}
+	}
+	names := joinColumnNames(left.Columns, right.Columns, rightCols, opts)
+	out := NewTable(names...)
+
+	// Index right rows by key
+	index := make(map[string][]int)
+	for r := 0; r < right.NumRows(); r++ {
+		if key, ok := rowKey(right, r, rightIdx); ok {
+			index[key] = append(index[key], r)
+		}
+	}
+
+	stats.LeftRows = left.NumRows()
+	stats.RightRows = right.NumRows()
+	rightUsed := make([]bool, right.NumRows())
+	keyOf := make(map[int]int) // left key column -> right key column
+	for k, i := range leftIdx {
+		keyOf[i] = rightIdx[k]
+	}
+
+	emit := func(l, r int) {
+		row := make([]interface{}, 0, len(names))
+		for c := range left.Columns {
+			switch {
+			case l >= 0:
+				row = append(row, left.Value(l, c))
+			case r >= 0:
+				// Right-only rows fill the key columns from the right table
+				if rc, ok := keyOf[c]; ok {
+					row = append(row, right.Value(r, rc))
+				} else {
+					row = append(row, nil)
+				}
+			}
+		}
+		for _, c := range rightCols {
+			if r >= 0 {
+				row = append(row, right.Value(r, c))
+			} else {
+				row = append(row, nil)
+			}
+		}
+		out.AppendRow(row...)
+	}
+
+	for l := 0; l < left.NumRows(); l++ {
+		var partners []int
+		if key, ok := rowKey(left, l, leftIdx); ok {
+			partners = index[key]
+		}
+		if len(partners) == 0 {
+			stats.LeftUnmatched++
+			if mode == "left" || mode == "outer" {
+				emit(l, -1)
+			}
+			continue
+		}
+		for _, r := range partners {
+			rightUsed[r] = true
+			stats.Matched++
+			emit(l, r)
+		}
+	}
+	for r, used := range rightUsed {
+		if used {
+			continue
+		}
+		stats.RightUnmatched++
+		if mode == "right" || mode == "outer" {
+			emit(-1, r)
+		}
+	}
+
+	stats.Rows = out.NumRows()
+	return out, stats, nil
+}
+
+func columnIndexes(t *Table, keys []string, side string) ([]int, error) {
+	idx := make([]int, len(keys))
+	for i, k := range keys {
+		idx[i] = t.ColumnIndex(k)
+		if idx[i] < 0 {
+			return nil, fmt.Errorf("join key %q not found in the %s table (columns: %s)", k, side, strings.Join(t.Columns, ", "))
+		}
+	}
+	return idx, nil
+}
+
+// joinColumnNames names the result columns, adding suffixes where left and right names clash
+func joinColumnNames(leftCols, rightCols []string, rightIdx []int, opts JoinOptions) []string {
+	clash := make(map[string]bool)
+	for _, l := range leftCols {
+		for _, i := range rightIdx {
+			if l == rightCols[i] {
+				clash[l] = true
+			}
+		}
+	}
+	var names []string
+	for _, c := range leftCols {
+		if clash[c] {
+			c += opts.LeftSuffix
+		}
+		names = append(names, c)
+	}
+	for _, i := range rightIdx {
+		c := rightCols[i]
+		if clash[c] {
+			c += opts.RightSuffix
+		}
+		names = append(names, c)
+	}
+	return names
+}
+
+// rowKey builds a comparable key from a row's key columns; ok is false if any key is empty
+func rowKey(t *Table, row int, cols []int) (string, bool) {
+	parts := make([]string, len(cols))
+	for i, c := range cols {
+		v := t.Value(row, c)
+		switch val := v.(type) {
+		case nil:
+			return "", false
+		case int:
+			parts[i] = "n" + strconv.Itoa(val)
+		case float64:
+			if val == math.Trunc(val) && math.Abs(val) < 1e15 {
+				parts[i] = "n" + strconv.FormatInt(int64(val), 10)
+			} else {
+				parts[i] = "n" + strconv.FormatFloat(val, 'g', -1, 64)
+			}
+		case string:
+			if val == "" {
+				return "", false
+			}
+			parts[i] = "s" + val
+		default:
+			parts[i] = fmt.Sprintf("v%v", val)
+		}
+	}
+	return strings.Join(parts, "\x00"), true
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"strconv"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// Table is a columnar dataset passed between cards.
+// In scripts a table is a dict {"columns": [...], "rows": [[...], ...]}.
+type Table struct {
+	Columns []string        `json:"columns"`
+	Data    [][]interface{} `json:"data"` // one slice of values per column
+}
+
+// NewTable creates an empty table with the given column names
+func NewTable(columns ...string) *Table {
+	t := &Table{Columns: append([]string{}, columns...)}
+	t.Data = make([][]interface{}, len(columns))
+	return t
+}
+
+// NumRows returns the number of rows in the table
+func (t *Table) NumRows() int {
+	if t == nil || len(t.Data) == 0 {
+		return 0
+	}
+	return len(t.Data[0])
+}
+
+// ColumnIndex returns the index of the named column, or -1
+func (t *Table) ColumnIndex(name string) int {
+	for i, c := range t.Columns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Value returns the value at the given row and column index
+func (t *Table) Value(row, col int) interface{} {
+	return t.Data[col][row]
+}
+
+// Row returns a copy of the values of one row
+func (t *Table) Row(i int) []interface{} {
+	row := make([]interface{}, len(t.Columns))
+	for c := range t.Columns {
+		row[c] = t.Data[c][i]
+	}
+	return row
+}
+
+// AppendRow adds a row; missing trailing values are nil
+func (t *Table) AppendRow(values ...interface{}) {
+	for c := range t.Columns {
+		var v interface{}
+		if c < len(values) {
+			v = values[c]
+		}
+		t.Data[c] = append(t.Data[c], v)
+	}
+}
+
+// ParseCell converts spreadsheet cell text to a number when it looks like one.
+// Empty cells become nil.
+func ParseCell(s string) interface{} {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return nil
+	}
+	if i, err := strconv.Atoi(trimmed); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(trimmed, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+func tableToStarlark(t *Table) (starlark.Value, error) {
+	cols := make([]starlark.Value, len(t.Columns))
+	for i, c := range t.Columns {
+		cols[i] = starlark.String(c)
+	}
+	rows := make([]starlark.Value, t.NumRows())
+	for r := range rows {
+		cells := make([]starlark.Value, len(t.Columns))
+		for c := range t.Columns {
+			v, err := toStarlarkValue(t.Data[c][r])
+			if err != nil {
+				return nil, err
+			}
+			cells[c] = v
+		}
+		rows[r] = starlark.NewList(cells)
+	}
+	dict := starlark.NewDict(2)
+	dict.SetKey(starlark.String("columns"), starlark.NewList(cols))
+	dict.SetKey(starlark.String("rows"), starlark.NewList(rows))
+	return dict, nil
+}
+
+// tableFromStarlark recognizes the script representation of a table
+func tableFromStarlark(d *starlark.Dict) (*Table, bool) {
+	if d.Len() != 2 {
+		return nil, false
+	}
+	colsV, _, _ := d.Get(starlark.String("columns"))
+	rowsV, _, _ := d.Get(starlark.String("rows"))
+	cols, ok1 := colsV.(*starlark.List)
+	rows, ok2 := rowsV.(*starlark.List)
+	if !ok1 || !ok2 {
+		return nil, false
+	}
+
+	names := make([]string, cols.Len())
+	for i := range names {
+		s, ok := cols.Index(i).(starlark.String)
+		if !ok {
+			return nil, false
+		}
+		names[i] = string(s)
+	}
+	t := NewTable(names...)
+	for r := 0; r < rows.Len(); r++ {
+		var cells []interface{}
+		switch row := rows.Index(r).(type) {
+		case *starlark.List:
+			for i := 0; i < row.Len(); i++ {
+				cells = append(cells, FromStarlarkValue(row.Index(i)))
+			}
+		case starlark.Tuple:
+			for _, v := range row {
+				cells = append(cells, FromStarlarkValue(v))
+			}
+		default:
+			return nil, false
+		}
+		t.AppendRow(cells...)
+	}
+	return t, true
+}
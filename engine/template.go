@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplateSyntaxes lists the supported template languages in form order
+var TemplateSyntaxes = []string{"go", "starlark"}
+
+// TemplateValue prepares an input value for a template: tables become a list of
+// row maps so templates can loop over rows and read cells by column name. Empty
+// cells become "" since text/template prints nil as "<no value>".
+func TemplateValue(v interface{}) interface{} {
+	t, ok := v.(*Table)
+	if !ok {
+		return v
+	}
+	rows := make([]interface{}, t.NumRows())
+	for r := range rows {
+		row := make(map[string]interface{}, len(t.Columns))
+		for c, name := range t.Columns {
+			if v := t.Value(r, c); v != nil {
+				row[name] = v
+			} else {
+				row[name] = ""
+			}
+		}
+		rows[r] = row
+	}
+	return rows
+}
+
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"join": func(items []interface{}, sep string) string {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, sep)
+	},
+	"add": func(a, b int) int { return a + b },
+}
+
+// RenderGoTemplate fills a text/template with the data. Referring to a missing
+// input is an error rather than "<no value>".
+func RenderGoTemplate(name, src string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// FStringScript compiles an f-string style template such as "Total: {sum(prices)}"
+// to Starlark that stores the filled text in output. Each {expression} is Starlark;
+// None prints as nothing and {{ or }} print a literal brace.
+func FStringScript(src, output string) (string, error) {
+	var b strings.Builder
+	b.WriteString("def _str(v):\n    return \"\" if v == None else str(v)\n\n_parts = []\n")
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			fmt.Fprintf(&b, "_parts.append(%s)\n", strconv.Quote(lit.String()))
+			lit.Reset()
+		}
+	}
+
+	for i := 0; i < len(src); i++ {
+		switch ch := src[i]; {
+		case strings.HasPrefix(src[i:], "{{"), strings.HasPrefix(src[i:], "}}"):
+			lit.WriteByte(ch)
+			i++
+		case ch == '}':
+			return "", fmt.Errorf("line %d: single '}' in template (write }} for a literal brace)", lineOf(src, i))
+		case ch == '{':
+			end, err := expressionEnd(src, i+1)
+			if err != nil {
+				return "", err
+			}
+			expr := strings.TrimSpace(src[i+1 : end])
+			if expr == "" {
+				return "", fmt.Errorf("line %d: empty {} in template", lineOf(src, i))
+			}
+			flush()
+			fmt.Fprintf(&b, "_parts.append(_str(%s))\n", expr)
+			i = end
+		default:
+			lit.WriteByte(ch)
+		}
+	}
+	flush()
+	fmt.Fprintf(&b, "%s = \"\".join(_parts)\n", output)
+	return b.String(), nil
+}
+
+// expressionEnd returns the index of the '}' closing an expression that starts at
+// start, skipping braces inside nested brackets and string literals
+func expressionEnd(src string, start int) (int, error) {
+	depth := 0
+	var quote byte
+	for i := start; i < len(src); i++ {
+		ch := src[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '{' || ch == '[' || ch == '(':
+			depth++
+		case ch == ']' || ch == ')':
+			depth--
+		case ch == '}':
+			if depth == 0 {
+				return i, nil
+			}
+			depth--
+		}
+	}
+	return 0, fmt.Errorf("line %d: '{' is never closed", lineOf(src, start-1))
+}
+
+func lineOf(src string, i int) int {
+	return strings.Count(src[:i], "\n") + 1
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"card-flows/engine"
+)
+
+func (g *Game) AddExportCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "export",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth * 1.5,
+		Height: DefaultCardHeight * 1.5,
+		Color:  ColorCardDefault,
+		Title:  "File:export",
+		Inputs: []Port{
+			{Name: "data", Type: "any"},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// executeExport writes the card's input to its file. Like every card it is cached,
+// so the file is only written again when the input or the settings change.
+func (e *Engine) executeExport(c *Card, inputs map[string]interface{}) (map[string]interface{}, error) {
+	v, ok := inputs["data"]
+	if !ok || v == nil {
+		return nil, fmt.Errorf("the data input is not connected")
+	}
+	res, err := engine.Export(v, c.ParamText("path"), c.ParamText("format"), c.ParamText("mode"), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	c.status = fmt.Sprintf("Wrote %d rows to %s", res.Rows, filepath.Base(res.Path))
+	e.logRun(c, fmt.Sprintf("Wrote %d rows to %s", res.Rows, res.Path), map[string]interface{}{
+		"path":   res.Path,
+		"rows":   res.Rows,
+		"format": c.Param("format"),
+		"mode":   c.Param("mode"),
+	})
+	return map[string]interface{}{}, nil
+}
+
+// ExportNow writes the card's file again even if nothing changed since the last run
+func (g *Game) ExportNow(c *Card) {
+	delete(g.engine.ExecutionCache, c.ID)
+	g.RunEngine()
+}
+
+func (c *Card) exportFields(g *Game) []formField {
+	path := fmt.Sprint(c.Param("path"))
+	return []formField{
+		{Label: "Format", Value: fmt.Sprint(c.Param("format")), Options: engine.ExportFormats, Set: c.setExportFormat},
+		{Label: "Mode", Value: fmt.Sprint(c.Param("mode")), Options: engine.ExportModes, Set: func(v string) {
+			c.setParams(map[string]interface{}{"mode": v})
+		}},
+		{Label: "Path", Value: path, Edit: true, Set: func(v string) {
+			c.setParams(map[string]interface{}{"path": v})
+		}},
+		{Label: "Write now", Action: "export_now"},
+	}
+}
+
+// setExportFormat sets the export format, keeping the file extension in step
+func (c *Card) setExportFormat(format string) {
+	path := fmt.Sprint(c.Param("path"))
+	if ext := filepath.Ext(path); ext != "" && exportFormatOf(ext) != "" {
+		path = strings.TrimSuffix(path, ext) + "." + format
+	}
+	c.setParams(map[string]interface{}{"format": format, "path": path})
+}
+
+// exportFormatOf returns the export format for a file extension, or ""
+func exportFormatOf(ext string) string {
+	for _, f := range engine.ExportFormats {
+		if strings.EqualFold(ext, "."+f) {
+			return f
+		}
+	}
+	return ""
+}
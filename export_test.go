@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"card-flows/engine"
+)
+
+// newExportFlow wires a grid card with salesCells into an export card writing to path
+func newExportFlow(path, format, mode string) (*Game, *Card) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+
+	grid := g.AddGridCard(100, 100)
+	grid.Cells = salesCells
+	ec := g.AddExportCard(100, 400)
+	ec.setParams(map[string]interface{}{"path": path, "format": format, "mode": mode})
+	wireCards(g, grid, "table", ec, "data")
+	return g, ec
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestExportFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"csv", "region,quarter,total,rep\nnorth,Q1,10,ann\nsouth,Q1,5,bob\nnorth,Q2,2.5,ann\nnorth,Q1,1,cy\nsouth,Q2,,bob\n"},
+		{"jsonl", `{"region":"north","quarter":"Q1","total":10,"rep":"ann"}` + "\n"},
+		{"json", "[\n  {\n    \"region\": \"north\",\n    \"quarter\": \"Q1\",\n    \"total\": 10,\n    \"rep\": \"ann\"\n  },\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out."+tt.format)
+			g, ec := newExportFlow(path, tt.format, "overwrite")
+			g.engine.Run()
+			if ec.LastError != "" {
+				t.Fatalf("Unexpected error: %s", ec.LastError)
+			}
+			if got := readFile(t, path); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("Expected file to start with\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExportAppend(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "out.csv")
+	g, ec := newExportFlow(csvPath, "csv", "append")
+	g.engine.Run()
+	g.ExportNow(ec)
+	if ec.LastError != "" {
+		t.Fatalf("Unexpected error: %s", ec.LastError)
+	}
+	got := readFile(t, csvPath)
+	if strings.Count(got, "region,quarter") != 1 || strings.Count(got, "\n") != 11 {
+		t.Errorf("Expected one header and ten rows, got\n%s", got)
+	}
+
+	// Appending different columns is refused and leaves the file alone
+	os.WriteFile(csvPath, []byte("a,b\n1,2\n"), 0o644)
+	g.ExportNow(ec)
+	if !strings.Contains(ec.LastError, "the file has columns a, b") {
+		t.Errorf("Expected a column mismatch error, got %q", ec.LastError)
+	}
+	if readFile(t, csvPath) != "a,b\n1,2\n" {
+		t.Errorf("Expected the file to be unchanged")
+	}
+
+	jsonPath := filepath.Join(dir, "out.json")
+	g, ec = newExportFlow(jsonPath, "json", "append")
+	g.engine.Run()
+	g.ExportNow(ec)
+	if got := readFile(t, jsonPath); strings.Count(got, `"rep"`) != 10 || !strings.HasPrefix(got, "[") {
+		t.Errorf("Expected one JSON array of ten records, got\n%s", got)
+	}
+
+	jsonlPath := filepath.Join(dir, "out.jsonl")
+	g, ec = newExportFlow(jsonlPath, "jsonl", "append")
+	g.engine.Run()
+	g.ExportNow(ec)
+	if got := readFile(t, jsonlPath); strings.Count(got, "\n") != 10 {
+		t.Errorf("Expected ten lines, got\n%s", got)
+	}
+}
+
+func TestExportTimestampedAndRunLog(t *testing.T) {
+	dir := t.TempDir()
+	g, ec := newExportFlow(filepath.Join(dir, "report.csv"), "csv", "timestamped")
+	g.engine.Run()
+	if ec.LastError != "" {
+		t.Fatalf("Unexpected error: %s", ec.LastError)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || !strings.HasPrefix(entries[0].Name(), "report_") || !strings.HasSuffix(entries[0].Name(), ".csv") {
+		t.Fatalf("Expected one timestamped file and no temp files, got %v", entries)
+	}
+	if len(g.engine.RunLog) != 1 {
+		t.Fatalf("Expected one run log entry, got %v", g.engine.RunLog)
+	}
+	entry := g.engine.RunLog[0]
+	if entry.CardID != ec.ID || entry.Data["rows"] != 5 || entry.Data["path"] != filepath.Join(dir, entries[0].Name()) {
+		t.Errorf("Unexpected run log entry %+v", entry)
+	}
+
+	// An unchanged input is not written again
+	g.engine.Run()
+	if len(g.engine.RunLog) != 1 {
+		t.Errorf("Expected the cached export to be skipped, got %d entries", len(g.engine.RunLog))
+	}
+
+	when := time.Date(2024, 1, 31, 15, 4, 5, 0, time.UTC)
+	if got := engine.TimestampedPath("out/data.jsonl", when); got != "out/data_20240131-150405.jsonl" {
+		t.Errorf("Unexpected timestamped path %q", got)
+	}
+}
+
+func TestExportFormatField(t *testing.T) {
+	g, ec := newExportFlow("out.csv", "csv", "overwrite")
+	fields := ec.exportFields(g)
+	fields[0].Set(nextOption(fields[0].Value, fields[0].Options))
+	if ec.Param("format") != "jsonl" || ec.Param("path") != "out.jsonl" {
+		t.Errorf("Expected the extension to follow the format, got %v %v", ec.Param("format"), ec.Param("path"))
+	}
+
+	g, ec = newExportFlow("", "csv", "overwrite")
+	g.engine.Run()
+	if ec.LastError != "set a file path to export to" {
+		t.Errorf("Expected a missing path error, got %q", ec.LastError)
+	}
+}
+
+func TestExportOverwriteKeepsFileMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv")
+	if err := os.WriteFile(path, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	g, ec := newExportFlow(path, "csv", "overwrite")
+	g.engine.Run()
+	if ec.LastError != "" {
+		t.Fatal(ec.LastError)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the file to keep mode 0600, got %v", info.Mode().Perm())
+	}
+	if ec.Text != "" || !strings.HasPrefix(ec.status, "Wrote ") {
+		t.Errorf("Expected the outcome in the status rather than the saved text, got %q and %q", ec.Text, ec.status)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"math"
+
+	"card-flows/transform"
+)
+
+func (g *Game) AddFilterCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "filter",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth * 1.5,
+		Height: DefaultCardHeight * 2,
+		Color:  ColorCardDefault,
+		Title:  "Table:filter",
+		Inputs: []Port{
+			{Name: "table", Type: "table"},
+		},
+		Outputs: []Port{
+			{Name: "result", Type: "table"},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+func (g *Game) AddSortCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "sort",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth * 1.5,
+		Height: DefaultCardHeight * 2,
+		Color:  ColorCardDefault,
+		Title:  "Table:sort",
+		Inputs: []Port{
+			{Name: "table", Type: "table"},
+		},
+		Outputs: []Port{
+			{Name: "result", Type: "table"},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// setParams merges a spec's parameters into the card's params
+func (c *Card) setParams(params map[string]interface{}) {
+	if c.Params == nil {
+		c.Params = map[string]interface{}{}
+	}
+	for k, v := range params {
+		c.Params[k] = v
+	}
+}
+
+func (c *Card) filterFields(g *Game) []formField {
+	columns := g.inputColumns(c, "table")
+	spec := transform.FilterFromParams(c.paramsWithDefaults())
+	save := func() { c.setParams(spec.Params()) }
+
+	match := "all"
+	if spec.MatchAny {
+		match = "any"
+	}
+	fields := []formField{{Label: "Match", Value: match, Options: []string{"all", "any"}, Set: func(v string) {
+		spec.MatchAny = v == "any"
+		save()
+	}}}
+
+	for i, cond := range spec.Conditions {
+		fields = append(fields, columnField("Where", cond.Column, columns, true, func(v string) {
+			if v == transform.None {
+				spec.Conditions = append(spec.Conditions[:i], spec.Conditions[i+1:]...)
+			} else {
+				spec.Conditions[i].Column = v
+			}
+			save()
+		}))
+		fields = append(fields, formField{Label: "  is", Value: cond.Op, Options: transform.FilterOps, Set: func(v string) {
+			spec.Conditions[i].Op = v
+			save()
+		}})
+		if cond.NeedsValue() {
+			fields = append(fields, formField{Label: "  value", Value: cond.Value, Edit: true, Set: func(v string) {
+				spec.Conditions[i].Value = v
+				save()
+			}})
+		}
+	}
+	fields = append(fields, columnField("Where", transform.None, columns, true, func(v string) {
+		if v == transform.None {
+			return
+		}
+		spec.Conditions = append(spec.Conditions, transform.Condition{Column: v, Op: "="})
+		save()
+	}))
+
+	return append(fields, formField{Label: "Eject to script", Action: "eject"})
+}
+
+func (c *Card) sortFields(g *Game) []formField {
+	columns := g.inputColumns(c, "table")
+	spec := transform.SortFromParams(c.paramsWithDefaults())
+	save := func() { c.setParams(spec.Params()) }
+
+	var fields []formField
+	for i, key := range spec.Keys {
+		fields = append(fields, columnField("Sort by", key.Column, columns, true, func(v string) {
+			if v == transform.None {
+				spec.Keys = append(spec.Keys[:i], spec.Keys[i+1:]...)
+			} else {
+				spec.Keys[i].Column = v
+			}
+			save()
+		}))
+		fields = append(fields, formField{Label: "  order", Value: key.Direction(), Options: transform.SortDirections, Set: func(v string) {
+			spec.Keys[i].Descending = v == "descending"
+			save()
+		}})
+	}
+	fields = append(fields, columnField("Sort by", transform.None, columns, true, func(v string) {
+		if v == transform.None {
+			return
+		}
+		spec.Keys = append(spec.Keys, transform.SortKey{Column: v})
+		save()
+	}))
+
+	return append(fields, formField{Label: "Eject to script", Action: "eject"})
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"card-flows/engine"
+	"card-flows/transform"
+)
+
+// newTableFlow wires a grid card with salesCells into a new card created by add
+func newTableFlow(add func(g *Game) *Card) (*Game, *Card) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+
+	grid := g.AddGridCard(100, 100)
+	grid.Cells = salesCells
+	c := add(g)
+	wireCards(g, grid, "table", c, "table")
+	return g, c
+}
+
+// resultColumn returns one column of a card's result table
+func resultColumn(t *testing.T, g *Game, c *Card, column string) []interface{} {
+	t.Helper()
+	if c.LastError != "" {
+		t.Fatalf("Unexpected error: %s", c.LastError)
+	}
+	table := g.engine.Memory[c.ID+":result"].(*engine.Table)
+	i := table.ColumnIndex(column)
+	if i < 0 {
+		t.Fatalf("Column %q missing from %v", column, table.Columns)
+	}
+	return table.Data[i]
+}
+
+func TestFilterConditions(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter transform.Filter
+		want   []interface{} // the rep column of the kept rows
+	}{
+		{"number equals", transform.Filter{Conditions: []transform.Condition{{Column: "total", Op: "=", Value: "5"}}}, []interface{}{"bob"}},
+		{"text equals ignores case", transform.Filter{Conditions: []transform.Condition{{Column: "region", Op: "=", Value: "NORTH"}}}, []interface{}{"ann", "ann", "cy"}},
+		{"greater than", transform.Filter{Conditions: []transform.Condition{{Column: "total", Op: ">", Value: "2"}}}, []interface{}{"ann", "bob", "ann"}},
+		{"contains", transform.Filter{Conditions: []transform.Condition{{Column: "quarter", Op: "contains", Value: "2"}}}, []interface{}{"ann", "bob"}},
+		{"is empty", transform.Filter{Conditions: []transform.Condition{{Column: "total", Op: "is empty"}}}, []interface{}{"bob"}},
+		{"all", transform.Filter{Conditions: []transform.Condition{
+			{Column: "region", Op: "=", Value: "north"},
+			{Column: "quarter", Op: "=", Value: "Q1"},
+		}}, []interface{}{"ann", "cy"}},
+		{"any", transform.Filter{MatchAny: true, Conditions: []transform.Condition{
+			{Column: "rep", Op: "starts with", Value: "c"},
+			{Column: "total", Op: "<=", Value: "2.5"},
+		}}, []interface{}{"ann", "cy"}},
+		{"no conditions", transform.Filter{}, []interface{}{"ann", "bob", "ann", "cy", "bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, fc := newTableFlow(func(g *Game) *Card { return g.AddFilterCard(100, 400) })
+			fc.setParams(tt.filter.Params())
+			g.engine.Run()
+			if got := resultColumn(t, g, fc, "rep"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSortKeys(t *testing.T) {
+	g, sc := newTableFlow(func(g *Game) *Card { return g.AddSortCard(100, 400) })
+	sc.setParams(transform.Sort{Keys: []transform.SortKey{
+		{Column: "region", Descending: true},
+		{Column: "total"},
+	}}.Params())
+	g.engine.Run()
+
+	// Blank totals sort last within their region
+	if got, want := resultColumn(t, g, sc, "total"), []interface{}{5, nil, 1, 2.5, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected totals %v, got %v", want, got)
+	}
+}
+
+func TestFilterSortValidation(t *testing.T) {
+	g, fc := newTableFlow(func(g *Game) *Card { return g.AddFilterCard(100, 400) })
+	fc.setParams(transform.Filter{Conditions: []transform.Condition{{Column: "amount", Op: ">", Value: "1"}}}.Params())
+	g.engine.Run()
+	if !strings.Contains(fc.LastError, `column "amount" is not in the input table (columns: region, quarter, total, rep)`) {
+		t.Errorf("Expected a missing column error, got %q", fc.LastError)
+	}
+	if fields := fc.filterFields(g); !fields[1].Invalid {
+		t.Errorf("Expected the Where field to be flagged, got %+v", fields[1])
+	}
+
+	g, sc := newTableFlow(func(g *Game) *Card { return g.AddSortCard(100, 400) })
+	sc.setParams(transform.Sort{Keys: []transform.SortKey{{Column: "amount"}}}.Params())
+	g.engine.Run()
+	if !strings.Contains(sc.LastError, `column "amount" is not in the input table`) {
+		t.Errorf("Expected a missing column error, got %q", sc.LastError)
+	}
+}
+
+func TestFilterSortAddRowWithoutInput(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	fc := g.AddFilterCard(100, 100)
+	sc := g.AddSortCard(400, 100)
+
+	// Clicking an add row only cycles through the empty choice, which adds nothing
+	for _, c := range []*Card{fc, sc} {
+		for i, f := range c.formFields(g) {
+			if f.Value == transform.None {
+				x, y := c.formOrigin()
+				g.clickFormField(c, x+1, y+(float64(i)+0.5)*FormRowHeight)
+			}
+		}
+	}
+	if spec := transform.FilterFromParams(fc.ResolvedParams()); len(spec.Conditions) != 0 {
+		t.Errorf("Expected no conditions, got %+v", spec.Conditions)
+	}
+	if spec := transform.SortFromParams(sc.ResolvedParams()); len(spec.Keys) != 0 {
+		t.Errorf("Expected no sort keys, got %+v", spec.Keys)
+	}
+}
+
+func TestFilterValueEditing(t *testing.T) {
+	g, fc := newTableFlow(func(g *Game) *Card { return g.AddFilterCard(100, 400) })
+	fc.setParams(transform.Filter{Conditions: []transform.Condition{{Column: "total", Op: ">"}}}.Params())
+	g.engine.Run()
+
+	// Select the value row, then type into it as the input system does
+	fc.FormField = 3
+	if f := fc.editedFormField(g); f == nil || f.Label != "  value" {
+		t.Fatalf("Expected the value field to be editable, got %+v", f)
+	}
+	g.SetCardText(fc, g.GetCardText(fc)+"4")
+	g.engine.Run()
+
+	if got, want := resultColumn(t, g, fc, "total"), []interface{}{10, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected totals %v, got %v", want, got)
+	}
+}
+
+func TestFilterSortSaveLoad(t *testing.T) {
+	filename := "test_filter_state.yaml"
+	defer os.Remove(filename)
+
+	g, fc := newTableFlow(func(g *Game) *Card { return g.AddFilterCard(100, 400) })
+	want := transform.Filter{MatchAny: true, Conditions: []transform.Condition{{Column: "rep", Op: "=", Value: "bob"}}}
+	fc.setParams(want.Params())
+	sc := g.AddSortCard(400, 400)
+	sc.setParams(transform.Sort{Keys: []transform.SortKey{{Column: "total", Descending: true}}}.Params())
+	wireCards(g, fc, "result", sc, "table")
+
+	if err := SaveState(g, filename); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+	g2 := NewGame()
+	g2.cards = []*Card{}
+	g2.arrows = []*Arrow{}
+	if err := LoadState(g2, filename); err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+
+	if got := transform.FilterFromParams(g2.getCardByID(fc.ID).Params); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected filter %+v, got %+v", want, got)
+	}
+	g2.engine.Run()
+	if got, want := resultColumn(t, g2, g2.getCardByID(sc.ID), "total"), []interface{}{5, nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected totals %v, got %v", want, got)
+	}
+}
@@ -151,3 +151,97 @@ func TestFindReplaceCache(t *testing.T) {
 		t.Errorf("Expected 'new text', got '%s'", frCard.Text)
 	}
 }
+
+// wireCards appends an arrow between two card ports
+func wireCards(g *Game, from *Card, fromPort string, to *Card, toPort string) {
+	g.arrows = append(g.arrows, &Arrow{
+		FromCardID: from.ID,
+		FromPort:   fromPort,
+		ToCardID:   to.ID,
+		ToPort:     toPort,
+		Color:      ColorArrowDefault,
+	})
+}
+
+// newFindReplaceFlow builds input/find/replace text cards wired into a find/replace card
+func newFindReplaceFlow(input, find, replace string) (*Game, *Card) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+
+	inputCard := g.AddTextCard(100, 100)
+	inputCard.Text = input
+	findCard := g.AddTextCard(300, 200)
+	findCard.Text = find
+	replaceCard := g.AddTextCard(300, 400)
+	replaceCard.Text = replace
+	frCard := g.AddFindReplaceCard(100, 300)
+
+	wireCards(g, inputCard, "text", frCard, "input")
+	wireCards(g, findCard, "text", frCard, "find")
+	wireCards(g, replaceCard, "text", frCard, "replace")
+	return g, frCard
+}
+
+func TestFindReplaceRegexGroups(t *testing.T) {
+	g, frCard := newFindReplaceFlow("Doe, John; Roe, Jane", `(\w+), (\w+)`, `\2 $1`)
+	frCard.Params["regex"] = true
+
+	g.engine.Run()
+
+	if frCard.Text != "John Doe; Jane Roe" {
+		t.Errorf("Expected 'John Doe; Jane Roe', got '%s'", frCard.Text)
+	}
+	if count := g.engine.Memory[frCard.ID+":count"]; count != 2 {
+		t.Errorf("Expected count 2, got %v", count)
+	}
+	matches, ok := g.engine.Memory[frCard.ID+":matches"].([]interface{})
+	if !ok || len(matches) != 2 || matches[0] != "Doe, John" {
+		t.Errorf("Unexpected matches: %v", g.engine.Memory[frCard.ID+":matches"])
+	}
+}
+
+func TestFindReplaceModes(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		input  string
+		find   string
+		want   string
+		count  int
+	}{
+		{"literal ignores regex syntax", map[string]interface{}{}, "abc a.c", "a.c", "abc X", 1},
+		{"ignore case", map[string]interface{}{"ignore_case": true}, "cat Cat concat", "CAT", "X X conX", 3},
+		{"whole word", map[string]interface{}{"whole_word": true}, "cat Cat concat", "cat", "X Cat concat", 1},
+		{"max count", map[string]interface{}{"ignore_case": true, "max_count": 1}, "cat Cat concat", "cat", "X Cat concat", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, frCard := newFindReplaceFlow(tt.input, tt.find, "X")
+			frCard.Params = tt.params
+
+			g.engine.Run()
+
+			if frCard.Text != tt.want {
+				t.Errorf("Expected '%s', got '%s'", tt.want, frCard.Text)
+			}
+			if count := g.engine.Memory[frCard.ID+":count"]; count != tt.count {
+				t.Errorf("Expected count %d, got %v", tt.count, count)
+			}
+		})
+	}
+}
+
+func TestFindReplaceParamsInvalidateCache(t *testing.T) {
+	g, frCard := newFindReplaceFlow("a1 b2", `\d`, "#")
+	g.engine.Run()
+	if frCard.Text != "a1 b2" {
+		t.Fatalf("Expected literal mode to leave text unchanged, got '%s'", frCard.Text)
+	}
+
+	frCard.Params["regex"] = true
+	g.engine.Run()
+	if frCard.Text != "a# b#" {
+		t.Errorf("Expected 'a# b#' after enabling regex, got '%s'", frCard.Text)
+	}
+}
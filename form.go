@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"card-flows/engine"
+	"card-flows/transform"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// formField is one row of a form-configured card. Clicking the row selects the
+// next option, like a dropdown without the popup. Rows with an Action run it instead,
+// and Edit rows are typed into after a double-click.
+type formField struct {
+	Label   string
+	Value   string
+	Options []string
+	Set     func(value string)
+	Invalid bool   // the value refers to a column the input table does not have
+	Action  string // card action performed when the row is clicked
+	Edit    bool   // free text entered with the keyboard
+	Secret  bool   // the value names a secret, shown with its value masked
+}
+
+// formFields returns the settings form of a card, or nil for cards without one
+func (c *Card) formFields(g *Game) []formField {
+	if c.settingsOpen && c.HasSettings() {
+		return c.settingsFields(g)
+	}
+	switch c.Type {
+	case "groupby":
+		return c.groupByFields(g)
+	case "filter":
+		return c.filterFields(g)
+	case "sort":
+		return c.sortFields(g)
+	case "chart":
+		return c.chartFields(g)
+	case "export":
+		return c.exportFields(g)
+	case "xlsx_import":
+		return c.xlsxImportFields(g)
+	case "xlsx_export":
+		return c.xlsxExportFields(g)
+	case "template":
+		return c.templateFields(g)
+	case "composite":
+		return c.compositeFields(g)
+	case "flow_input", "flow_output":
+		return c.flowPortFields(g)
+	case "call_flow":
+		return c.callFlowFields(g)
+	case "map":
+		return c.mapFields(g)
+	case "llm":
+		return c.llmFields(g)
+	}
+	return nil
+}
+
+// validateForm checks a form-configured card against the columns of its input table
+// so that bad column references are reported before the script runs
+func validateForm(c *Card, inputs map[string]interface{}) error {
+	var columns []string
+	if t, ok := inputs["table"].(*engine.Table); ok {
+		columns = t.Columns
+	}
+	switch c.Type {
+	case "groupby":
+		return transform.GroupByFromParams(c.ResolvedParams()).Validate(columns)
+	case "filter":
+		return transform.FilterFromParams(c.ResolvedParams()).Validate(columns)
+	case "sort":
+		return transform.SortFromParams(c.ResolvedParams()).Validate(columns)
+	}
+	return nil
+}
+
+// inputColumns returns the column names of the table wired into a port,
+// or nil if the input is not a table or has not run yet
+func (g *Game) inputColumns(c *Card, port string) []string {
+	for _, a := range g.arrows {
+		if a.ToCardID != c.ID || a.ToPort != port {
+			continue
+		}
+		if t, ok := g.engine.Memory[a.FromCardID+":"+a.FromPort].(*engine.Table); ok {
+			return t.Columns
+		}
+	}
+	return nil
+}
+
+// columnField builds a field choosing one of the input columns
+func columnField(label, value string, columns []string, allowNone bool, set func(string)) formField {
+	var options []string
+	if allowNone {
+		options = append(options, transform.None)
+	}
+	options = append(options, columns...)
+	return formField{
+		Label:   label,
+		Value:   value,
+		Options: options,
+		Set:     set,
+		Invalid: value != transform.None && !transform.HasColumn(value, columns),
+	}
+}
+
+// nextOption returns the option after the current value, wrapping around
+func nextOption(current string, options []string) string {
+	for i, o := range options {
+		if o == current {
+			return options[(i+1)%len(options)]
+		}
+	}
+	return options[0]
+}
+
+// formOrigin returns the world position of the first form row
+func (c *Card) formOrigin() (float64, float64) {
+	x := c.X + CardPaddingX
+	if len(c.Inputs) > 0 {
+		x += c.Width / 3.0
+	}
+	return x, c.Y + HeaderHeight + CardPaddingY
+}
+
+// formFieldAt returns the index of the form row under the world position, or -1
+func (c *Card) formFieldAt(g *Game, wx, wy float64) int {
+	fields := c.formFields(g)
+	x, y := c.formOrigin()
+	if wx < x || wx > c.X+c.Width || wy < y {
+		return -1
+	}
+	i := int((wy - y) / FormRowHeight)
+	if i >= len(fields) {
+		return -1
+	}
+	return i
+}
+
+// clickFormField advances the clicked field to its next option and reruns the flow.
+// It returns false for text fields, which are edited with a double-click instead.
+func (g *Game) clickFormField(c *Card, wx, wy float64) bool {
+	i := c.formFieldAt(g, wx, wy)
+	if i < 0 {
+		return true
+	}
+	f := c.formFields(g)[i]
+	if f.Edit {
+		c.FormField = i
+		return false
+	}
+	if f.Action != "" {
+		return g.PerformCardAction(c, f.Action, wx, wy)
+	}
+	if f.Set == nil || len(f.Options) == 0 {
+		return true
+	}
+	f.Set(nextOption(f.Value, f.Options))
+	g.RunEngine()
+	return true
+}
+
+// editedFormField returns the text field selected for editing, or nil
+func (c *Card) editedFormField(g *Game) *formField {
+	fields := c.formFields(g)
+	if c.FormField < 0 || c.FormField >= len(fields) || !fields[c.FormField].Edit {
+		return nil
+	}
+	return &fields[c.FormField]
+}
+
+func (c *Card) drawForm(screen *ebiten.Image, g *Game, cw, ch float64) {
+	zoom := g.camera.Zoom
+	x, y := c.formOrigin()
+	width := c.X + c.Width - CardPaddingX - x
+	editing := g.input != nil && g.input.EditingCard == c
+	for i, f := range c.formFields(g) {
+		sx, sy := g.camera.WorldToScreen(x, y+float64(i)*FormRowHeight, cw, ch)
+		label := fmt.Sprintf("%s: [%s]", f.Label, f.Value)
+		if f.Action != "" {
+			label = f.Label
+		} else if f.Edit {
+			value := f.Value
+			if f.Secret && !(editing && i == c.FormField) {
+				value = secretLabel(value)
+			}
+			if editing && i == c.FormField && (time.Now().UnixMilli()/CursorBlinkRate)%2 == 0 {
+				value += "|"
+			}
+			label = fmt.Sprintf("%s: %s", f.Label, value)
+		}
+		vector.DrawFilledRect(screen, float32(sx), float32(sy), float32(width*zoom), float32((FormRowHeight-2)*zoom), ColorButtonBackground, false)
+		var clr color.Color = color.White
+		if f.Invalid {
+			clr = ColorErrorText
+		}
+		DrawTextLines(screen, g.FontFace, label, int(sx+4*zoom), int(sy+2*zoom), clr)
+	}
+
+	// Last execution error or the card's status, e.g. the last export, below the form
+	n := len(c.formFields(g))
+	sx, sy := g.camera.WorldToScreen(x, y+float64(n)*FormRowHeight, cw, ch)
+	if c.LastError != "" {
+		DrawTextLines(screen, g.FontFace, c.LastError, int(sx), int(sy), ColorErrorText)
+	} else if c.status != "" {
+		DrawTextLines(screen, g.FontFace, c.status, int(sx), int(sy), ColorStatusText)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+
+	"card-flows/formula"
+)
+
+func (g *Game) AddFormulaCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "formula",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth * 1.5,
+		Height: DefaultCardHeight * 1.5, // Taller for 3 inputs
+		Color:  ColorCardDefault,
+		Title:  "Formula",
+		Text:   "=UPPER(TRIM(input))",
+		Inputs: []Port{
+			{Name: "input", Type: "any"},
+			{Name: "a", Type: "any"},
+			{Name: "b", Type: "any"},
+		},
+		Outputs: []Port{
+			{Name: "result", Type: "any"},
+		},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// formulaScript compiles the card's formula into Starlark, checking it against the card's input ports
+func formulaScript(c *Card) (string, error) {
+	inputs := make([]string, len(c.Inputs))
+	for i, p := range c.Inputs {
+		inputs[i] = p.Name
+	}
+	output := "result"
+	if len(c.Outputs) > 0 {
+		output = c.Outputs[0].Name
+	}
+	return formula.Compile(c.Text, inputs, output)
+}
@@ -0,0 +1,200 @@
+package formula
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// NameError is reported when a formula refers to an unknown function or input (Excel's #NAME?)
+type NameError struct {
+	Msg string
+}
+
+func (e *NameError) Error() string { return "#NAME? " + e.Msg }
+
+// ArgumentError is reported when a function gets the wrong number of arguments
+type ArgumentError struct {
+	Msg string
+}
+
+func (e *ArgumentError) Error() string { return e.Msg }
+
+// Compile parses a formula, checks it against the names of the card's inputs and
+// returns a Starlark script that stores the formula's value in the global named output.
+// A source that does not start with "=" is a constant, as in a spreadsheet cell.
+func Compile(src string, inputs []string, output string) (string, error) {
+	trimmed := strings.TrimSpace(src)
+	if !strings.HasPrefix(trimmed, "=") {
+		return fmt.Sprintf("%s = %s\n", output, constant(trimmed)), nil
+	}
+
+	node, err := Parse(trimmed[1:])
+	if err != nil {
+		return "", err
+	}
+	c := &compiler{inputs: make(map[string]string)}
+	for _, name := range inputs {
+		c.inputs[strings.ToLower(name)] = name
+		c.inputNames = append(c.inputNames, name)
+	}
+	expr, err := c.compile(node)
+	if err != nil {
+		return "", err
+	}
+	return library + fmt.Sprintf("\n%s = _xl_result(%s)\n", output, expr), nil
+}
+
+// Explain rewrites a Starlark execution error raised by a compiled formula in spreadsheet terms.
+func Explain(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		msg = evalErr.Msg
+	}
+	msg = strings.TrimPrefix(msg, "fail: ")
+	if strings.HasPrefix(msg, "#") {
+		return errors.New(msg)
+	}
+	return errors.New("#VALUE! " + msg)
+}
+
+// constant converts a literal cell value to a Starlark expression
+func constant(s string) string {
+	if s == "" {
+		return "None"
+	}
+	if i, err := strconv.Atoi(s); err == nil {
+		return strconv.Itoa(i)
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	switch strings.ToUpper(s) {
+	case "TRUE":
+		return "True"
+	case "FALSE":
+		return "False"
+	}
+	return strconv.Quote(s)
+}
+
+// arity describes the accepted argument counts of a function; Max -1 means unlimited
+type arity struct{ Min, Max int }
+
+// functions lists every spreadsheet function the library implements
+var functions = map[string]arity{
+	// Text
+	"UPPER": {1, 1}, "LOWER": {1, 1}, "PROPER": {1, 1}, "TRIM": {1, 1}, "LEN": {1, 1},
+	"LEFT": {1, 2}, "RIGHT": {1, 2}, "MID": {3, 3}, "FIND": {2, 3}, "SEARCH": {2, 3},
+	"SUBSTITUTE": {3, 4}, "REPT": {2, 2}, "EXACT": {2, 2}, "VALUE": {1, 1},
+	"CONCAT": {1, -1}, "CONCATENATE": {1, -1}, "TEXTJOIN": {3, -1},
+	// Math
+	"SUM": {1, -1}, "PRODUCT": {1, -1}, "AVERAGE": {1, -1}, "MIN": {1, -1}, "MAX": {1, -1},
+	"COUNT": {1, -1}, "COUNTA": {1, -1}, "ROUND": {1, 2}, "ROUNDUP": {1, 2}, "ROUNDDOWN": {1, 2},
+	"ABS": {1, 1}, "INT": {1, 1}, "MOD": {2, 2}, "POWER": {2, 2}, "SQRT": {1, 1},
+	// Logical
+	"IF": {2, 3}, "AND": {1, -1}, "OR": {1, -1}, "NOT": {1, 1},
+	"ISBLANK": {1, 1}, "ISNUMBER": {1, 1}, "ISTEXT": {1, 1},
+	// Date
+	"TODAY": {0, 0}, "NOW": {0, 0}, "DATE": {3, 3}, "YEAR": {1, 1}, "MONTH": {1, 1}, "DAY": {1, 1},
+	"HOUR": {1, 1}, "MINUTE": {1, 1}, "SECOND": {1, 1}, "DAYS": {2, 2}, "EDATE": {2, 2},
+}
+
+var binaryHelpers = map[string]string{
+	"+": "_xl_add", "-": "_xl_sub", "*": "_xl_mul", "/": "_xl_div", "^": "_xl_pow",
+}
+
+type compiler struct {
+	inputs     map[string]string // lower-cased name -> port name
+	inputNames []string
+}
+
+func (c *compiler) compile(n Node) (string, error) {
+	switch n := n.(type) {
+	case *Number:
+		return strconv.FormatFloat(n.Value, 'g', -1, 64), nil
+	case *String:
+		return strconv.Quote(n.Value), nil
+	case *Bool:
+		if n.Value {
+			return "True", nil
+		}
+		return "False", nil
+	case *Ref:
+		name, ok := c.inputs[strings.ToLower(n.Name)]
+		if !ok {
+			return "", &NameError{fmt.Sprintf("%q is not an input of this card (inputs: %s)", n.Name, strings.Join(c.inputNames, ", "))}
+		}
+		return name, nil
+	case *Unary:
+		x, err := c.compile(n.X)
+		if err != nil {
+			return "", err
+		}
+		switch n.Op {
+		case "-":
+			return fmt.Sprintf("_xl_sub(0, %s)", x), nil
+		case "+":
+			return fmt.Sprintf("_xl_num(%s)", x), nil
+		}
+		return fmt.Sprintf("_xl_div(%s, 100)", x), nil
+	case *Binary:
+		l, err := c.compile(n.L)
+		if err != nil {
+			return "", err
+		}
+		r, err := c.compile(n.R)
+		if err != nil {
+			return "", err
+		}
+		if helper, ok := binaryHelpers[n.Op]; ok {
+			return fmt.Sprintf("%s(%s, %s)", helper, l, r), nil
+		}
+		if n.Op == "&" {
+			return fmt.Sprintf("(_xl_text(%s) + _xl_text(%s))", l, r), nil
+		}
+		return fmt.Sprintf("_xl_cmp(%s, %s, %q)", l, r, n.Op), nil
+	case *Call:
+		return c.compileCall(n)
+	}
+	return "", fmt.Errorf("unsupported expression %T", n)
+}
+
+func (c *compiler) compileCall(n *Call) (string, error) {
+	a, ok := functions[n.Name]
+	if !ok {
+		return "", &NameError{fmt.Sprintf("unknown function %s", n.Name)}
+	}
+	if len(n.Args) < a.Min {
+		return "", &ArgumentError{fmt.Sprintf("You've entered too few arguments for %s: it needs at least %d", n.Name, a.Min)}
+	}
+	if a.Max >= 0 && len(n.Args) > a.Max {
+		return "", &ArgumentError{fmt.Sprintf("You've entered too many arguments for %s: it accepts at most %d", n.Name, a.Max)}
+	}
+
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		s, err := c.compile(arg)
+		if err != nil {
+			return "", err
+		}
+		args[i] = s
+	}
+
+	// IF only evaluates the chosen branch
+	if n.Name == "IF" {
+		otherwise := "False"
+		if len(args) == 3 {
+			otherwise = args[2]
+		}
+		return fmt.Sprintf("(%s if _xl_bool(%s) else %s)", args[1], args[0], otherwise), nil
+	}
+	return fmt.Sprintf("_xl_%s(%s)", n.Name, strings.Join(args, ", ")), nil
+}
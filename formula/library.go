@@ -0,0 +1,385 @@
+package formula
+
+// library is the Starlark prelude that implements spreadsheet semantics for compiled
+// formulas. It relies on the re, math and time modules predeclared by the engine.
+// Dates are exchanged as ISO strings ("2024-01-31" or "2024-01-31 13:45:00").
+const library = `
+_XL_INT = r"^[-+]?\d+$"
+_XL_FLOAT = r"^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$"
+_XL_DATE = r"^\d{4}-\d{2}-\d{2}$"
+_XL_DATETIME = r"^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}$"
+
+def _xl_error(code, msg):
+    fail(code + " " + msg)
+
+def _xl_is_number(v):
+    t = type(v)
+    if t == "int" or t == "float":
+        return True
+    if t == "string" and re.findall(_XL_FLOAT, v.strip()):
+        return True
+    return False
+
+def _xl_num(v):
+    t = type(v)
+    if v == None:
+        return 0
+    if t == "bool":
+        return 1 if v else 0
+    if t == "int" or t == "float":
+        return v
+    if t == "string":
+        s = v.strip()
+        if s == "":
+            return 0
+        if re.findall(_XL_INT, s):
+            return int(s)
+        if re.findall(_XL_FLOAT, s):
+            return float(s)
+    _xl_error("#VALUE!", "%s is not a number" % repr(v))
+
+def _xl_text(v):
+    t = type(v)
+    if v == None:
+        return ""
+    if t == "bool":
+        return "TRUE" if v else "FALSE"
+    if t == "float" and v == math.floor(v) and math.fabs(v) < 1e15:
+        return str(int(v))
+    return str(v)
+
+def _xl_bool(v):
+    t = type(v)
+    if v == None:
+        return False
+    if t == "bool":
+        return v
+    if t == "int" or t == "float":
+        return v != 0
+    if t == "string":
+        u = v.strip().upper()
+        if u == "TRUE":
+            return True
+        if u == "FALSE" or u == "":
+            return False
+    _xl_error("#VALUE!", "%s is not TRUE or FALSE" % repr(v))
+
+def _xl_result(v):
+    if type(v) == "float" and v == math.floor(v) and math.fabs(v) < 1e15:
+        return int(v)
+    return v
+
+def _xl_flat(args):
+    out = []
+    for a in args:
+        if type(a) == "list" or type(a) == "tuple":
+            out.extend(a)
+        else:
+            out.append(a)
+    return out
+
+def _xl_numbers(args):
+    # Like spreadsheet ranges, blanks and non-numeric text inside lists are skipped
+    out = []
+    for a in args:
+        if type(a) == "list" or type(a) == "tuple":
+            out.extend([_xl_num(x) for x in a if x != None and type(x) != "bool" and _xl_is_number(x)])
+        else:
+            out.append(_xl_num(a))
+    return out
+
+def _xl_chars(s):
+    return [c for c in _xl_text(s).codepoints()]
+
+def _xl_count_arg(n):
+    n = _xl_num(n)
+    if n < 0:
+        _xl_error("#VALUE!", "the number of characters cannot be negative")
+    return int(n)
+
+# Operators
+
+def _xl_add(a, b):
+    return _xl_num(a) + _xl_num(b)
+
+def _xl_sub(a, b):
+    return _xl_num(a) - _xl_num(b)
+
+def _xl_mul(a, b):
+    return _xl_num(a) * _xl_num(b)
+
+def _xl_div(a, b):
+    d = _xl_num(b)
+    if d == 0:
+        _xl_error("#DIV/0!", "division by zero")
+    return _xl_num(a) / d
+
+def _xl_pow(a, b):
+    return math.pow(_xl_num(a), _xl_num(b))
+
+def _xl_key(v):
+    t = type(v)
+    if v == None:
+        return (0, 0)
+    if t == "bool":
+        return (2, 1 if v else 0)
+    if t == "string":
+        return (1, v.lower())
+    return (0, v)
+
+def _xl_cmp(a, b, op):
+    if a == None and type(b) == "string":
+        a = ""
+    if b == None and type(a) == "string":
+        b = ""
+    ka, kb = _xl_key(a), _xl_key(b)
+    if op == "=":
+        return ka == kb
+    if op == "<>":
+        return ka != kb
+    if op == "<":
+        return ka < kb
+    if op == ">":
+        return ka > kb
+    if op == "<=":
+        return ka <= kb
+    return ka >= kb
+
+# Text
+
+def _xl_UPPER(s):
+    return _xl_text(s).upper()
+
+def _xl_LOWER(s):
+    return _xl_text(s).lower()
+
+def _xl_PROPER(s):
+    return _xl_text(s).title()
+
+def _xl_TRIM(s):
+    return " ".join([w for w in _xl_text(s).split(" ") if w != ""])
+
+def _xl_LEN(s):
+    return len(_xl_chars(s))
+
+def _xl_LEFT(s, n = 1):
+    return "".join(_xl_chars(s)[:_xl_count_arg(n)])
+
+def _xl_RIGHT(s, n = 1):
+    chars = _xl_chars(s)
+    n = _xl_count_arg(n)
+    if n == 0:
+        return ""
+    return "".join(chars[-n:])
+
+def _xl_MID(s, start, n):
+    start = int(_xl_num(start))
+    if start < 1:
+        _xl_error("#VALUE!", "MID start must be at least 1")
+    return "".join(_xl_chars(s)[start - 1:start - 1 + _xl_count_arg(n)])
+
+def _xl_position(needle, hay, start, ignore_case, fn):
+    hay_chars = _xl_chars(hay)
+    needle_chars = _xl_chars(needle)
+    start = int(_xl_num(start))
+    if start < 1 or start > len(hay_chars) + 1:
+        _xl_error("#VALUE!", "%s start is out of range" % fn)
+    h = "".join(hay_chars[start - 1:])
+    n = "".join(needle_chars)
+    if ignore_case:
+        h, n = h.lower(), n.lower()
+    i = h.find(n)
+    if i < 0:
+        _xl_error("#VALUE!", "%s could not find %s" % (fn, repr(_xl_text(needle))))
+    return start + len([c for c in h[:i].codepoints()])
+
+def _xl_FIND(needle, hay, start = 1):
+    return _xl_position(needle, hay, start, False, "FIND")
+
+def _xl_SEARCH(needle, hay, start = 1):
+    return _xl_position(needle, hay, start, True, "SEARCH")
+
+def _xl_SUBSTITUTE(s, old, new, instance = None):
+    s, old, new = _xl_text(s), _xl_text(old), _xl_text(new)
+    if old == "":
+        return s
+    if instance == None:
+        return s.replace(old, new)
+    n = int(_xl_num(instance))
+    if n < 1:
+        _xl_error("#VALUE!", "SUBSTITUTE instance must be at least 1")
+    parts = s.split(old)
+    if n >= len(parts):
+        return s
+    return old.join(parts[:n]) + new + old.join(parts[n:])
+
+def _xl_REPT(s, n):
+    return _xl_text(s) * _xl_count_arg(n)
+
+def _xl_EXACT(a, b):
+    return _xl_text(a) == _xl_text(b)
+
+def _xl_VALUE(s):
+    if type(s) == "string" and s.strip() == "":
+        _xl_error("#VALUE!", "empty text is not a number")
+    return _xl_num(s)
+
+def _xl_CONCAT(*args):
+    return "".join([_xl_text(a) for a in _xl_flat(args)])
+
+def _xl_CONCATENATE(*args):
+    return _xl_CONCAT(*args)
+
+def _xl_TEXTJOIN(delimiter, ignore_empty, *args):
+    items = [_xl_text(a) for a in _xl_flat(args)]
+    if _xl_bool(ignore_empty):
+        items = [i for i in items if i != ""]
+    return _xl_text(delimiter).join(items)
+
+# Math
+
+def _xl_SUM(*args):
+    total = 0
+    for n in _xl_numbers(args):
+        total += n
+    return total
+
+def _xl_PRODUCT(*args):
+    total = 1
+    for n in _xl_numbers(args):
+        total *= n
+    return total
+
+def _xl_AVERAGE(*args):
+    nums = _xl_numbers(args)
+    if len(nums) == 0:
+        _xl_error("#DIV/0!", "AVERAGE of no numbers")
+    return _xl_SUM(nums) / len(nums)
+
+def _xl_MIN(*args):
+    nums = _xl_numbers(args)
+    return min(nums) if nums else 0
+
+def _xl_MAX(*args):
+    nums = _xl_numbers(args)
+    return max(nums) if nums else 0
+
+def _xl_COUNT(*args):
+    return len([a for a in _xl_flat(args) if type(a) != "bool" and a != None and _xl_is_number(a)])
+
+def _xl_COUNTA(*args):
+    return len([a for a in _xl_flat(args) if a != None and a != ""])
+
+def _xl_ROUND(x, digits = 0):
+    f = math.pow(10, int(_xl_num(digits)))
+    return math.round(_xl_num(x) * f) / f
+
+def _xl_ROUNDUP(x, digits = 0):
+    f = math.pow(10, int(_xl_num(digits)))
+    v = _xl_num(x) * f
+    return (math.ceil(v) if v >= 0 else math.floor(v)) / f
+
+def _xl_ROUNDDOWN(x, digits = 0):
+    f = math.pow(10, int(_xl_num(digits)))
+    v = _xl_num(x) * f
+    return (math.floor(v) if v >= 0 else math.ceil(v)) / f
+
+def _xl_ABS(x):
+    return math.fabs(_xl_num(x))
+
+def _xl_INT(x):
+    return math.floor(_xl_num(x))
+
+def _xl_MOD(a, b):
+    a, b = _xl_num(a), _xl_num(b)
+    if b == 0:
+        _xl_error("#DIV/0!", "MOD by zero")
+    return a - b * math.floor(a / b)
+
+def _xl_POWER(a, b):
+    return _xl_pow(a, b)
+
+def _xl_SQRT(x):
+    x = _xl_num(x)
+    if x < 0:
+        _xl_error("#NUM!", "SQRT of a negative number")
+    return math.sqrt(x)
+
+# Logical
+
+def _xl_AND(*args):
+    result = True
+    for a in _xl_flat(args):
+        result = _xl_bool(a) and result
+    return result
+
+def _xl_OR(*args):
+    result = False
+    for a in _xl_flat(args):
+        result = _xl_bool(a) or result
+    return result
+
+def _xl_NOT(v):
+    return not _xl_bool(v)
+
+def _xl_ISBLANK(v):
+    return v == None or v == ""
+
+def _xl_ISNUMBER(v):
+    return type(v) == "int" or type(v) == "float"
+
+def _xl_ISTEXT(v):
+    return type(v) == "string"
+
+# Date
+
+def _xl_date(v):
+    if type(v) == "time.time":
+        return v
+    s = _xl_text(v).strip()
+    if re.findall(_XL_DATE, s):
+        return time.parse_time(s, format = "2006-01-02")
+    if re.findall(_XL_DATETIME, s):
+        return time.parse_time(s, format = "2006-01-02 15:04:05")
+    _xl_error("#VALUE!", "%s is not a date (use YYYY-MM-DD)" % repr(s))
+
+def _xl_format_date(t):
+    return t.format("2006-01-02")
+
+def _xl_TODAY():
+    return _xl_format_date(time.now())
+
+def _xl_NOW():
+    return time.now().format("2006-01-02 15:04:05")
+
+def _xl_DATE(y, m, d):
+    return _xl_format_date(time.time(year = int(_xl_num(y)), month = int(_xl_num(m)), day = int(_xl_num(d))))
+
+def _xl_YEAR(v):
+    return _xl_date(v).year
+
+def _xl_MONTH(v):
+    return _xl_date(v).month
+
+def _xl_DAY(v):
+    return _xl_date(v).day
+
+def _xl_HOUR(v):
+    return _xl_date(v).hour
+
+def _xl_MINUTE(v):
+    return _xl_date(v).minute
+
+def _xl_SECOND(v):
+    return _xl_date(v).second
+
+def _xl_DAYS(end, start):
+    return int((_xl_date(end) - _xl_date(start)).hours // 24)
+
+def _xl_EDATE(v, months):
+    t = _xl_date(v)
+    month = t.month + int(_xl_num(months))
+    # Like Excel, stay within the target month: Jan 31 plus a month is the end of February
+    last = time.time(year = t.year, month = month + 1, day = 0).day
+    return _xl_format_date(time.time(year = t.year, month = month, day = min(t.day, last)))
+`
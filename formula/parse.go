@@ -0,0 +1,308 @@
+package formula
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Node is a parsed formula expression
+type Node interface{}
+
+type (
+	Number struct{ Value float64 }
+	String struct{ Value string }
+	Bool   struct{ Value bool }
+	Ref    struct{ Name string } // reference to a card input
+	Call   struct {
+		Name string // upper-cased function name
+		Args []Node
+	}
+	Unary struct {
+		Op string // "-", "+" or "%"
+		X  Node
+	}
+	Binary struct {
+		Op   string // "+", "-", "*", "/", "^", "&", "=", "<>", "<", ">", "<=", ">="
+		L, R Node
+	}
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // 1-based column in the formula
+}
+
+// SyntaxError is reported when a formula cannot be parsed
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("There's a problem with this formula: %s (at character %d)", e.Msg, e.Pos)
+}
+
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			if j < len(runes) && (runes[j] == 'e' || runes[j] == 'E') {
+				k := j + 1
+				if k < len(runes) && (runes[k] == '+' || runes[k] == '-') {
+					k++
+				}
+				if k < len(runes) && unicode.IsDigit(runes[k]) {
+					for k < len(runes) && unicode.IsDigit(runes[k]) {
+						k++
+					}
+					j = k
+				}
+			}
+			toks = append(toks, token{tokNumber, string(runes[i:j]), start})
+			i = j
+		case r == '"':
+			var sb strings.Builder
+			j := i + 1
+			for {
+				if j >= len(runes) {
+					return nil, &SyntaxError{start, "text is missing its closing quote"}
+				}
+				if runes[j] == '"' {
+					// Excel escapes quotes by doubling them
+					if j+1 < len(runes) && runes[j+1] == '"' {
+						sb.WriteRune('"')
+						j += 2
+						continue
+					}
+					break
+				}
+				sb.WriteRune(runes[j])
+				j++
+			}
+			toks = append(toks, token{tokString, sb.String(), start})
+			i = j + 1
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokIdent, string(runes[i:j]), start})
+			i = j
+		default:
+			op := string(r)
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				if two == "<>" || two == "<=" || two == ">=" {
+					op = two
+				}
+			}
+			if !strings.Contains("+-*/^&=<>(),%", string(r)) {
+				return nil, &SyntaxError{start, fmt.Sprintf("unexpected character %q", r)}
+			}
+			toks = append(toks, token{tokOp, op, start})
+			i += len([]rune(op))
+		}
+	}
+	toks = append(toks, token{tokEOF, "", len(runes) + 1})
+	return toks, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// Parse parses a formula body (without the leading "=") into an expression tree.
+func Parse(src string) (Node, error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	if p.peek().kind == tokEOF {
+		return nil, &SyntaxError{1, "the formula is empty"}
+	}
+	n, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &SyntaxError{t.pos, fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Operator precedence follows Excel, lowest first:
+// comparison, &, + -, * /, ^, unary + -, %
+func (p *parser) parseComparison() (Node, error) {
+	return p.parseBinary(p.parseConcat, "=", "<>", "<", ">", "<=", ">=")
+}
+
+func (p *parser) parseConcat() (Node, error) {
+	return p.parseBinary(p.parseAdditive, "&")
+}
+
+func (p *parser) parseAdditive() (Node, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *parser) parseMultiplicative() (Node, error) {
+	return p.parseBinary(p.parseExponent, "*", "/")
+}
+
+func (p *parser) parseExponent() (Node, error) {
+	return p.parseBinary(p.parseUnary, "^")
+}
+
+func (p *parser) parseBinary(operand func() (Node, error), ops ...string) (Node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(ops...) {
+		op := p.next().text
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: op, L: left, R: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.isOp("-", "+") {
+		op := p.next().text
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: op, X: x}, nil
+	}
+	return p.parsePercent()
+}
+
+func (p *parser) parsePercent() (Node, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("%") {
+		p.next()
+		x = &Unary{Op: "%", X: x}
+	}
+	return x, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, &SyntaxError{t.pos, fmt.Sprintf("%q is not a valid number", t.text)}
+		}
+		return &Number{Value: v}, nil
+	case tokString:
+		return &String{Value: t.text}, nil
+	case tokIdent:
+		if p.isOp("(") {
+			return p.parseCall(t)
+		}
+		switch strings.ToUpper(t.text) {
+		case "TRUE":
+			return &Bool{Value: true}, nil
+		case "FALSE":
+			return &Bool{Value: false}, nil
+		}
+		return &Ref{Name: t.text}, nil
+	case tokOp:
+		if t.text == "(" {
+			x, err := p.parseComparison()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOp(")") {
+				return nil, &SyntaxError{p.peek().pos, "missing closing parenthesis"}
+			}
+			p.next()
+			return x, nil
+		}
+		return nil, &SyntaxError{t.pos, fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return nil, &SyntaxError{t.pos, "the formula ends unexpectedly"}
+}
+
+func (p *parser) parseCall(name token) (Node, error) {
+	p.next() // "("
+	call := &Call{Name: strings.ToUpper(name.text)}
+	if p.isOp(")") {
+		p.next()
+		return call, nil
+	}
+	for {
+		arg, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+		if p.isOp(",") {
+			p.next()
+			continue
+		}
+		if p.isOp(")") {
+			p.next()
+			return call, nil
+		}
+		if p.peek().kind == tokEOF {
+			return nil, &SyntaxError{p.peek().pos, fmt.Sprintf("missing closing parenthesis for %s", call.Name)}
+		}
+		return nil, &SyntaxError{p.peek().pos, fmt.Sprintf("expected \",\" or \")\" in the arguments of %s", call.Name)}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// newFormulaFlow wires text cards into a formula card's ports
+func newFormulaFlow(expr string, values map[string]string) (*Game, *Card) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+
+	fc := g.AddFormulaCard(100, 400)
+	fc.Text = expr
+	x := 100.0
+	for port, val := range values {
+		tc := g.AddTextCard(x, 100)
+		tc.Text = val
+		wireCards(g, tc, "text", fc, port)
+		x += 250
+	}
+	return g, fc
+}
+
+func TestFormulaEvaluation(t *testing.T) {
+	tests := []struct {
+		expr   string
+		values map[string]string
+		want   interface{}
+	}{
+		{"=UPPER(TRIM(input))", map[string]string{"input": "  hello   world "}, "HELLO WORLD"},
+		{"=IF(LEN(a)>3, a, b)", map[string]string{"a": "long", "b": "short"}, "long"},
+		{"=IF(LEN(a)>3, a, b)", map[string]string{"a": "abc", "b": "short"}, "short"},
+		{"=-2^2", nil, 4},
+		{"=\"Total: \"&a+b", map[string]string{"a": "1", "b": "2"}, "Total: 3"},
+		{"=ROUND(a/3, 2)", map[string]string{"a": "10"}, 3.33},
+		{"=SUM(a, b, 10%)", map[string]string{"a": "1", "b": "2.5"}, 3.6},
+		{"=MID(input, 2, 3)&LEFT(input)", map[string]string{"input": "abcdef"}, "bcda"},
+		{"=SUBSTITUTE(input, \"o\", \"0\", 2)", map[string]string{"input": "foo boo"}, "fo0 boo"},
+		{"=AND(a>1, NOT(ISBLANK(b)))", map[string]string{"a": "2"}, false},
+		{"=YEAR(EDATE(DATE(2024, 11, 15), 3))", nil, 2025},
+		{"=EDATE(\"2024-01-31\", 1)", nil, "2024-02-29"},
+		{"=EDATE(\"2023-03-31\", -13)", nil, "2022-02-28"},
+		{"=DAYS(\"2024-03-01\", \"2024-02-01\")", nil, 29},
+		{"42", nil, 42},
+		{"plain text", nil, "plain text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			g, fc := newFormulaFlow(tt.expr, tt.values)
+			g.engine.Run()
+
+			if fc.LastError != "" {
+				t.Fatalf("Unexpected error: %s", fc.LastError)
+			}
+			if got := g.engine.Memory[fc.ID+":result"]; got != tt.want {
+				t.Errorf("Expected %v (%T), got %v (%T)", tt.want, tt.want, got, got)
+			}
+		})
+	}
+}
+
+func TestFormulaEditInvalidatesCache(t *testing.T) {
+	g, fc := newFormulaFlow("=UPPER(input)", map[string]string{"input": "abc"})
+	g.engine.Run()
+	fc.Text = "=LEN(input)"
+	g.engine.Run()
+	if got := g.engine.Memory[fc.ID+":result"]; got != 3 {
+		t.Errorf("Expected the edited formula to run, got %v", got)
+	}
+}
+
+func TestFormulaErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"=FOO(input)", "#NAME? unknown function FOO"},
+		{"=UPPER(c)", `#NAME? "c" is not an input of this card`},
+		{"=LEN()", "too few arguments for LEN"},
+		{"=UPPER(input", "missing closing parenthesis"},
+		{"=a/0", "#DIV/0!"},
+		{"=a+\"x\"", "#VALUE!"},
+		{"=SQRT(-1)", "#NUM!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			g, fc := newFormulaFlow(tt.expr, map[string]string{"a": "1", "input": "x"})
+			g.engine.Run()
+
+			if !strings.Contains(fc.LastError, tt.want) {
+				t.Errorf("Expected error containing %q, got %q", tt.want, fc.LastError)
+			}
+			if _, ok := g.engine.Memory[fc.ID+":result"]; ok {
+				t.Error("Expected no result in memory after an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Frame is a titled region of the canvas drawn behind the cards, e.g. a swimlane
+// separating the happy path from error handling. Cards fully inside a frame move
+// with it and can be run on their own.
+type Frame struct {
+	ID            string
+	Title         string
+	X, Y          float64
+	Width, Height float64
+	Color         string // one of FrameColors
+}
+
+// FrameColors lists the frame tints in cycling order
+var FrameColors = []string{"blue", "green", "red", "gray"}
+
+var frameColorValues = map[string]color.RGBA{
+	"blue":  {70, 110, 180, 255},
+	"green": {70, 150, 90, 255},
+	"red":   {170, 70, 70, 255},
+	"gray":  {110, 110, 120, 255},
+}
+
+// frameDrag is a frame being moved or resized with the mouse
+type frameDrag struct {
+	frame      *Frame
+	resizing   bool
+	lastX      float64 // world position of the previous update
+	lastY      float64
+	cards      []*Card   // cards moving with the frame
+	frames     []*Frame  // frames nested inside, which move too
+	lastClick  time.Time // last click on a title bar, to detect double-clicks
+	clickFrame *Frame
+}
+
+// AddFrame adds a frame with its top left corner at the world position
+func (g *Game) AddFrame(x, y float64) *Frame {
+	f := &Frame{
+		ID:     NewID(),
+		Title:  "Frame",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  FrameDefaultWidth,
+		Height: FrameDefaultHeight,
+		Color:  FrameColors[0],
+	}
+	g.frames = append(g.frames, f)
+	return f
+}
+
+// DeleteFrame removes a frame; the cards inside it stay on the canvas
+func (g *Game) DeleteFrame(f *Frame) {
+	g.frames = slices.DeleteFunc(g.frames, func(o *Frame) bool { return o == f })
+}
+
+// contains reports whether the rectangle lies entirely inside the frame
+func (f *Frame) contains(x, y, w, h float64) bool {
+	return x >= f.X && y >= f.Y && x+w <= f.X+f.Width && y+h <= f.Y+f.Height
+}
+
+// CardsInFrame returns the cards lying entirely inside the frame, in canvas order
+func (g *Game) CardsInFrame(f *Frame) []*Card {
+	var cards []*Card
+	for _, c := range g.cards {
+		if f.contains(c.X, c.Y, c.Width, c.Height) {
+			cards = append(cards, c)
+		}
+	}
+	return cards
+}
+
+// MoveFrame moves a frame with the cards and frames inside it and the notes pinned to those cards
+func (g *Game) MoveFrame(f *Frame, dx, dy float64) {
+	cards, frames := g.frameContents(f)
+	moveFrameContents(f, cards, frames, dx, dy)
+}
+
+// frameContents returns what moves with a frame
+func (g *Game) frameContents(f *Frame) ([]*Card, []*Frame) {
+	cards := g.CardsInFrame(f)
+	for _, c := range g.cards {
+		if c.AttachedTo == "" || slices.Contains(cards, c) {
+			continue
+		}
+		if slices.ContainsFunc(cards, func(o *Card) bool { return o.ID == c.AttachedTo }) {
+			cards = append(cards, c)
+		}
+	}
+	var frames []*Frame
+	for _, o := range g.frames {
+		if o != f && f.contains(o.X, o.Y, o.Width, o.Height) {
+			frames = append(frames, o)
+		}
+	}
+	return cards, frames
+}
+
+func moveFrameContents(f *Frame, cards []*Card, frames []*Frame, dx, dy float64) {
+	f.X += dx
+	f.Y += dy
+	for _, c := range cards {
+		c.X += dx
+		c.Y += dy
+	}
+	for _, o := range frames {
+		o.X += dx
+		o.Y += dy
+	}
+}
+
+// RunFrame runs only the cards inside the frame. Inputs wired in from outside
+// use the results of the last run.
+func (g *Game) RunFrame(f *Frame) {
+	if g.engine != nil {
+		g.engine.RunCards(g.CardsInFrame(f))
+	}
+}
+
+// frameAt returns the top frame whose title bar or resize grip is at the world
+// position, and which part was hit: "title", "color", "run", "delete" or "resize"
+func (g *Game) frameAt(wx, wy float64) (*Frame, string) {
+	for i := len(g.frames) - 1; i >= 0; i-- {
+		f := g.frames[i]
+		if wx < f.X || wx > f.X+f.Width || wy < f.Y || wy > f.Y+f.Height {
+			continue
+		}
+		if wy <= f.Y+FrameTitleHeight {
+			for i, part := range []string{"delete", "run", "color"} {
+				if bx := f.buttonX(i); wx >= bx && wx <= bx+FrameButtonWidth {
+					return f, part
+				}
+			}
+			return f, "title"
+		}
+		if wx >= f.X+f.Width-FrameGripSize && wy >= f.Y+f.Height-FrameGripSize {
+			return f, "resize"
+		}
+	}
+	return nil, ""
+}
+
+// buttonX returns the world x of a title bar button counted from the right
+func (f *Frame) buttonX(i int) float64 {
+	return f.X + f.Width - float64(i+1)*(FrameButtonWidth+4)
+}
+
+// overFrameControls reports whether the mouse is on a frame's title bar or grip
+// rather than a card, or a frame is being dragged, so canvas input leaves it alone
+func (g *Game) overFrameControls(mx, my int) bool {
+	if g.frameDrag.frame != nil {
+		return true
+	}
+	wx, wy := g.screenToWorld(float64(mx), float64(my))
+	if g.getCardAt(wx, wy) != nil {
+		return false
+	}
+	f, _ := g.frameAt(wx, wy)
+	return f != nil
+}
+
+// updateFrames handles adding frames with F7 and moving, resizing, renaming,
+// recoloring, running and deleting them with the mouse
+func (g *Game) updateFrames() {
+	mx, my := ebiten.CursorPosition()
+	wx, wy := g.screenToWorld(float64(mx), float64(my))
+	d := &g.frameDrag
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyF7) && g.input.EditingCard == nil {
+		g.AddFrame(wx, wy)
+	}
+
+	if d.frame != nil {
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			d.frame, d.cards, d.frames = nil, nil, nil
+			return
+		}
+		dx, dy := wx-d.lastX, wy-d.lastY
+		d.lastX, d.lastY = wx, wy
+		if d.resizing {
+			d.frame.Width = math.Max(FrameMinSize, d.frame.Width+dx)
+			d.frame.Height = math.Max(FrameMinSize, d.frame.Height+dy)
+		} else {
+			moveFrameContents(d.frame, d.cards, d.frames, dx, dy)
+		}
+		return
+	}
+
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) || g.ui.IsMouseOver(mx, my) ||
+		g.overInspector(mx, my) || g.getCardAt(wx, wy) != nil {
+		return
+	}
+	f, part := g.frameAt(wx, wy)
+	if f == nil {
+		return
+	}
+	switch part {
+	case "delete":
+		g.DeleteFrame(f)
+	case "run":
+		g.RunFrame(f)
+	case "color":
+		f.Color = FrameColors[(slices.Index(FrameColors, f.Color)+1)%len(FrameColors)]
+	case "title", "resize":
+		// A double-click on the title renames the frame
+		if part == "title" && d.clickFrame == f && time.Since(d.lastClick) < DoubleClickThreshold*time.Millisecond {
+			g.input.EditingCard = f
+			d.clickFrame = nil
+			return
+		}
+		d.lastClick, d.clickFrame = time.Now(), f
+		d.frame, d.resizing = f, part == "resize"
+		d.lastX, d.lastY = wx, wy
+		if !d.resizing {
+			d.cards, d.frames = g.frameContents(f)
+		}
+	}
+}
+
+// drawFrames draws the frames behind the arrows and cards
+func (g *Game) drawFrames(screen *ebiten.Image, cw, ch float64) {
+	zoom := g.camera.Zoom
+	for _, f := range g.frames {
+		sx, sy := g.camera.WorldToScreen(f.X, f.Y, cw, ch)
+		sw, sh := f.Width*zoom, f.Height*zoom
+		tint := frameColorValues[f.Color]
+
+		body := color.NRGBA{tint.R, tint.G, tint.B, FrameBodyAlpha}
+		vector.DrawFilledRect(screen, float32(sx), float32(sy), float32(sw), float32(sh), body, false)
+		vector.StrokeRect(screen, float32(sx), float32(sy), float32(sw), float32(sh), 1, tint, false)
+		bar := color.NRGBA{tint.R, tint.G, tint.B, FrameTitleAlpha}
+		vector.DrawFilledRect(screen, float32(sx), float32(sy), float32(sw), float32(FrameTitleHeight*zoom), bar, false)
+
+		title := f.Title
+		if g.input != nil && g.input.EditingCard == f && (time.Now().UnixMilli()/CursorBlinkRate)%2 == 0 {
+			title += "|"
+		}
+		DrawTextLines(screen, g.FontFace, title, int(sx+CardPaddingX*zoom), int(sy+4*zoom), color.White)
+
+		// Title bar buttons from the right: delete, run, and a swatch of the next color
+		next := FrameColors[(slices.Index(FrameColors, f.Color)+1)%len(FrameColors)]
+		bw, bh := FrameButtonWidth*zoom, (FrameTitleHeight-8)*zoom
+		for i, label := range []string{"X", "Run", ""} {
+			bx := sx + (f.buttonX(i)-f.X)*zoom
+			by := sy + 4*zoom
+			if label == "" {
+				vector.DrawFilledRect(screen, float32(bx), float32(by), float32(bw), float32(bh), frameColorValues[next], false)
+			} else {
+				vector.DrawFilledRect(screen, float32(bx), float32(by), float32(bw), float32(bh), ColorButtonBackground, false)
+				DrawTextLines(screen, g.FontFace, label, int(bx+4*zoom), int(by), color.White)
+			}
+		}
+
+		grip := FrameGripSize * zoom
+		vector.DrawFilledRect(screen, float32(sx+sw-grip), float32(sy+sh-grip), float32(grip), float32(grip), bar, false)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMoveFrameMovesContents(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	g.frames = nil
+	f := g.AddFrame(0, 0)
+	inside := g.AddTextCard(100, 100)
+	outside := g.AddTextCard(900, 100)
+	straddling := g.AddTextCard(550, 100) // sticks out of the right edge
+	note := g.AddNoteCard(0, 0)
+	note.X, note.Y = inside.X+150, inside.Y+400 // hangs below the frame
+	note.AttachedTo = inside.ID
+	nested := g.AddFrame(300, 250)
+	nested.Width, nested.Height = 200, 100
+
+	g.MoveFrame(f, 50, -20)
+	if inside.X != 150 || inside.Y != 80 || note.X != 300 || nested.X != 350 || nested.Y != 230 {
+		t.Errorf("Expected the card, its note and the nested frame to move, got card (%v, %v), note x %v, frame (%v, %v)",
+			inside.X, inside.Y, note.X, nested.X, nested.Y)
+	}
+	if outside.X != 900 || straddling.X != 550 {
+		t.Errorf("Expected cards not fully inside to stay put")
+	}
+}
+
+func TestRunFrameRunsOnlyItsCards(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	g.frames = nil
+	f := g.AddFrame(0, 0)
+	inside := g.AddTextCard(100, 100)
+	outside := g.AddTextCard(900, 100)
+
+	g.RunFrame(f)
+	if _, ok := g.engine.ExecutionCache[inside.ID]; !ok {
+		t.Errorf("Expected the card inside the frame to run")
+	}
+	if _, ok := g.engine.ExecutionCache[outside.ID]; ok {
+		t.Errorf("Expected the card outside the frame not to run")
+	}
+}
+
+func TestRunFrameExpandsVariables(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	g.frames = nil
+	g.variables = &Variables{
+		Values:   map[string]interface{}{"sep": "-"},
+		Profiles: map[string]map[string]interface{}{"prod": {"sep": "+"}},
+	}
+	f := g.AddFrame(0, 0)
+	f.Width, f.Height = 800, 600
+	first := g.AddTextCard(100, 100)
+	first.Text = "a"
+	second := g.AddTextCard(400, 100)
+	second.Text = "b"
+	concat := g.AddConcatCard(100, 300)
+	concat.setParams(map[string]interface{}{"separator": "${sep}"})
+	g.Connect(first, "text", concat, "text_1")
+	g.Connect(second, "text", concat, "text_2")
+
+	g.RunFrame(f)
+	if got := g.engine.Memory[concat.ID+":text"]; got != "a-b" {
+		t.Errorf("Expected the frame run to expand variables, got %v (error %q)", got, concat.LastError)
+	}
+	if err := g.SetProfile("prod"); err != nil {
+		t.Fatal(err)
+	}
+	g.RunFrame(f)
+	if got := g.engine.Memory[concat.ID+":text"]; got != "a+b" {
+		t.Errorf("Expected the frame run to follow the profile, got %v", got)
+	}
+}
+
+func TestFrameControlsAndSave(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	g.frames = nil
+	f := g.AddFrame(0, 0)
+	f.Title = "Error handling"
+	f.Color = "red"
+
+	for _, tc := range []struct {
+		x, y float64
+		part string
+	}{
+		{10, 10, "title"},
+		{f.buttonX(0) + 1, 10, "delete"},
+		{f.buttonX(1) + 1, 10, "run"},
+		{f.buttonX(2) + 1, 10, "color"},
+		{f.Width - 2, f.Height - 2, "resize"},
+		{300, 200, ""},
+	} {
+		if _, part := g.frameAt(tc.x, tc.y); part != tc.part {
+			t.Errorf("At (%v, %v) expected %q, got %q", tc.x, tc.y, tc.part, part)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	if err := SaveState(g, path); err != nil {
+		t.Fatal(err)
+	}
+	g2 := NewGame()
+	if err := LoadState(g2, path); err != nil {
+		t.Fatal(err)
+	}
+	if len(g2.frames) != 1 || *g2.frames[0] != *f {
+		t.Errorf("Expected the frame to be saved, got %+v", g2.frames)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
 
 	"card-flows/canvas"
@@ -31,7 +32,16 @@ type Game struct {
 	engine *Engine
 
 	screenshotRequested bool
+	selectedGrid        *Card // grid card that receives pasted text
+	hideGhostPreviews   bool  // hide the data previews under all cards
+	inspector           Inspector
 	FontFace            font.Face
+	markdownFonts       map[float64]*markdownFonts // by body font size, loaded on first use
+	frames              []*Frame                   // canvas regions drawn behind the cards
+	frameDrag           frameDrag
+	selection           []*Card    // cards picked with Ctrl+click, grouped by Ctrl+G
+	views               []flowView // flows containing the open composite, outermost first
+	variables           *Variables // flow variables and profiles; nil in inner flows, which use the caller's
 }
 
 func NewGame() *Game {
@@ -61,6 +71,7 @@ func NewGame() *Game {
 		DrawTextLines,
 	)
 	g.engine = NewEngine(g)
+	g.engine.background = true
 
 	err := LoadState(g, "state.yaml")
 	if err == nil {
@@ -69,8 +80,9 @@ func NewGame() *Game {
 
 	// Default dummy cards if load fails
 	g.cards = append(g.cards, &Card{
-		ID: NewID(),
-		X:  50, Y: 50, Width: 200, Height: 120, Color: color.RGBA{100, 149, 237, 255}, Title: "Text Card",
+		ID:   NewID(),
+		Type: "text",
+		X:    50, Y: 50, Width: 200, Height: 120, Color: color.RGBA{100, 149, 237, 255}, Title: "Text Card",
 		Text:    "Hello World",
 		Inputs:  []Port{{Name: "text", Type: "string"}},
 		Outputs: []Port{{Name: "text", Type: "string"}},
@@ -78,6 +90,7 @@ func NewGame() *Game {
 
 	g.cards = append(g.cards, &Card{
 		ID:     NewID(),
+		Type:   "find_replace",
 		Title:  "String:find_replace",
 		X:      500,
 		Y:      50,
@@ -91,6 +104,8 @@ func NewGame() *Game {
 		},
 		Outputs: []Port{
 			{Name: "result", Type: "string"},
+			{Name: "count", Type: "int"},
+			{Name: "matches", Type: "list"},
 		},
 	})
 
@@ -101,19 +116,38 @@ func (g *Game) Update() error {
 	// Delegate to sub-systems
 	g.input.Update()
 	g.ui.Update()
+	g.updateInspector()
+	g.updateFrames()
+	g.updateComposites()
+	g.updateProfiles()
+	g.updateLLMCalls()
 	return nil
 }
 
 func (g *Game) DeleteCard(c *Card) {
+	c.cancelLLM()
 	newCards := []*Card{}
 	for _, card := range g.cards {
 		if card != c {
 			newCards = append(newCards, card)
 		}
+		if card.AttachedTo == c.ID {
+			card.AttachedTo = ""
+		}
 	}
 	g.cards = newCards
 }
 
+// removeStaleArrows removes the wires to ports a card no longer has
+func (g *Game) removeStaleArrows(c *Card) {
+	hasPort := func(ports []Port, name string) bool {
+		return slices.ContainsFunc(ports, func(p Port) bool { return p.Name == name })
+	}
+	g.arrows = slices.DeleteFunc(g.arrows, func(a *Arrow) bool {
+		return a.ToCardID == c.ID && !hasPort(c.Inputs, a.ToPort) || a.FromCardID == c.ID && !hasPort(c.Outputs, a.FromPort)
+	})
+}
+
 func (g *Game) DuplicateCard(c *Card) {
 	newID := NewID()
 
@@ -133,21 +167,37 @@ func (g *Game) DuplicateCard(c *Card) {
 	}
 
 	newCard := &Card{
-		ID:     newID,
-		X:      c.X + DuplicateOffset,
-		Y:      c.Y + DuplicateOffset,
-		Width:  c.Width,
-		Height: c.Height,
-		Color:  c.Color,
-		Title:  fmt.Sprintf("%s (%s)", baseTitle, shortID),
-		Text:   c.Text,
-	}
-	// Copy ports
+		ID:         newID,
+		Type:       c.Type,
+		X:          c.X + DuplicateOffset,
+		Y:          c.Y + DuplicateOffset,
+		Width:      c.Width,
+		Height:     c.Height,
+		Color:      c.Color,
+		Title:      fmt.Sprintf("%s (%s)", baseTitle, shortID),
+		Text:       c.Text,
+		Markdown:   c.Markdown,
+		AttachedTo: c.AttachedTo,
+	}
+	if c.Subflow != nil {
+		newCard.Subflow = c.Subflow.clone()
+	}
+	// Copy grid cells
+	for _, row := range c.Cells {
+		newCard.Cells = append(newCard.Cells, append([]string{}, row...))
+	}
+	// Copy params and ports
+	if c.Params != nil {
+		newCard.Params = make(map[string]interface{}, len(c.Params))
+		for k, v := range c.Params {
+			newCard.Params[k] = v
+		}
+	}
 	for _, p := range c.Inputs {
-		newCard.Inputs = append(newCard.Inputs, Port{Name: p.Name, Type: p.Type})
+		newCard.Inputs = append(newCard.Inputs, p)
 	}
 	for _, p := range c.Outputs {
-		newCard.Outputs = append(newCard.Outputs, Port{Name: p.Name, Type: p.Type})
+		newCard.Outputs = append(newCard.Outputs, p)
 	}
 	g.cards = append(g.cards, newCard)
 }
@@ -175,6 +225,8 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	wx, wy := g.screenToWorld(float64(mx), float64(my))
 	hoveredCard := g.getCardAt(wx, wy)
 
+	g.drawFrames(screen, cw, ch)
+
 	// Draw Arrows (Connections)
 	for _, arrow := range g.arrows {
 		arrow.Draw(screen, g, cw, ch)
@@ -196,10 +248,20 @@ func (g *Game) Draw(screen *ebiten.Image) {
 			"Mouse World: (%.1f, %.1f)\n"+
 			"Hovering: %s\n"+
 			"Drag: Left Click to move cards\n"+
-			"Pan: Left Drag (Empty Space) or Middle Drag",
+			"Pan: Left Drag (Empty Space) or Middle Drag\n"+
+			"Previews: F3 (all), Shift+F3 (hovered card)\n"+
+			"Inspector: click a card, F4 to show or hide\n"+
+			"Markdown: F6 (hovered card)\n"+
+			"Notes: Shift+double-click, drop on a card to pin\n"+
+			"Frames: F7, drag the title bar to move, double-click it to rename\n"+
+			"Groups: Ctrl+click cards, Ctrl+G to group, double-click to open, Esc to leave\n"+
+			"Ports: + adds an input, double-click a name to rename it, clear it to remove\n"+
+			"Settings: * in a card header opens its settings\n"+
+			"Profile: %s (F8 to switch)",
 		g.camera.X, g.camera.Y, g.camera.Zoom,
 		wx, wy,
 		hoverStatus,
+		g.profileLabel(),
 	), 10, 10, color.White)
 
 	// Print card IDs for debugging
@@ -207,6 +269,8 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		DrawTextLines(screen, g.FontFace, fmt.Sprintf("ID: %s", hoveredCard.ID), 10, 100, color.White)
 	}
 
+	g.drawBreadcrumb(screen)
+	g.drawInspector(screen)
 	g.ui.Draw(screen)
 
 	// --- Save Screenshot ---
@@ -362,7 +426,7 @@ func (g *Game) ScreenToWorld(sx, sy float64) (float64, float64) {
 }
 
 func (g *Game) IsMouseOver(mx, my int) bool {
-	return g.ui.IsMouseOver(mx, my)
+	return g.ui.IsMouseOver(mx, my) || g.overInspector(mx, my) || g.overFrameControls(mx, my)
 }
 
 func (g *Game) RequestScreenshot() {
@@ -370,6 +434,15 @@ func (g *Game) RequestScreenshot() {
 }
 
 func (g *Game) RunEngine() {
+	if len(g.views) > 0 {
+		// Inside a composite: rerun the whole flow so its outputs reach the outer cards
+		g.rootFlow()
+		for _, v := range g.views {
+			delete(v.engine.ExecutionCache, v.composite.ID)
+		}
+		g.views[0].engine.Run()
+		return
+	}
 	if g.engine != nil {
 		g.engine.Run()
 	}
@@ -422,14 +495,47 @@ func (g *Game) GetCardTitle(card interface{}) string {
 }
 
 func (g *Game) GetCardText(card interface{}) string {
+	if f, ok := card.(*Frame); ok {
+		return f.Title
+	}
 	if c, ok := card.(*Card); ok {
+		if c.Type == "grid" {
+			if cell := c.selectedCell(); cell != nil {
+				return *cell
+			}
+			return ""
+		}
+		if c.editedPort() != nil {
+			return c.portDraft
+		}
+		if f := c.editedFormField(g); f != nil {
+			return f.Value
+		}
 		return c.Text
 	}
 	return ""
 }
 
 func (g *Game) SetCardText(card interface{}, text string) {
+	if f, ok := card.(*Frame); ok {
+		f.Title = text
+		return
+	}
 	if c, ok := card.(*Card); ok {
+		if c.Type == "grid" {
+			if cell := c.selectedCell(); cell != nil {
+				*cell = text
+			}
+			return
+		}
+		if c.editedPort() != nil {
+			c.portDraft = text
+			return
+		}
+		if f := c.editedFormField(g); f != nil {
+			f.Set(text)
+			return
+		}
 		c.Text = text
 	}
 }
@@ -443,6 +549,9 @@ func (g *Game) GetCardBounds(card interface{}) (float64, float64, float64, float
 
 func (g *Game) SetCardBounds(card interface{}, x, y, w, h float64) {
 	if c, ok := card.(*Card); ok {
+		if w == c.Width && h == c.Height {
+			g.moveAttachedNotes(c, x-c.X, y-c.Y)
+		}
 		c.X = x
 		c.Y = y
 		c.Width = w
@@ -468,6 +577,19 @@ func (g *Game) GetPortAt(card interface{}, wx, wy, zoom float64) *input.PortInfo
 	return nil
 }
 
+// ConnectPorts wires a dragged output to an input port and reruns the flow.
+// Cards can't be wired to themselves or to cards above them.
+func (g *Game) ConnectPorts(from interface{}, fromPort string, to interface{}, toPort string) {
+	src, ok1 := from.(*Card)
+	dst, ok2 := to.(*Card)
+	if !ok1 || !ok2 || src == dst || dst.Y < src.Y {
+		return
+	}
+	g.Connect(src, fromPort, dst, toPort)
+	g.PropagateText(src)
+	g.RunEngine()
+}
+
 func (g *Game) GetOutputPortPosition(card interface{}, portName string) (float64, float64) {
 	if c, ok := card.(*Card); ok {
 		return c.GetOutputPortPosition(portName)
@@ -508,9 +630,128 @@ func (g *Game) CheckActionButton(card interface{}, wx, wy float64) string {
 		return "duplicate"
 	}
 
+	if x, y, w, h := c.settingsButton(); c.HasSettings() && wx >= x && wx <= x+w && wy >= y && wy <= y+h {
+		return "settings"
+	}
+
+	if action := c.variadicActionAt(wx, wy); action != "" {
+		return action
+	}
+	if c.Type == "grid" {
+		return c.gridActionAt(wx, wy)
+	}
+	if c.IsNote() && !c.settingsOpen {
+		return c.noteButtonAt(wx, wy)
+	}
+	if c.formFieldAt(g, wx, wy) >= 0 {
+		return "form_field"
+	}
+	if c.Type == "template" && c.templateTextAt(g, wx, wy) {
+		return "edit_text"
+	}
+
 	return ""
 }
 
+// PerformCardAction handles card-specific actions returned by CheckActionButton.
+// It returns false when the click should continue as usual, e.g. to start editing a grid cell.
+func (g *Game) PerformCardAction(card interface{}, action string, wx, wy float64) bool {
+	c, ok := card.(*Card)
+	if !ok {
+		return false
+	}
+
+	switch action {
+	case "grid_cell":
+		c.selectGridCell(wx, wy)
+		g.selectedGrid = c
+		return false
+	case "grid_add_row":
+		c.AddGridRow()
+	case "grid_remove_row":
+		c.RemoveGridRow()
+	case "grid_add_column":
+		c.AddGridColumn()
+	case "grid_remove_column":
+		c.RemoveGridColumn()
+	case "form_field":
+		return g.clickFormField(c, wx, wy)
+	case "settings":
+		c.toggleSettings()
+		return true
+	case "port_add":
+		c.AddInputPort()
+	case "port_rename":
+		// A double-click types a new name into the label
+		if i := c.inputLabelAt(wx, wy); i >= 0 {
+			c.portEdit, c.portDraft = i+1, c.Inputs[i].Name
+		}
+		return false
+	case "export_png":
+		filename := fmt.Sprintf("chart_%s.png", c.ID)
+		if err := g.ExportChartPNG(c, filename); err != nil {
+			c.LastError = err.Error()
+		} else {
+			log.Println("Chart saved as", filename)
+		}
+		return true
+	case "edit_text":
+		// Deselect the form so the double-click edits the card's own text
+		c.FormField = -1
+		return false
+	case "export_now":
+		g.ExportNow(c)
+		return true
+	case "note_color":
+		c.Color = noteColorValues[c.cycleNoteParam("color", NoteColors)]
+		return true
+	case "note_size":
+		c.cycleNoteParam("size", NoteSizes)
+		return true
+	case "save_composite_type":
+		if err := SaveCompositeType(c); err != nil {
+			c.LastError = err.Error()
+		} else {
+			c.LastError = ""
+			c.setParams(map[string]interface{}{"type": c.Title})
+			c.status = "Saved as " + compositeTypeFile(c.Title)
+		}
+		return true
+	case "open_composite":
+		g.OpenComposite(c)
+		return true
+	case "llm_cancel":
+		// The run after the request stops shows it as cancelled
+		if c.llmPending() {
+			c.llmCall.cancel()
+		}
+		return true
+	case "eject":
+		if err := g.EjectToScript(c); err != nil {
+			c.LastError = err.Error()
+			return true
+		}
+	default:
+		return false
+	}
+	g.RunEngine()
+	return true
+}
+
+// Paste writes tab-separated clipboard text into the selected grid cell
+func (g *Game) Paste() {
+	if g.selectedGrid == nil || g.getCardByID(g.selectedGrid.ID) == nil {
+		return
+	}
+	text, err := readClipboard()
+	if err != nil {
+		log.Println("paste error:", err)
+		return
+	}
+	g.selectedGrid.PasteTSV(text)
+	g.RunEngine()
+}
+
 func (g *Game) RegisterSubscriptionHandle(fromID, toID, toPort string) {
 	g.RegisterSubscription(fromID, toID, toPort)
 }
@@ -522,6 +763,7 @@ func (g *Game) UnregisterSubscriptionHandle(fromID, toID, toPort string) {
 func (g *Game) PropagateTextByID(cardID string) {
 	c := g.getCardByID(cardID)
 	if c != nil {
+		g.commitPortEdit(c)
 		g.PropagateText(c)
 	}
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"card-flows/engine"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// ghostPreviewLines formats a value as a few short lines for the preview under a card.
+// It returns nil when there is nothing to show.
+func ghostPreviewLines(v interface{}) []string {
+	var lines []string
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case *engine.Table:
+		lines = append(lines, strings.Join(val.Columns, " | "))
+		n := val.NumRows()
+		for r := 0; r < n && r < GhostPreviewRows; r++ {
+			cells := make([]string, len(val.Columns))
+			for c, cell := range val.Row(r) {
+				cells[c] = previewValue(cell)
+			}
+			lines = append(lines, strings.Join(cells, " | "))
+		}
+		if n == 0 {
+			lines = append(lines, "(no rows)")
+		} else if n > GhostPreviewRows {
+			lines = append(lines, fmt.Sprintf("... %d more rows", n-GhostPreviewRows))
+		}
+	case []interface{}:
+		for i := 0; i < len(val) && i < GhostPreviewRows; i++ {
+			lines = append(lines, previewValue(val[i]))
+		}
+		lines = appendMore(lines, len(val), "items", "[]")
+	case []string:
+		for i := 0; i < len(val) && i < GhostPreviewRows; i++ {
+			lines = append(lines, secretStore.Redact(val[i]))
+		}
+		lines = appendMore(lines, len(val), "items", "[]")
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i := 0; i < len(keys) && i < GhostPreviewRows; i++ {
+			lines = append(lines, keys[i]+": "+previewValue(val[keys[i]]))
+		}
+		lines = appendMore(lines, len(keys), "keys", "{}")
+	case string:
+		if val == "" {
+			return []string{`""`}
+		}
+		all := strings.Split(val, "\n")
+		for i := 0; i < len(all) && i < GhostPreviewRows; i++ {
+			lines = append(lines, secretStore.Redact(all[i]))
+		}
+		if len(all) > GhostPreviewRows {
+			lines = append(lines, "...")
+		}
+	default:
+		lines = []string{previewValue(val)}
+	}
+
+	for i, l := range lines {
+		lines[i] = truncateRunes(l, GhostPreviewMaxChars)
+	}
+	return lines
+}
+
+func appendMore(lines []string, n int, noun, empty string) []string {
+	if n == 0 {
+		return append(lines, empty)
+	}
+	if n > GhostPreviewRows {
+		return append(lines, fmt.Sprintf("... %d more %s", n-GhostPreviewRows, noun))
+	}
+	return lines
+}
+
+// previewValue formats a single value on one line, with secret values masked
+func previewValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case *engine.Table:
+		return fmt.Sprintf("table %dx%d", val.NumRows(), len(val.Columns))
+	case string:
+		return secretStore.Redact(strings.ReplaceAll(val, "\n", " "))
+	}
+	return secretStore.Redact(fmt.Sprint(v))
+}
+
+// truncateRunes shortens s to at most n runes, marking the cut with "..."
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// showsGhostPreview reports whether the card's preview is drawn at the current zoom
+func (g *Game) showsGhostPreview(c *Card) bool {
+	return !g.hideGhostPreviews && !c.HidePreview && g.camera.Zoom >= GhostPreviewMinZoom && len(c.Outputs) > 0
+}
+
+// ToggleGhostPreview hides or shows the preview of one card, or of all cards when card is nil
+func (g *Game) ToggleGhostPreview(card interface{}) {
+	if c, ok := card.(*Card); ok && c != nil {
+		c.HidePreview = !c.HidePreview
+		return
+	}
+	g.hideGhostPreviews = !g.hideGhostPreviews
+}
+
+// drawGhostPreview draws the first rows of the card's primary output under its footer
+func (c *Card) drawGhostPreview(screen *ebiten.Image, g *Game, cw, ch float64) {
+	if !g.showsGhostPreview(c) || g.engine == nil {
+		return
+	}
+	lines := ghostPreviewLines(g.engine.Memory[c.ID+":"+c.Outputs[0].Name])
+	if lines == nil {
+		return
+	}
+
+	sx, sy := g.camera.WorldToScreen(c.X, c.Y+c.Height+PortSize, cw, ch)
+	h := float64(len(lines))*TextLineHeight + CardPaddingY
+	vector.DrawFilledRect(screen, float32(sx), float32(sy), float32(c.Width*g.camera.Zoom), float32(h), ColorGhostBackground, false)
+	DrawTextLines(screen, g.FontFace, strings.Join(lines, "\n"), int(sx+CardPaddingX*g.camera.Zoom), int(sy+CardPaddingY/2), ColorGhostText)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"card-flows/engine"
+)
+
+func TestGhostPreviewLines(t *testing.T) {
+	table := engine.NewTable("name", "qty")
+	for _, name := range []string{"apple", "pear", "plum", "fig"} {
+		table.AppendRow(name, len(name))
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []string
+	}{
+		{"table", table, []string{"name | qty", "apple | 5", "pear | 4", "plum | 4", "... 1 more rows"}},
+		{"empty table", engine.NewTable("a"), []string{"a", "(no rows)"}},
+		{"list", []interface{}{1, "two", nil, 4.5}, []string{"1", "two", "", "... 1 more items"}},
+		{"empty list", []interface{}{}, []string{"[]"}},
+		{"map", map[string]interface{}{"b": 2, "a": 1}, []string{"a: 1", "b: 2"}},
+		{"text", "one\ntwo\nthree\nfour", []string{"one", "two", "three", "..."}},
+		{"long text", strings.Repeat("x", 50), []string{strings.Repeat("x", GhostPreviewMaxChars-3) + "..."}},
+		{"empty text", "", []string{`""`}},
+		{"number", 42, []string{"42"}},
+		{"nil", nil, nil},
+	}
+	for _, tt := range tests {
+		if got := ghostPreviewLines(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestGhostPreviewToggles(t *testing.T) {
+	filename := "test_ghost_state.yaml"
+	defer os.Remove(filename)
+
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	a := g.AddTextCard(100, 100)
+	b := g.AddTextCard(400, 100)
+	g.camera.Zoom = 1
+
+	if !g.showsGhostPreview(a) {
+		t.Fatal("Expected previews to be shown by default")
+	}
+
+	// Hidden automatically when zoomed out
+	g.camera.Zoom = GhostPreviewMinZoom / 2
+	if g.showsGhostPreview(a) {
+		t.Error("Expected previews to be hidden at low zoom")
+	}
+	g.camera.Zoom = 1
+
+	g.ToggleGhostPreview(a)
+	if g.showsGhostPreview(a) || !g.showsGhostPreview(b) {
+		t.Error("Expected only the toggled card's preview to be hidden")
+	}
+
+	if err := SaveState(g, filename); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	g.ToggleGhostPreview(nil)
+	if g.showsGhostPreview(b) {
+		t.Error("Expected the global toggle to hide every preview")
+	}
+
+	g2 := NewGame()
+	if err := LoadState(g2, filename); err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if !g2.getCardByID(a.ID).HidePreview || g2.getCardByID(b.ID).HidePreview || g2.hideGhostPreviews {
+		t.Error("Expected per-card preview settings to survive save and load")
+	}
+}
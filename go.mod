@@ -5,6 +5,7 @@ go 1.24.11
 require (
 	github.com/hajimehoshi/ebiten/v2 v2.9.7
 	go.starlark.net v0.0.0-20260102030733-3fee463870c9
+	golang.org/x/crypto v0.42.0
 	golang.org/x/image v0.31.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"card-flows/engine"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font"
+)
+
+func (g *Game) AddGridCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "grid",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth * 1.5,
+		Height: DefaultCardHeight * 2,
+		Color:  ColorCardDefault,
+		Title:  "Grid",
+		Cells: [][]string{
+			{"A", "B", "C"},
+			{"", "", ""},
+			{"", "", ""},
+		},
+		Outputs: []Port{
+			{Name: "table", Type: "table"},
+		},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// gridToolbar lists the grid toolbar buttons in display order
+var gridToolbar = []struct {
+	Label  string
+	Action string
+}{
+	{"+R", "grid_add_row"},
+	{"-R", "grid_remove_row"},
+	{"+C", "grid_add_column"},
+	{"-C", "grid_remove_column"},
+}
+
+// GridTable converts grid cells to a Table; the first row holds the column names
+func GridTable(cells [][]string) *engine.Table {
+	if len(cells) == 0 {
+		return engine.NewTable()
+	}
+	cols := 0
+	for _, row := range cells {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+
+	t := engine.NewTable(columnNames(cells[0], cols)...)
+	for _, row := range cells[1:] {
+		values := make([]interface{}, cols)
+		for i := range values {
+			if i < len(row) {
+				values[i] = engine.ParseCell(row[i])
+			}
+		}
+		t.AppendRow(values...)
+	}
+	return t
+}
+
+// columnNames names cols columns from a header row. Blank names become the
+// column letter and repeated names get a numeric suffix.
+func columnNames(header []string, cols int) []string {
+	names := make([]string, cols)
+	seen := make(map[string]bool)
+	for i := range names {
+		name := ""
+		if i < len(header) {
+			name = strings.TrimSpace(header[i])
+		}
+		if name == "" {
+			name = columnLetter(i)
+		}
+		for base, n := name, 2; seen[name]; n++ {
+			name = base + "_" + strconv.Itoa(n)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// columnLetter returns the spreadsheet letter for a column index (0 -> A, 26 -> AA)
+func columnLetter(i int) string {
+	s := ""
+	for i >= 0 {
+		s = string(rune('A'+i%26)) + s
+		i = i/26 - 1
+	}
+	return s
+}
+
+func (c *Card) gridColumns() int {
+	if len(c.Cells) == 0 {
+		return 0
+	}
+	return len(c.Cells[0])
+}
+
+// padGridRows widens rows shorter than the header, which hand-edited files may
+// have, so that every row has a cell in each column
+func (c *Card) padGridRows() {
+	n := c.gridColumns()
+	for i := range c.Cells {
+		for len(c.Cells[i]) < n {
+			c.Cells[i] = append(c.Cells[i], "")
+		}
+	}
+}
+
+// AddGridRow appends an empty row
+func (c *Card) AddGridRow() {
+	c.Cells = append(c.Cells, make([]string, c.gridColumns()))
+}
+
+// RemoveGridRow removes the selected data row, or the last one when the header is selected.
+// The header row is never removed.
+func (c *Card) RemoveGridRow() {
+	if len(c.Cells) <= 1 {
+		return
+	}
+	row := c.SelRow
+	if row <= 0 || row >= len(c.Cells) {
+		row = len(c.Cells) - 1
+	}
+	c.Cells = append(c.Cells[:row], c.Cells[row+1:]...)
+	if c.SelRow >= len(c.Cells) {
+		c.SelRow = len(c.Cells) - 1
+	}
+}
+
+// AddGridColumn appends an empty column with a letter header
+func (c *Card) AddGridColumn() {
+	n := c.gridColumns()
+	if len(c.Cells) == 0 {
+		c.Cells = [][]string{{}}
+	}
+	c.padGridRows()
+	for i := range c.Cells {
+		c.Cells[i] = append(c.Cells[i], "")
+	}
+	c.Cells[0][n] = columnLetter(n)
+}
+
+// RemoveGridColumn removes the selected column, keeping at least one
+func (c *Card) RemoveGridColumn() {
+	n := c.gridColumns()
+	if n <= 1 {
+		return
+	}
+	col := c.SelCol
+	if col < 0 || col >= n {
+		col = n - 1
+	}
+	for i := range c.Cells {
+		// Rows loaded from a file may be shorter than the header
+		if col < len(c.Cells[i]) {
+			c.Cells[i] = append(c.Cells[i][:col], c.Cells[i][col+1:]...)
+		}
+	}
+	if c.SelCol >= n-1 {
+		c.SelCol = n - 2
+	}
+}
+
+// PasteTSV writes tab-separated text into the grid starting at the selected cell,
+// growing the grid as needed
+func (c *Card) PasteTSV(text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return
+	}
+	for i, line := range strings.Split(text, "\n") {
+		row := c.SelRow + i
+		for row >= len(c.Cells) {
+			c.AddGridRow()
+		}
+		for j, value := range strings.Split(line, "\t") {
+			col := c.SelCol + j
+			for col >= c.gridColumns() {
+				c.AddGridColumn()
+			}
+			for col >= len(c.Cells[row]) {
+				c.Cells[row] = append(c.Cells[row], "")
+			}
+			c.Cells[row][col] = value
+		}
+	}
+}
+
+// selectedCell returns the selected cell, or nil if the selection is out of range
+func (c *Card) selectedCell() *string {
+	if c.SelRow < 0 || c.SelRow >= len(c.Cells) || c.SelCol < 0 || c.SelCol >= len(c.Cells[c.SelRow]) {
+		return nil
+	}
+	return &c.Cells[c.SelRow][c.SelCol]
+}
+
+// gridLayout returns the world-space origin of the cell area and the toolbar row
+func (c *Card) gridLayout() (toolbarY, cellsX, cellsY float64) {
+	toolbarY = c.Y + HeaderHeight + GridPadding
+	cellsX = c.X + CardPaddingX
+	cellsY = toolbarY + GridToolbarHeight + GridPadding
+	return
+}
+
+// gridActionAt returns the toolbar action or "grid_cell" for the world position, or ""
+func (c *Card) gridActionAt(wx, wy float64) string {
+	toolbarY, cellsX, cellsY := c.gridLayout()
+	for i, b := range gridToolbar {
+		bx := c.X + CardPaddingX + float64(i)*(GridToolbarButtonWidth+GridPadding)
+		if wx >= bx && wx <= bx+GridToolbarButtonWidth && wy >= toolbarY && wy <= toolbarY+GridToolbarHeight {
+			return b.Action
+		}
+	}
+	if _, _, ok := c.gridCellAt(wx, wy, cellsX, cellsY); ok {
+		return "grid_cell"
+	}
+	return ""
+}
+
+func (c *Card) gridCellAt(wx, wy, cellsX, cellsY float64) (int, int, bool) {
+	if wx < cellsX || wy < cellsY || wx > c.X+c.Width-CardPaddingX || wy > c.Y+c.Height-FooterHeight {
+		return 0, 0, false
+	}
+	row := int((wy - cellsY) / GridCellHeight)
+	col := int((wx - cellsX) / GridCellWidth)
+	if row >= len(c.Cells) || col >= c.gridColumns() {
+		return 0, 0, false
+	}
+	return row, col, true
+}
+
+// selectGridCell selects the cell under the world position
+func (c *Card) selectGridCell(wx, wy float64) {
+	_, cellsX, cellsY := c.gridLayout()
+	if row, col, ok := c.gridCellAt(wx, wy, cellsX, cellsY); ok {
+		c.SelRow, c.SelCol = row, col
+	}
+}
+
+func (c *Card) drawGrid(screen *ebiten.Image, g *Game, cw, ch float64) {
+	zoom := g.camera.Zoom
+	toolbarY, cellsX, cellsY := c.gridLayout()
+
+	// Toolbar
+	for i, b := range gridToolbar {
+		bx := c.X + CardPaddingX + float64(i)*(GridToolbarButtonWidth+GridPadding)
+		sbx, sby := g.camera.WorldToScreen(bx, toolbarY, cw, ch)
+		vector.DrawFilledRect(screen, float32(sbx), float32(sby), float32(GridToolbarButtonWidth*zoom), float32(GridToolbarHeight*zoom), ColorButtonBackground, false)
+		DrawTextLines(screen, g.FontFace, b.Label, int(sbx+4*zoom), int(sby+2*zoom), color.White)
+	}
+
+	// Cells, clipped to the card body
+	maxX := c.X + c.Width - CardPaddingX
+	maxY := c.Y + c.Height - FooterHeight
+	editing := g.input != nil && g.input.EditingCard == c
+	for r, row := range c.Cells {
+		y := cellsY + float64(r)*GridCellHeight
+		if y+GridCellHeight > maxY {
+			break
+		}
+		for col, value := range row {
+			x := cellsX + float64(col)*GridCellWidth
+			if x+GridCellWidth > maxX {
+				break
+			}
+			sx, sy := g.camera.WorldToScreen(x, y, cw, ch)
+			sw, sh := GridCellWidth*zoom, GridCellHeight*zoom
+
+			fill := ColorGridCell
+			if r == 0 {
+				fill = ColorGridHeader
+			}
+			vector.DrawFilledRect(screen, float32(sx), float32(sy), float32(sw), float32(sh), fill, false)
+			vector.StrokeRect(screen, float32(sx), float32(sy), float32(sw), float32(sh), 1, ColorGridLine, false)
+			if r == c.SelRow && col == c.SelCol {
+				vector.StrokeRect(screen, float32(sx), float32(sy), float32(sw), float32(sh), 2, ColorCardHover, false)
+				if editing && (time.Now().UnixMilli()/CursorBlinkRate)%2 == 0 {
+					value += "|"
+				}
+			}
+			label := truncateToWidth(g.FontFace, value, sw-4*zoom)
+			DrawTextLines(screen, g.FontFace, label, int(sx+3*zoom), int(sy+2*zoom), color.White)
+		}
+	}
+}
+
+// truncateToWidth shortens s so that it renders within maxWidth pixels
+func truncateToWidth(face font.Face, s string, maxWidth float64) string {
+	if face == nil {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && float64(font.MeasureString(face, string(runes))>>6) > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes)
+}
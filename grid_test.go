@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"card-flows/engine"
+)
+
+func newGridGame() (*Game, *Card) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	return g, g.AddGridCard(100, 100)
+}
+
+func TestGridOutputsTable(t *testing.T) {
+	g, grid := newGridGame()
+	grid.Cells = [][]string{
+		{"name", "qty", ""},
+		{"apple", "3", "1.5"},
+		{"pear", "", "x"},
+	}
+
+	g.engine.Run()
+
+	table, ok := g.engine.Memory[grid.ID+":table"].(*engine.Table)
+	if !ok {
+		t.Fatalf("Expected a table output, got %T", g.engine.Memory[grid.ID+":table"])
+	}
+	if want := []string{"name", "qty", "C"}; !reflect.DeepEqual(table.Columns, want) {
+		t.Errorf("Expected columns %v, got %v", want, table.Columns)
+	}
+	if want := []interface{}{"apple", 3, 1.5}; !reflect.DeepEqual(table.Row(0), want) {
+		t.Errorf("Expected row 0 %v, got %v", want, table.Row(0))
+	}
+	if want := []interface{}{"pear", nil, "x"}; !reflect.DeepEqual(table.Row(1), want) {
+		t.Errorf("Expected row 1 %v, got %v", want, table.Row(1))
+	}
+}
+
+func TestGridDuplicateHeaders(t *testing.T) {
+	table := GridTable([][]string{{"a", "a", "a"}})
+	if want := []string{"a", "a_2", "a_3"}; !reflect.DeepEqual(table.Columns, want) {
+		t.Errorf("Expected columns %v, got %v", want, table.Columns)
+	}
+}
+
+func TestGridRowsAndColumns(t *testing.T) {
+	_, grid := newGridGame()
+
+	grid.AddGridColumn()
+	if len(grid.Cells[0]) != 4 || grid.Cells[0][3] != "D" {
+		t.Errorf("Expected new column with header D, got %v", grid.Cells[0])
+	}
+
+	grid.AddGridRow()
+	if len(grid.Cells) != 4 || len(grid.Cells[3]) != 4 {
+		t.Errorf("Expected 4 rows of 4 cells, got %v", grid.Cells)
+	}
+
+	grid.Cells[1][0] = "keep"
+	grid.Cells[2][0] = "drop"
+	grid.SelRow, grid.SelCol = 2, 1
+	grid.RemoveGridRow()
+	grid.RemoveGridColumn()
+	if len(grid.Cells) != 3 || grid.Cells[1][0] != "keep" || grid.Cells[2][0] != "" {
+		t.Errorf("Expected the selected row to be removed, got %v", grid.Cells)
+	}
+	if want := []string{"A", "C", "D"}; !reflect.DeepEqual(grid.Cells[0], want) {
+		t.Errorf("Expected header %v, got %v", want, grid.Cells[0])
+	}
+
+	// The header row and the last column are never removed
+	grid.Cells = [][]string{{"only"}}
+	grid.SelRow, grid.SelCol = 0, 0
+	grid.RemoveGridRow()
+	grid.RemoveGridColumn()
+	if !reflect.DeepEqual(grid.Cells, [][]string{{"only"}}) {
+		t.Errorf("Expected the header cell to remain, got %v", grid.Cells)
+	}
+}
+
+func TestGridRemoveColumnRaggedRows(t *testing.T) {
+	_, grid := newGridGame()
+	grid.Cells = [][]string{
+		{"a", "b", "c"},
+		{"1"},
+		{"2", "3", "4"},
+	}
+	grid.SelCol = 2
+	grid.RemoveGridColumn()
+	want := [][]string{{"a", "b"}, {"1"}, {"2", "3"}}
+	if !reflect.DeepEqual(grid.Cells, want) {
+		t.Errorf("Expected %v, got %v", want, grid.Cells)
+	}
+}
+
+func TestGridPasteIntoShortRow(t *testing.T) {
+	_, grid := newGridGame()
+	grid.Cells = [][]string{
+		{"a", "b", "c"},
+		{"1"},
+	}
+	grid.SelRow, grid.SelCol = 1, 2
+	grid.PasteTSV("x")
+	grid.AddGridColumn()
+	want := [][]string{{"a", "b", "c", "D"}, {"1", "", "x", ""}}
+	if !reflect.DeepEqual(grid.Cells, want) {
+		t.Errorf("Expected %v, got %v", want, grid.Cells)
+	}
+}
+
+func TestGridPasteTSV(t *testing.T) {
+	_, grid := newGridGame()
+	grid.SelRow, grid.SelCol = 1, 1
+
+	grid.PasteTSV("1\t2\t3\r\n4\t5\t6\r\n7\t8\t9\r\n")
+
+	want := [][]string{
+		{"A", "B", "C", "D"},
+		{"", "1", "2", "3"},
+		{"", "4", "5", "6"},
+		{"", "7", "8", "9"},
+	}
+	if !reflect.DeepEqual(grid.Cells, want) {
+		t.Errorf("Expected %v, got %v", want, grid.Cells)
+	}
+}
+
+func TestGridSaveLoad(t *testing.T) {
+	filename := "test_grid_state.yaml"
+	defer os.Remove(filename)
+
+	g, grid := newGridGame()
+	grid.Cells = [][]string{{"city", "pop"}, {"Oslo", "700000"}}
+
+	if err := SaveState(g, filename); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	g2 := NewGame()
+	g2.cards = []*Card{}
+	g2.arrows = []*Arrow{}
+	if err := LoadState(g2, filename); err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+
+	loaded := g2.getCardByID(grid.ID)
+	if loaded == nil {
+		t.Fatal("Grid card not loaded")
+	}
+	if !reflect.DeepEqual(loaded.Cells, grid.Cells) {
+		t.Errorf("Expected cells %v, got %v", grid.Cells, loaded.Cells)
+	}
+}
+
+func TestGridEditInvalidatesCache(t *testing.T) {
+	g, grid := newGridGame()
+	grid.Cells = [][]string{{"n"}, {"1"}}
+
+	g.engine.Run()
+	first := g.engine.ExecutionCache[grid.ID].InputHash
+
+	grid.SelRow, grid.SelCol = 1, 0
+	g.SetCardText(grid, "2")
+	g.engine.Run()
+
+	if g.engine.ExecutionCache[grid.ID].InputHash == first {
+		t.Error("Expected editing a cell to change the cache hash")
+	}
+	table := g.engine.Memory[grid.ID+":table"].(*engine.Table)
+	if got := table.Value(0, 0); got != 2 {
+		t.Errorf("Expected updated value 2, got %v", got)
+	}
+}
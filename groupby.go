@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+
+	"card-flows/transform"
+)
+
+func (g *Game) AddGroupByCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "groupby",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth * 1.5,
+		Height: DefaultCardHeight * 2.5,
+		Color:  ColorCardDefault,
+		Title:  "Table:group_by",
+		Inputs: []Port{
+			{Name: "table", Type: "table"},
+		},
+		Outputs: []Port{
+			{Name: "result", Type: "table"},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// setGroupBy stores a group-by spec in the card's params
+func (c *Card) setGroupBy(spec transform.GroupBy) {
+	c.setParams(spec.Params())
+}
+
+func (c *Card) groupByFields(g *Game) []formField {
+	columns := g.inputColumns(c, "table")
+	spec := transform.GroupByFromParams(c.paramsWithDefaults())
+	var fields []formField
+
+	for i, key := range spec.Keys {
+		fields = append(fields, columnField("Group by", key, columns, true, func(v string) {
+			if v == transform.None {
+				spec.Keys = append(spec.Keys[:i], spec.Keys[i+1:]...)
+			} else {
+				spec.Keys[i] = v
+			}
+			c.setGroupBy(spec)
+		}))
+	}
+	fields = append(fields, columnField("Group by", transform.None, columns, true, func(v string) {
+		if v == transform.None {
+			return
+		}
+		spec.Keys = append(spec.Keys, v)
+		c.setGroupBy(spec)
+	}))
+
+	for i, agg := range spec.Aggregations {
+		fields = append(fields, columnField("Aggregate", agg.Column, columns, true, func(v string) {
+			if v == transform.None {
+				spec.Aggregations = append(spec.Aggregations[:i], spec.Aggregations[i+1:]...)
+			} else {
+				spec.Aggregations[i].Column = v
+			}
+			c.setGroupBy(spec)
+		}))
+		fields = append(fields, formField{Label: "  using", Value: agg.Op, Options: transform.AggregateOps, Set: func(v string) {
+			spec.Aggregations[i].Op = v
+			c.setGroupBy(spec)
+		}})
+	}
+	fields = append(fields, columnField("Aggregate", transform.None, columns, true, func(v string) {
+		if v == transform.None {
+			return
+		}
+		spec.Aggregations = append(spec.Aggregations, transform.Aggregation{Column: v, Op: "sum"})
+		c.setGroupBy(spec)
+	}))
+
+	pivot := spec.Pivot
+	if pivot == "" {
+		pivot = transform.None
+	}
+	fields = append(fields, columnField("Pivot", pivot, columns, true, func(v string) {
+		spec.Pivot = v
+		if v == transform.None {
+			spec.Pivot = ""
+		}
+		c.setGroupBy(spec)
+	}))
+
+	return append(fields, formField{Label: "Eject to script", Action: "eject"})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"card-flows/engine"
+	"card-flows/transform"
+)
+
+var salesCells = [][]string{
+	{"region", "quarter", "total", "rep"},
+	{"north", "Q1", "10", "ann"},
+	{"south", "Q1", "5", "bob"},
+	{"north", "Q2", "2.5", "ann"},
+	{"north", "Q1", "1", "cy"},
+	{"south", "Q2", "", "bob"},
+}
+
+// newGroupByFlow wires a grid card into a group-by card
+func newGroupByFlow(spec transform.GroupBy) (*Game, *Card) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+
+	grid := g.AddGridCard(100, 100)
+	grid.Cells = salesCells
+	gb := g.AddGroupByCard(100, 400)
+	gb.setGroupBy(spec)
+	wireCards(g, grid, "table", gb, "table")
+	return g, gb
+}
+
+func tableRows(tb *engine.Table) [][]interface{} {
+	var rows [][]interface{}
+	for i := 0; i < tb.NumRows(); i++ {
+		rows = append(rows, tb.Row(i))
+	}
+	return rows
+}
+
+func TestGroupByAggregations(t *testing.T) {
+	g, gb := newGroupByFlow(transform.GroupBy{
+		Keys: []string{"region"},
+		Aggregations: []transform.Aggregation{
+			{Column: "total", Op: "sum"},
+			{Column: "total", Op: "count"},
+			{Column: "total", Op: "mean"},
+			{Column: "total", Op: "max"},
+			{Column: "rep", Op: "distinct"},
+		},
+	})
+	g.engine.Run()
+	if gb.LastError != "" {
+		t.Fatalf("Unexpected error: %s", gb.LastError)
+	}
+
+	table := g.engine.Memory[gb.ID+":result"].(*engine.Table)
+	wantCols := []string{"region", "total_sum", "total_count", "total_mean", "total_max", "rep_distinct"}
+	if !reflect.DeepEqual(table.Columns, wantCols) {
+		t.Errorf("Expected columns %v, got %v", wantCols, table.Columns)
+	}
+	wantRows := [][]interface{}{
+		{"north", 13.5, 3, 4.5, 10, 2},
+		{"south", 5, 1, 5.0, 5, 1},
+	}
+	if got := tableRows(table); !reflect.DeepEqual(got, wantRows) {
+		t.Errorf("Expected rows %v, got %v", wantRows, got)
+	}
+}
+
+func TestGroupByPivot(t *testing.T) {
+	g, gb := newGroupByFlow(transform.GroupBy{
+		Keys:         []string{"region"},
+		Aggregations: []transform.Aggregation{{Column: "total", Op: "sum"}},
+		Pivot:        "quarter",
+	})
+	g.engine.Run()
+	if gb.LastError != "" {
+		t.Fatalf("Unexpected error: %s", gb.LastError)
+	}
+
+	table := g.engine.Memory[gb.ID+":result"].(*engine.Table)
+	if want := []string{"region", "Q1", "Q2"}; !reflect.DeepEqual(table.Columns, want) {
+		t.Errorf("Expected columns %v, got %v", want, table.Columns)
+	}
+	wantRows := [][]interface{}{
+		{"north", 11, 2.5},
+		{"south", 5, nil},
+	}
+	if got := tableRows(table); !reflect.DeepEqual(got, wantRows) {
+		t.Errorf("Expected rows %v, got %v", wantRows, got)
+	}
+}
+
+func TestGroupByValidation(t *testing.T) {
+	g, gb := newGroupByFlow(transform.GroupBy{
+		Keys:         []string{"country"},
+		Aggregations: []transform.Aggregation{{Column: "total", Op: "sum"}},
+	})
+	g.engine.Run()
+	if !strings.Contains(gb.LastError, `column "country" is not in the input table`) {
+		t.Errorf("Expected a missing column error, got %q", gb.LastError)
+	}
+
+	// The form flags the bad column once the upstream schema is known
+	fields := gb.groupByFields(g)
+	if fields[0].Value != "country" || !fields[0].Invalid {
+		t.Errorf("Expected the group-by field to be flagged, got %+v", fields[0])
+	}
+}
+
+func TestGroupByFormFields(t *testing.T) {
+	g, gb := newGroupByFlow(transform.GroupBy{})
+	g.engine.Run()
+
+	// The empty "Group by" row cycles through the upstream columns
+	fields := gb.groupByFields(g)
+	fields[0].Set(nextOption(fields[0].Value, fields[0].Options))
+	if got := transform.GroupByFromParams(gb.Params).Keys; !reflect.DeepEqual(got, []string{"region"}) {
+		t.Fatalf("Expected group by [region], got %v", got)
+	}
+
+	// Then add an aggregation and switch its operation
+	fields = gb.groupByFields(g)
+	add := fields[2]
+	if add.Label != "Aggregate" || add.Value != transform.None {
+		t.Fatalf("Expected the add-aggregation row, got %+v", add)
+	}
+	add.Set("total")
+	fields = gb.groupByFields(g)
+	fields[3].Set(nextOption(fields[3].Value, fields[3].Options))
+
+	spec := transform.GroupByFromParams(gb.Params)
+	if want := []transform.Aggregation{{Column: "total", Op: "count"}}; !reflect.DeepEqual(spec.Aggregations, want) {
+		t.Errorf("Expected aggregations %v, got %v", want, spec.Aggregations)
+	}
+
+	// Without an input there are no columns, so the add rows add nothing
+	lone := g.AddGroupByCard(0, 0)
+	for _, f := range lone.groupByFields(g) {
+		if f.Set != nil && f.Value == transform.None {
+			f.Set(nextOption(f.Value, f.Options))
+		}
+	}
+	if spec := transform.GroupByFromParams(lone.Params); len(spec.Keys) != 0 || len(spec.Aggregations) != 0 {
+		t.Errorf("Expected no rows added without columns, got %+v", spec)
+	}
+}
+
+func TestGroupBySaveLoadAndEject(t *testing.T) {
+	filename := "test_groupby_state.yaml"
+	defer os.Remove(filename)
+
+	g, gb := newGroupByFlow(transform.GroupBy{
+		Keys:         []string{"region"},
+		Aggregations: []transform.Aggregation{{Column: "total", Op: "sum"}},
+	})
+	if err := SaveState(g, filename); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+	g2 := NewGame()
+	g2.cards = []*Card{}
+	g2.arrows = []*Arrow{}
+	if err := LoadState(g2, filename); err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	loaded := g2.getCardByID(gb.ID)
+	g2.engine.Run()
+	want := tableRows(g2.engine.Memory[loaded.ID+":result"].(*engine.Table))
+	if len(want) != 2 {
+		t.Fatalf("Expected 2 groups after loading, got %v (error %q)", want, loaded.LastError)
+	}
+
+	// Ejecting keeps the output and turns the form into code
+	if err := g2.EjectToScript(loaded); err != nil {
+		t.Fatalf("Eject failed: %v", err)
+	}
+	if loaded.Type != "script" || !strings.Contains(loaded.Text, "# Group by region") {
+		t.Fatalf("Expected a script card with the generated code, got %q", loaded.Type)
+	}
+	g2.engine.Run()
+	if got := tableRows(g2.engine.Memory[loaded.ID+":result"].(*engine.Table)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected ejected script to produce %v, got %v", want, got)
+	}
+
+	// Editing the script reruns it
+	loaded.Text = strings.Replace(loaded.Text, `"sum"`, `"count"`, 1)
+	g2.engine.Run()
+	if got := tableRows(g2.engine.Memory[loaded.ID+":result"].(*engine.Table)); reflect.DeepEqual(got, want) {
+		t.Error("Expected the edited script to produce a different result")
+	}
+}
@@ -451,34 +451,9 @@ func (is *InputSystem) handleWiring(mx, my int, wx, wy float64) {
 			// 	continue
 			// }
 
-			// --- Single Input Constraint: Remove existing connections to this input ---
-			// An input port can only have one incoming arrow.
-			filteredArrows := g.arrows[:0] // Reuse storage
-			removedCount := 0
-			for _, arrow := range g.arrows {
-				if arrow.ToCardID == targetCard.ID && arrow.ToPort == targetPort.Name {
-					removedCount++
-					fmt.Printf("Removing existing arrow to %s:%s\n", targetCard.Title, targetPort.Name)
-					// Unregister subscription before removing
-					g.UnregisterSubscription(arrow.FromCardID, arrow.ToCardID, arrow.ToPort)
-					continue // Skip (delete) this arrow
-				}
-				filteredArrows = append(filteredArrows, arrow)
-			}
-			g.arrows = filteredArrows
-
 			// --- Create Connection ---
-			newArrow := &Arrow{
-				FromCardID: is.dragStartCard.ID,
-				FromPort:   is.dragStartPort,
-				ToCardID:   targetCard.ID,
-				ToPort:     targetPort.Name,
-				Color:      ColorArrowDefault,
-			}
-			g.arrows = append(g.arrows, newArrow)
-
-			// Register subscription for pub-sub
-			g.RegisterSubscription(is.dragStartCard.ID, targetCard.ID, targetPort.Name)
+			// Replaces the existing wire unless the port takes many
+			g.Connect(is.dragStartCard, is.dragStartPort, targetCard, targetPort.Name)
 
 			// Immediately propagate text if source card has text
 			g.PropagateText(is.dragStartCard)
@@ -23,6 +23,7 @@ type Host interface {
 	SaveState(filename string) error
 	GetCardAt(wx, wy float64) interface{}
 	AddTextCardHandle(wx, wy float64) interface{}
+	AddNoteCardHandle(wx, wy float64) interface{}
 	DeleteCardHandle(card interface{})
 	DuplicateCardHandle(card interface{})
 	IsInputPortConnected(cardID, portName string) bool
@@ -35,7 +36,16 @@ type Host interface {
 	GetCornerAt(card interface{}, wx, wy, zoom float64) int
 	GetPortAt(card interface{}, wx, wy, zoom float64) *PortInfo
 	GetOutputPortPosition(card interface{}, portName string) (float64, float64)
-	CheckActionButton(card interface{}, wx, wy float64) string // returns "delete", "duplicate", or ""
+	CheckActionButton(card interface{}, wx, wy float64) string              // returns "delete", "duplicate", a card-specific action, or ""
+	PerformCardAction(card interface{}, action string, wx, wy float64) bool // returns true if the click is fully handled
+	Paste()
+	ToggleGhostPreview(card interface{}) // nil toggles the previews of all cards
+	SelectCard(card interface{})         // shows the card in the inspector panel
+	ToggleMarkdown(card interface{})     // switches the card between plain text and markdown
+	DropCard(card interface{})           // called when a dragged card is released
+	ToggleSelected(card interface{})     // adds or removes the card from the selection; nil clears it
+	OpenCard(card interface{}) bool      // opens a composite card's inner flow; false for other cards
+	ConnectPorts(from interface{}, fromPort string, to interface{}, toPort string)
 	ApplyPan(dx, dy float64)
 	RegisterSubscription(fromID, toID, toPort string)
 	UnregisterSubscription(fromID, toID, toPort string)
@@ -110,6 +120,31 @@ func (is *InputSystem) handleControlKeys() {
 	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyS) {
 		_ = is.host.SaveState("state.yaml")
 	}
+
+	// --- Ghost Previews ---
+	if inpututil.IsKeyJustPressed(ebiten.KeyF3) {
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			mx, my := ebiten.CursorPosition()
+			if card := is.host.GetCardAt(is.host.ScreenToWorld(float64(mx), float64(my))); card != nil {
+				is.host.ToggleGhostPreview(card)
+			}
+		} else {
+			is.host.ToggleGhostPreview(nil)
+		}
+	}
+
+	// --- Markdown ---
+	if inpututil.IsKeyJustPressed(ebiten.KeyF6) {
+		mx, my := ebiten.CursorPosition()
+		if card := is.host.GetCardAt(is.host.ScreenToWorld(float64(mx), float64(my))); card != nil {
+			is.host.ToggleMarkdown(card)
+		}
+	}
+
+	// --- Paste ---
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyV) && is.EditingCard == nil {
+		is.host.Paste()
+	}
 }
 
 func (is *InputSystem) handleZoom() {
@@ -193,13 +228,24 @@ func (is *InputSystem) handleMouseInteraction(mx, my int, wx, wy float64, overUI
 				} else if action == "duplicate" {
 					is.host.DuplicateCardHandle(card)
 					return
+				} else if action != "" && is.host.PerformCardAction(card, action, wx, wy) {
+					return
+				}
+
+				if is.host.OpenCard(card) {
+					return
 				}
 
 				// Start editing for text cards — stop any panning to avoid camera jump
 				is.isPanning = false
 				is.EditingCard = card
 			} else {
-				newCard := is.host.AddTextCardHandle(wx, wy)
+				var newCard interface{}
+				if ebiten.IsKeyPressed(ebiten.KeyShift) {
+					newCard = is.host.AddNoteCardHandle(wx, wy)
+				} else {
+					newCard = is.host.AddTextCardHandle(wx, wy)
+				}
 				// New card created for editing — ensure panning is stopped
 				is.isPanning = false
 				is.EditingCard = newCard
@@ -209,6 +255,11 @@ func (is *InputSystem) handleMouseInteraction(mx, my int, wx, wy float64, overUI
 
 		// Single click logic - check action buttons first
 		if card := is.host.GetCardAt(wx, wy); card != nil {
+			if ebiten.IsKeyPressed(ebiten.KeyControl) {
+				is.host.ToggleSelected(card)
+				return
+			}
+			is.host.SelectCard(card)
 			action := is.host.CheckActionButton(card, wx, wy)
 			if action == "delete" {
 				is.host.DeleteCardHandle(card)
@@ -216,6 +267,9 @@ func (is *InputSystem) handleMouseInteraction(mx, my int, wx, wy float64, overUI
 			} else if action == "duplicate" {
 				is.host.DuplicateCardHandle(card)
 				return
+			} else if action != "" {
+				is.host.PerformCardAction(card, action, wx, wy)
+				return
 			}
 
 			// Check if clicking on a resize corner
@@ -235,9 +289,10 @@ func (is *InputSystem) handleMouseInteraction(mx, my int, wx, wy float64, overUI
 				is.DragOffsetY = wy - y
 			}
 		} else {
-			// Clicked on empty space - clear active card so panning can start
+			// Clicked on empty space - clear active card and selection so panning can start
 			is.ActiveCard = nil
 			is.IsHot = false
+			is.host.ToggleSelected(nil)
 		}
 	} else if is.ResizingCard != nil {
 		is.handleResizing(wx, wy)
@@ -316,6 +371,7 @@ func (is *InputSystem) handleDragging(wx, wy float64) {
 		is.host.SetCardBounds(card, newX, newY, w, h)
 	} else {
 		// release
+		is.host.DropCard(is.ActiveCard)
 		is.ActiveCard = nil
 		is.IsHot = false
 	}
@@ -373,7 +429,12 @@ func (is *InputSystem) handleWiring(mx, my int, wx, wy float64) {
 			is.HoveredPortInfo = nil
 		}()
 
-		// connection resolution is delegated to host; simplified here
+		// Dropped on an input port: the host validates and makes the connection
+		if card := is.host.GetCardAt(wx, wy); card != nil {
+			if portInfo := is.host.GetPortAt(card, wx, wy, 1.0); portInfo != nil && portInfo.IsInput {
+				is.host.ConnectPorts(is.DragStartCard, is.DragStartPort, card, portInfo.Name)
+			}
+		}
 	}
 }
 
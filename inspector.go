@@ -0,0 +1,560 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+
+	"card-flows/engine"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Inspector is the data preview docked at the bottom of the window.
+// It shows one output port of the selected card as a table or a tree.
+type Inspector struct {
+	CardID string
+	Port   string
+	Hidden bool
+
+	Scroll  int     // first visible row or tree line
+	ScrollX float64 // horizontal table offset in pixels
+
+	SortColumn string // "" keeps the original row order
+	SortDesc   bool
+	Widths     map[string]float64 // column widths set by dragging header borders
+	Expanded   map[string]bool    // open tree paths; the root is always open
+
+	// Sorted view of the current table, rebuilt when the table or sort changes
+	order      []int
+	orderTable *engine.Table
+	orderKey   string
+
+	resizing    string // column whose border is being dragged
+	resizeX     float64
+	resizeW     float64
+	draggingBar bool
+}
+
+// treeLine is one visible line of the tree view
+type treeLine struct {
+	Depth      int
+	Path       string
+	Text       string
+	Expandable bool
+	Open       bool
+}
+
+// Select shows the card in the inspector, starting on its first output port
+func (in *Inspector) Select(c *Card) {
+	if c == nil || c.ID == in.CardID {
+		return
+	}
+	port := ""
+	if len(c.Outputs) > 0 {
+		port = c.Outputs[0].Name
+	}
+	*in = Inspector{CardID: c.ID, Hidden: in.Hidden}
+	in.SelectPort(port)
+}
+
+// SelectPort switches to another output port of the same card
+func (in *Inspector) SelectPort(port string) {
+	in.Port = port
+	in.Scroll, in.ScrollX = 0, 0
+	in.SortColumn, in.SortDesc = "", false
+	in.Expanded = nil
+}
+
+// ToggleSort cycles a column through ascending, descending and unsorted
+func (in *Inspector) ToggleSort(column string) {
+	switch {
+	case in.SortColumn != column:
+		in.SortColumn, in.SortDesc = column, false
+	case !in.SortDesc:
+		in.SortDesc = true
+	default:
+		in.SortColumn, in.SortDesc = "", false
+	}
+}
+
+// ColumnWidth returns the displayed width of a column
+func (in *Inspector) ColumnWidth(column string) float64 {
+	if w, ok := in.Widths[column]; ok {
+		return w
+	}
+	return InspectorColumnWidth
+}
+
+// SetColumnWidth resizes a column, keeping it wide enough to grab again
+func (in *Inspector) SetColumnWidth(column string, w float64) {
+	if in.Widths == nil {
+		in.Widths = map[string]float64{}
+	}
+	in.Widths[column] = math.Max(w, InspectorMinColumnWidth)
+}
+
+// ToggleExpanded opens or closes a tree node
+func (in *Inspector) ToggleExpanded(path string) {
+	if in.Expanded == nil {
+		in.Expanded = map[string]bool{}
+	}
+	in.Expanded[path] = !in.Expanded[path]
+}
+
+// viewOrder returns the table rows in display order, or nil for the original order.
+// The index is cached so a million-row table is only sorted when the sort changes.
+func (in *Inspector) viewOrder(t *engine.Table) []int {
+	col := t.ColumnIndex(in.SortColumn)
+	if in.SortColumn == "" || col < 0 {
+		return nil
+	}
+	key := fmt.Sprintf("%s:%v", in.SortColumn, in.SortDesc)
+	if in.orderTable != t || in.orderKey != key || len(in.order) != t.NumRows() {
+		in.order = sortedOrder(t, col, in.SortDesc)
+		in.orderTable, in.orderKey = t, key
+	}
+	return in.order
+}
+
+// sortedOrder returns a stable index of the table rows sorted by one column, blanks last
+func sortedOrder(t *engine.Table, col int, desc bool) []int {
+	order := make([]int, t.NumRows())
+	for i := range order {
+		order[i] = i
+	}
+	values := t.Data[col]
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := values[order[i]], values[order[j]]
+		if blankA, blankB := isBlank(a), isBlank(b); blankA || blankB {
+			return !blankA && blankB
+		}
+		if desc {
+			return compareCells(b, a) < 0
+		}
+		return compareCells(a, b) < 0
+	})
+	return order
+}
+
+func isBlank(v interface{}) bool {
+	return v == nil || v == ""
+}
+
+// compareCells orders numbers numerically before text, and text without case
+func compareCells(a, b interface{}) int {
+	na, aNum := chartNumber(a)
+	nb, bNum := chartNumber(b)
+	switch {
+	case aNum && bNum:
+		if na < nb {
+			return -1
+		} else if na > nb {
+			return 1
+		}
+		return 0
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	return strings.Compare(strings.ToLower(fmt.Sprint(a)), strings.ToLower(fmt.Sprint(b)))
+}
+
+// clampScroll keeps the first visible row within the rows that can be scrolled to
+func clampScroll(scroll, total, visible int) int {
+	if scroll > total-visible {
+		scroll = total - visible
+	}
+	if scroll < 0 {
+		scroll = 0
+	}
+	return scroll
+}
+
+// scrollbarThumb returns the thumb offset and length within a track of the given length
+func scrollbarThumb(scroll, total, visible int, track float64) (float64, float64) {
+	if total <= visible || total == 0 {
+		return 0, track
+	}
+	length := math.Max(track*float64(visible)/float64(total), InspectorMinThumb)
+	return (track - length) * float64(scroll) / float64(total-visible), length
+}
+
+// scrollAt maps a position along the scrollbar track to the first visible row
+func scrollAt(pos, track float64, total, visible int) int {
+	if track <= 0 {
+		return 0
+	}
+	return clampScroll(int(pos/track*float64(total)-float64(visible)/2), total, visible)
+}
+
+// treeWindow flattens a value into tree lines and returns the lines from first to
+// first+n along with the total line count. Only lines in the window are formatted.
+func treeWindow(v interface{}, expanded map[string]bool, first, n int) ([]treeLine, int) {
+	var lines []treeLine
+	count := 0
+	inWindow := func() bool { return count >= first && count < first+n }
+	emit := func(l treeLine) {
+		if inWindow() {
+			lines = append(lines, l)
+		}
+		count++
+	}
+
+	// A plain string shows one line per text line
+	if s, ok := v.(string); ok {
+		for i, line := range strings.Split(s, "\n") {
+			emit(treeLine{Path: fmt.Sprintf("$%d", i), Text: secretStore.Redact(line)})
+		}
+		return lines, count
+	}
+
+	var walk func(label string, v interface{}, path string, depth int)
+	walk = func(label string, v interface{}, path string, depth int) {
+		summary := treeSummary(v)
+		if summary == "" {
+			emit(treeLine{Depth: depth, Path: path, Text: label + previewValue(v)})
+			return
+		}
+		open := depth == 0 || expanded[path]
+		emit(treeLine{Depth: depth, Path: path, Text: label + summary, Expandable: true, Open: open})
+		if !open {
+			return
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(m))
+			for k := range m {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(k+": ", m[k], path+"."+k, depth+1)
+			}
+			return
+		}
+		for i, item := range v.([]interface{}) {
+			// Leaves outside the window are only counted, keeping long lists cheap
+			if !inWindow() && treeSummary(item) == "" {
+				count++
+				continue
+			}
+			walk(fmt.Sprintf("[%d] ", i), item, fmt.Sprintf("%s[%d]", path, i), depth+1)
+		}
+	}
+	walk("", v, "$", 0)
+	return lines, count
+}
+
+// treeSummary describes a map or list for its tree line, or returns "" for leaves
+func treeSummary(v interface{}) string {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return fmt.Sprintf("{%d keys}", len(val))
+	case []interface{}:
+		return fmt.Sprintf("[%d items]", len(val))
+	}
+	return ""
+}
+
+// inspectorRect returns the panel bounds in screen pixels
+func (g *Game) inspectorRect() (x, y, w, h float64) {
+	h = math.Min(InspectorHeight, float64(g.screenHeight)/2)
+	return 0, float64(g.screenHeight) - h, float64(g.screenWidth), h
+}
+
+// overInspector reports whether the panel takes the mouse, including drags that left it
+func (g *Game) overInspector(mx, my int) bool {
+	if g.inspector.Hidden {
+		return false
+	}
+	if g.inspector.resizing != "" || g.inspector.draggingBar {
+		return true
+	}
+	_, y, _, _ := g.inspectorRect()
+	return float64(my) >= y
+}
+
+// inspectedValue returns the selected card and the value of its selected port
+func (g *Game) inspectedValue() (*Card, interface{}) {
+	c := g.getCardByID(g.inspector.CardID)
+	if c == nil || g.engine == nil {
+		return c, nil
+	}
+	return c, g.engine.Memory[c.ID+":"+g.inspector.Port]
+}
+
+// inspectorBody returns the area below the tabs and the number of data rows that fit
+func (g *Game) inspectorBody(table bool) (x, y, w, h float64, rows int) {
+	x, y, w, h = g.inspectorRect()
+	y += InspectorTabHeight
+	h -= InspectorTabHeight
+	rowsH := h
+	if table {
+		rowsH -= InspectorRowHeight
+	}
+	return x, y, w - InspectorScrollbarWidth, h, int(math.Max(rowsH/InspectorRowHeight, 0))
+}
+
+// inspectorRows returns the scrollable row count of the value and whether it is a table
+func (g *Game) inspectorRows(v interface{}) (int, bool) {
+	if t, ok := v.(*engine.Table); ok {
+		return t.NumRows(), true
+	}
+	_, total := treeWindow(v, g.inspector.Expanded, 0, 0)
+	return total, false
+}
+
+// columnEdges returns the screen x of each column's left edge plus the final right edge
+func (g *Game) columnEdges(t *engine.Table, x float64) []float64 {
+	edges := make([]float64, len(t.Columns)+1)
+	edges[0] = x + InspectorGutterWidth - g.inspector.ScrollX
+	for i, name := range t.Columns {
+		edges[i+1] = edges[i] + g.inspector.ColumnWidth(name)
+	}
+	return edges
+}
+
+// updateInspector handles the toggle key, scrolling, tabs, sorting and resizing
+func (g *Game) updateInspector() {
+	in := &g.inspector
+	if inpututil.IsKeyJustPressed(ebiten.KeyF4) {
+		in.Hidden = !in.Hidden
+	}
+	if in.Hidden {
+		return
+	}
+
+	mx, my := ebiten.CursorPosition()
+	fx, fy := float64(mx), float64(my)
+	c, v := g.inspectedValue()
+	t, isTable := v.(*engine.Table)
+	total, _ := g.inspectorRows(v)
+	bx, by, bw, bh, visible := g.inspectorBody(isTable)
+
+	// Drags continue outside the panel until the button is released
+	if in.resizing != "" {
+		if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			in.SetColumnWidth(in.resizing, in.resizeW+fx-in.resizeX)
+		} else {
+			in.resizing = ""
+		}
+		return
+	}
+	if in.draggingBar {
+		if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			in.Scroll = scrollAt(fy-by, bh, total, visible)
+		} else {
+			in.draggingBar = false
+		}
+		return
+	}
+	if !g.overInspector(mx, my) {
+		return
+	}
+
+	// Wheel scrolls rows; Shift or a horizontal wheel scrolls the table sideways
+	dx, dy := ebiten.Wheel()
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		dx, dy = dy, 0
+	}
+	in.Scroll = clampScroll(in.Scroll-int(dy*InspectorWheelRows), total, visible)
+	if isTable {
+		maxX := math.Max(g.columnEdges(t, bx)[len(t.Columns)]+in.ScrollX-bx-bw, 0)
+		in.ScrollX = math.Min(math.Max(in.ScrollX-dx*InspectorColumnWidth/2, 0), maxX)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyPageDown) {
+		in.Scroll = clampScroll(in.Scroll+visible, total, visible)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyPageUp) {
+		in.Scroll = clampScroll(in.Scroll-visible, total, visible)
+	}
+
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) || c == nil {
+		return
+	}
+
+	// Port tabs
+	if fy < by {
+		for i, p := range c.Outputs {
+			tx := bx + InspectorTitleWidth + float64(i)*InspectorTabWidth
+			if fx >= tx && fx < tx+InspectorTabWidth && p.Name != in.Port {
+				in.SelectPort(p.Name)
+			}
+		}
+		return
+	}
+
+	// Scrollbar
+	if fx >= bx+bw {
+		in.draggingBar = true
+		in.Scroll = scrollAt(fy-by, bh, total, visible)
+		return
+	}
+
+	if isTable {
+		if fy >= by+InspectorRowHeight {
+			return
+		}
+		// Header: a column border starts a resize, anywhere else sorts
+		edges := g.columnEdges(t, bx)
+		for i, name := range t.Columns {
+			if math.Abs(fx-edges[i+1]) <= InspectorResizeGrab {
+				in.resizing, in.resizeX, in.resizeW = name, fx, in.ColumnWidth(name)
+				return
+			}
+		}
+		for i, name := range t.Columns {
+			if fx >= edges[i] && fx < edges[i+1] {
+				in.ToggleSort(name)
+				return
+			}
+		}
+		return
+	}
+
+	// Tree: clicking a container opens or closes it
+	index := in.Scroll + int((fy-by)/InspectorRowHeight)
+	if lines, _ := treeWindow(v, in.Expanded, index, 1); len(lines) == 1 && lines[0].Expandable && lines[0].Depth > 0 {
+		in.ToggleExpanded(lines[0].Path)
+	}
+}
+
+// drawInspector draws the panel with the selected card's port tabs and data
+func (g *Game) drawInspector(screen *ebiten.Image) {
+	in := &g.inspector
+	if in.Hidden {
+		return
+	}
+	x, y, w, h := g.inspectorRect()
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(w), float32(h), ColorInspectorBackground, false)
+	vector.StrokeLine(screen, float32(x), float32(y), float32(x+w), float32(y), 1, ColorGridLine, false)
+
+	c, v := g.inspectedValue()
+	if c == nil {
+		DrawTextLines(screen, g.FontFace, "Click a card to inspect its output (F4 hides this panel)", int(x+CardPaddingX), int(y+CardPaddingY), ColorInspectorDim)
+		return
+	}
+
+	// Title and port tabs
+	title := truncateToWidth(g.FontFace, c.Title, InspectorTitleWidth-CardPaddingX)
+	DrawTextLines(screen, g.FontFace, title, int(x+CardPaddingX), int(y+4), color.White)
+	for i, p := range c.Outputs {
+		tx := x + InspectorTitleWidth + float64(i)*InspectorTabWidth
+		fill := ColorButtonBackground
+		if p.Name == in.Port {
+			fill = ColorGridHeader
+		}
+		vector.DrawFilledRect(screen, float32(tx), float32(y+2), float32(InspectorTabWidth-2), float32(InspectorTabHeight-4), fill, false)
+		DrawTextLines(screen, g.FontFace, truncateToWidth(g.FontFace, p.Name, InspectorTabWidth-8), int(tx+4), int(y+4), color.White)
+	}
+	if len(c.Outputs) == 0 {
+		DrawTextLines(screen, g.FontFace, "(no output ports)", int(x+InspectorTitleWidth), int(y+4), ColorInspectorDim)
+		return
+	}
+
+	total, isTable := g.inspectorRows(v)
+	bx, by, bw, bh, visible := g.inspectorBody(isTable)
+	in.Scroll = clampScroll(in.Scroll, total, visible)
+	switch {
+	case v == nil:
+		DrawTextLines(screen, g.FontFace, "(no value yet, press F5 to run)", int(bx+CardPaddingX), int(by+4), ColorInspectorDim)
+		return
+	case isTable:
+		g.drawInspectorTable(screen, v.(*engine.Table), bx, by, bw, visible)
+	default:
+		lines, _ := treeWindow(v, in.Expanded, in.Scroll, visible)
+		for i, l := range lines {
+			marker := "  "
+			if l.Expandable && l.Depth > 0 {
+				marker = "+ "
+				if l.Open {
+					marker = "- "
+				}
+			}
+			text := strings.Repeat("  ", l.Depth) + marker + l.Text
+			ly := by + float64(i)*InspectorRowHeight
+			DrawTextLines(screen, g.FontFace, truncateToWidth(g.FontFace, text, bw-CardPaddingX), int(bx+CardPaddingX), int(ly+3), color.White)
+		}
+	}
+
+	// Scrollbar
+	offset, length := scrollbarThumb(in.Scroll, total, visible, bh)
+	vector.DrawFilledRect(screen, float32(bx+bw), float32(by), InspectorScrollbarWidth, float32(bh), ColorGridCell, false)
+	vector.DrawFilledRect(screen, float32(bx+bw+2), float32(by+offset), InspectorScrollbarWidth-4, float32(length), ColorGridLine, false)
+	status := fmt.Sprintf("%d-%d of %d", min(in.Scroll+1, total), min(in.Scroll+visible, total), total)
+	DrawTextLines(screen, g.FontFace, status, int(bx+bw-InspectorTitleWidth), int(y+4), ColorInspectorDim)
+}
+
+// drawInspectorTable draws only the rows in view, so the cost does not grow with the table
+func (g *Game) drawInspectorTable(screen *ebiten.Image, t *engine.Table, bx, by, bw float64, visible int) {
+	in := &g.inspector
+	order := in.viewOrder(t)
+	edges := g.columnEdges(t, bx)
+	right := bx + bw
+
+	// Header with sort markers
+	vector.DrawFilledRect(screen, float32(bx), float32(by), float32(bw), InspectorRowHeight, ColorGridHeader, false)
+	for i, name := range t.Columns {
+		if edges[i+1] <= bx+InspectorGutterWidth || edges[i] >= right {
+			continue
+		}
+		label := name
+		if name == in.SortColumn && in.SortDesc {
+			label += " v"
+		} else if name == in.SortColumn {
+			label += " ^"
+		}
+		g.drawInspectorCell(screen, label, edges[i], edges[i+1], bx+InspectorGutterWidth, right, by, color.White)
+	}
+
+	// Rows; the gutter shows each row's position in the original table
+	for i := 0; i < visible; i++ {
+		view := in.Scroll + i
+		if view >= t.NumRows() {
+			break
+		}
+		row := view
+		if order != nil {
+			row = order[view]
+		}
+		ry := by + float64(i+1)*InspectorRowHeight
+		DrawTextLines(screen, g.FontFace, fmt.Sprint(row+1), int(bx+CardPaddingX), int(ry+3), ColorInspectorDim)
+		for col := range t.Columns {
+			if edges[col+1] <= bx+InspectorGutterWidth || edges[col] >= right {
+				continue
+			}
+			g.drawInspectorCell(screen, previewValue(t.Value(row, col)), edges[col], edges[col+1], bx+InspectorGutterWidth, right, ry, color.White)
+		}
+	}
+
+	// Column borders double as resize handles
+	for _, e := range edges[1:] {
+		if e > bx+InspectorGutterWidth && e < right {
+			vector.StrokeLine(screen, float32(e), float32(by), float32(e), float32(by+float64(visible+1)*InspectorRowHeight), 1, ColorGridLine, false)
+		}
+	}
+}
+
+// drawInspectorCell draws text clipped to a column and to the visible table area
+func (g *Game) drawInspectorCell(screen *ebiten.Image, s string, left, right, minX, maxX, y float64, clr color.Color) {
+	x := math.Max(left, minX)
+	width := math.Min(right, maxX) - x - 6
+	if width <= 0 {
+		return
+	}
+	DrawTextLines(screen, g.FontFace, truncateToWidth(g.FontFace, s, width), int(x+3), int(y+3), clr)
+}
+
+// SelectCard shows the clicked card in the inspector
+func (g *Game) SelectCard(card interface{}) {
+	if c, ok := card.(*Card); ok {
+		// Clicking the card again ends a port rename that was never typed into
+		c.portEdit = 0
+		g.inspector.Select(c)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"card-flows/engine"
+)
+
+func TestInspectorSortedOrder(t *testing.T) {
+	table := engine.NewTable("v")
+	for _, v := range []interface{}{"b", 10, nil, 2.5, "A", "", 2.5} {
+		table.AppendRow(v)
+	}
+
+	if got, want := sortedOrder(table, 0, false), []int{3, 6, 1, 4, 0, 2, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected ascending order %v, got %v", want, got)
+	}
+	// Descending keeps ties stable and blanks last
+	if got, want := sortedOrder(table, 0, true), []int{0, 4, 1, 3, 6, 2, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected descending order %v, got %v", want, got)
+	}
+}
+
+func TestInspectorViewOrderCache(t *testing.T) {
+	table := engine.NewTable("n")
+	for i := 0; i < 1000000; i++ {
+		table.AppendRow(i % 7)
+	}
+	in := &Inspector{}
+	if in.viewOrder(table) != nil {
+		t.Fatalf("Expected the original order without a sort column")
+	}
+
+	in.ToggleSort("n")
+	in.ToggleSort("n")
+	order := in.viewOrder(table)
+	if len(order) != 1000000 || table.Value(order[0], 0) != 6 || table.Value(order[len(order)-1], 0) != 0 {
+		t.Fatalf("Expected a descending view of all rows")
+	}
+	if again := in.viewOrder(table); &again[0] != &order[0] {
+		t.Errorf("Expected the sorted index to be reused")
+	}
+	// The data itself is never reordered
+	if table.Value(0, 0) != 0 {
+		t.Errorf("Expected the table to keep its row order")
+	}
+
+	in.ToggleSort("n")
+	if in.SortColumn != "" || in.viewOrder(table) != nil {
+		t.Errorf("Expected a third click to clear the sort, got %q", in.SortColumn)
+	}
+}
+
+func TestInspectorScrolling(t *testing.T) {
+	tests := []struct {
+		scroll, total, visible, want int
+	}{
+		{-5, 100, 10, 0},
+		{50, 100, 10, 50},
+		{95, 100, 10, 90},
+		{3, 5, 10, 0},
+	}
+	for _, tt := range tests {
+		if got := clampScroll(tt.scroll, tt.total, tt.visible); got != tt.want {
+			t.Errorf("clampScroll(%d, %d, %d) = %d, want %d", tt.scroll, tt.total, tt.visible, got, tt.want)
+		}
+	}
+
+	// A million rows still leave a thumb that can be grabbed, and its ends map to the ends
+	offset, length := scrollbarThumb(0, 1000000, 10, 200)
+	if offset != 0 || length != InspectorMinThumb {
+		t.Errorf("Expected a minimum size thumb at the top, got %v, %v", offset, length)
+	}
+	if offset, _ := scrollbarThumb(1000000-10, 1000000, 10, 200); offset != 200-InspectorMinThumb {
+		t.Errorf("Expected the thumb at the bottom, got %v", offset)
+	}
+	if got := scrollAt(200, 200, 1000000, 10); got != 1000000-10 {
+		t.Errorf("Expected the track end to scroll to the last rows, got %d", got)
+	}
+}
+
+func TestInspectorColumnWidth(t *testing.T) {
+	in := &Inspector{}
+	if in.ColumnWidth("a") != InspectorColumnWidth {
+		t.Errorf("Expected the default width, got %v", in.ColumnWidth("a"))
+	}
+	in.SetColumnWidth("a", 180)
+	in.SetColumnWidth("b", -20)
+	if in.ColumnWidth("a") != 180 || in.ColumnWidth("b") != InspectorMinColumnWidth {
+		t.Errorf("Unexpected widths %v", in.Widths)
+	}
+}
+
+func TestInspectorTreeWindow(t *testing.T) {
+	value := map[string]interface{}{
+		"count": 2,
+		"items": []interface{}{"a", map[string]interface{}{"x": 1}},
+	}
+	texts := func(lines []treeLine) []string {
+		var out []string
+		for _, l := range lines {
+			out = append(out, l.Text)
+		}
+		return out
+	}
+
+	lines, total := treeWindow(value, nil, 0, 10)
+	if want := []string{"{2 keys}", "count: 2", "items: [2 items]"}; !reflect.DeepEqual(texts(lines), want) || total != 3 {
+		t.Errorf("Expected collapsed children %v, got %v (%d lines)", want, texts(lines), total)
+	}
+
+	in := &Inspector{}
+	in.ToggleExpanded("$.items")
+	in.ToggleExpanded("$.items[1]")
+	lines, total = treeWindow(value, in.Expanded, 3, 2)
+	if want := []string{"[0] a", "[1] {1 keys}"}; !reflect.DeepEqual(texts(lines), want) || total != 6 {
+		t.Errorf("Expected window %v, got %v (%d lines)", want, texts(lines), total)
+	}
+	if lines[1].Depth != 2 || lines[1].Path != "$.items[1]" || !lines[1].Open {
+		t.Errorf("Unexpected nested line %+v", lines[1])
+	}
+
+	long := make([]interface{}, 1000000)
+	lines, total = treeWindow(long, nil, 500000, 3)
+	if total != 1000001 || len(lines) != 3 || lines[0].Text != "[499999] " {
+		t.Errorf("Expected a window into the long list, got %v (%d lines)", texts(lines), total)
+	}
+
+	if lines, total := treeWindow("one\ntwo", nil, 0, 10); total != 2 || lines[1].Text != "two" {
+		t.Errorf("Expected text split into lines, got %v", texts(lines))
+	}
+}
+
+func TestInspectorSelection(t *testing.T) {
+	g, sc := newTableFlow(func(g *Game) *Card { return g.AddSortCard(100, 400) })
+	g.engine.Run()
+
+	g.SelectCard(sc)
+	g.inspector.Scroll = 3
+	g.inspector.ToggleSort("rep")
+	c, v := g.inspectedValue()
+	if c != sc || g.inspector.Port != "result" {
+		t.Fatalf("Expected the sort card's result port, got %q", g.inspector.Port)
+	}
+	if table, ok := v.(*engine.Table); !ok || table.NumRows() != 5 {
+		t.Fatalf("Expected the result table, got %v", v)
+	}
+
+	// Reselecting keeps the view; another card starts fresh
+	g.SelectCard(sc)
+	if g.inspector.Scroll != 3 || g.inspector.SortColumn != "rep" {
+		t.Errorf("Expected the view to be kept, got %+v", g.inspector)
+	}
+	grid := g.cards[0]
+	g.SelectCard(grid)
+	if g.inspector.CardID != grid.ID || g.inspector.Port != "table" || g.inspector.Scroll != 0 || g.inspector.SortColumn != "" {
+		t.Errorf("Expected a fresh view of the grid, got %+v", g.inspector)
+	}
+}
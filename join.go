@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"card-flows/engine"
+)
+
+func (g *Game) AddJoinCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "join",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth,
+		Height: DefaultCardHeight * 1.5,
+		Color:  ColorCardDefault,
+		Title:  "Table:join",
+		Inputs: []Port{
+			{Name: "left", Type: "table"},
+			{Name: "right", Type: "table"},
+		},
+		Outputs: []Port{
+			{Name: "table", Type: "table"},
+			{Name: "stats", Type: "map"},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// executeJoin joins the tables on the card's left and right inputs.
+// The "keys" param is a comma-separated list of columns or left=right pairs;
+// when empty the tables are joined on their common columns.
+func executeJoin(c *Card, inputs map[string]interface{}) (map[string]interface{}, error) {
+	left, err := tableInput(inputs, "left")
+	if err != nil {
+		return nil, err
+	}
+	right, err := tableInput(inputs, "right")
+	if err != nil {
+		return nil, err
+	}
+
+	opts := engine.JoinOptions{
+		Mode:        c.ParamText("mode"),
+		LeftSuffix:  c.ParamText("left_suffix"),
+		RightSuffix: c.ParamText("right_suffix"),
+	}
+	opts.LeftKeys, opts.RightKeys = engine.ParseJoinKeys(c.ParamText("keys"), left, right)
+
+	table, stats, err := engine.Join(left, right, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.Text = fmt.Sprintf("%d rows\n%d matched, %d+%d unmatched", stats.Rows, stats.Matched, stats.LeftUnmatched, stats.RightUnmatched)
+	return map[string]interface{}{
+		"table": table,
+		"stats": stats.Map(),
+	}, nil
+}
+
+// tableInput returns the table wired into an input port
+func tableInput(inputs map[string]interface{}, port string) (*engine.Table, error) {
+	v, ok := inputs[port]
+	if !ok || v == nil {
+		return nil, fmt.Errorf("the %s input is not connected", port)
+	}
+	t, ok := v.(*engine.Table)
+	if !ok {
+		return nil, fmt.Errorf("the %s input must be a table, got %T", port, v)
+	}
+	return t, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"card-flows/engine"
+)
+
+// newJoinFlow wires two grid cards into a join card
+func newJoinFlow(left, right [][]string, params map[string]interface{}) (*Game, *Card) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+
+	lg := g.AddGridCard(100, 100)
+	lg.Cells = left
+	rg := g.AddGridCard(400, 100)
+	rg.Cells = right
+	jc := g.AddJoinCard(250, 400)
+	for k, v := range params {
+		jc.Params[k] = v
+	}
+	wireCards(g, lg, "table", jc, "left")
+	wireCards(g, rg, "table", jc, "right")
+	return g, jc
+}
+
+var (
+	joinPeople = [][]string{
+		{"id", "name"},
+		{"1", "Ann"},
+		{"2", "Bob"},
+		{"3", "Cy"},
+	}
+	joinOrders = [][]string{
+		{"id", "name", "total"},
+		{"1", "book", "10"},
+		{"1", "pen", "2"},
+		{"4", "lamp", "30"},
+	}
+)
+
+func TestJoinModes(t *testing.T) {
+	tests := []struct {
+		mode  string
+		rows  [][]interface{}
+		stats map[string]interface{}
+	}{
+		{
+			mode: "inner",
+			rows: [][]interface{}{{1, "Ann", "book", 10}, {1, "Ann", "pen", 2}},
+		},
+		{
+			mode: "left",
+			rows: [][]interface{}{{1, "Ann", "book", 10}, {1, "Ann", "pen", 2}, {2, "Bob", nil, nil}, {3, "Cy", nil, nil}},
+		},
+		{
+			mode: "right",
+			rows: [][]interface{}{{1, "Ann", "book", 10}, {1, "Ann", "pen", 2}, {4, nil, "lamp", 30}},
+		},
+		{
+			mode: "outer",
+			rows: [][]interface{}{{1, "Ann", "book", 10}, {1, "Ann", "pen", 2}, {2, "Bob", nil, nil}, {3, "Cy", nil, nil}, {4, nil, "lamp", 30}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			g, jc := newJoinFlow(joinPeople, joinOrders, map[string]interface{}{"keys": "id", "mode": tt.mode})
+			g.engine.Run()
+			if jc.LastError != "" {
+				t.Fatalf("Unexpected error: %s", jc.LastError)
+			}
+
+			table := g.engine.Memory[jc.ID+":table"].(*engine.Table)
+			if want := []string{"id", "name_left", "name_right", "total"}; !reflect.DeepEqual(table.Columns, want) {
+				t.Errorf("Expected columns %v, got %v", want, table.Columns)
+			}
+			var rows [][]interface{}
+			for i := 0; i < table.NumRows(); i++ {
+				rows = append(rows, table.Row(i))
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("Expected rows %v, got %v", tt.rows, rows)
+			}
+
+			stats := g.engine.Memory[jc.ID+":stats"].(map[string]interface{})
+			if stats["matched"] != 2 || stats["left_unmatched"] != 2 || stats["right_unmatched"] != 1 {
+				t.Errorf("Unexpected stats %v", stats)
+			}
+			if stats["rows"] != len(tt.rows) {
+				t.Errorf("Expected %d rows in stats, got %v", len(tt.rows), stats["rows"])
+			}
+		})
+	}
+}
+
+func TestJoinKeyPairsAndAutoKeys(t *testing.T) {
+	left := [][]string{{"user", "city"}, {"7", "Oslo"}}
+	right := [][]string{{"user_id", "city"}, {"7.0", "Bergen"}}
+
+	g, jc := newJoinFlow(left, right, map[string]interface{}{"keys": "user=user_id", "left_suffix": "_home", "right_suffix": "_work"})
+	g.engine.Run()
+	table := g.engine.Memory[jc.ID+":table"].(*engine.Table)
+	if want := []string{"user", "city_home", "city_work"}; !reflect.DeepEqual(table.Columns, want) {
+		t.Errorf("Expected columns %v, got %v", want, table.Columns)
+	}
+	if table.NumRows() != 1 {
+		t.Errorf("Expected 7 and 7.0 to match, got %d rows", table.NumRows())
+	}
+
+	// Without keys the tables are joined on their common columns
+	g, jc = newJoinFlow(left, right, nil)
+	g.engine.Run()
+	table = g.engine.Memory[jc.ID+":table"].(*engine.Table)
+	if want := []string{"user", "city", "user_id"}; !reflect.DeepEqual(table.Columns, want) {
+		t.Errorf("Expected columns %v, got %v", want, table.Columns)
+	}
+	if table.NumRows() != 0 {
+		t.Errorf("Expected no matches on city, got %d rows", table.NumRows())
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	tests := []struct {
+		params map[string]interface{}
+		want   string
+	}{
+		{map[string]interface{}{"keys": "missing"}, `join key "missing" not found in the left table`},
+		{map[string]interface{}{"keys": "id", "mode": "cross"}, `unknown join mode "cross"`},
+	}
+	for _, tt := range tests {
+		g, jc := newJoinFlow(joinPeople, joinOrders, tt.params)
+		g.engine.Run()
+		if !strings.Contains(jc.LastError, tt.want) {
+			t.Errorf("Expected error containing %q, got %q", tt.want, jc.LastError)
+		}
+	}
+}
+
+func TestJoinSettings(t *testing.T) {
+	g, jc := newJoinFlow(joinPeople, joinOrders, nil)
+	jc.toggleSettings()
+	fields := jc.formFields(g)
+	if len(fields) != 5 || fields[0].Label != "Keys" || !fields[0].Edit || fields[1].Value != "inner" {
+		t.Fatalf("Expected the join settings, got %+v", fields)
+	}
+	fields[0].Set("id")
+	fields[1].Set(nextOption(fields[1].Value, fields[1].Options))
+	g.engine.Run()
+	if jc.LastError != "" {
+		t.Fatal(jc.LastError)
+	}
+	if table := g.engine.Memory[jc.ID+":table"].(*engine.Table); table.NumRows() != 4 {
+		t.Errorf("Expected a left join keeping every person, got %d rows", table.NumRows())
+	}
+}
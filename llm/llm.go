@@ -0,0 +1,126 @@
+// Package llm sends prompts to language models. Providers share one interface so
+// the LLM card can use an OpenAI-compatible server or the local mock.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Request is one prompt: the system instructions and the user's input
+type Request struct {
+	Model       string
+	System      string
+	Prompt      string
+	Temperature float64
+}
+
+// Response is the model's answer with the tokens the provider counted
+type Response struct {
+	Text             string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Provider answers prompts
+type Provider interface {
+	Complete(ctx context.Context, req Request) (Response, error)
+}
+
+// OpenAI is a provider speaking the chat completions API of OpenAI, which many
+// other services and local model servers also offer
+type OpenAI struct {
+	BaseURL string // e.g. https://api.openai.com/v1
+	APIKey  string
+	Client  *http.Client
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Complete posts the prompt to the chat completions endpoint
+func (p *OpenAI) Complete(ctx context.Context, req Request) (Response, error) {
+	body, err := json.Marshal(chatRequestOf(req))
+	if err != nil {
+		return Response{}, err
+	}
+	url := strings.TrimSuffix(p.BaseURL, "/") + "/chat/completions"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, err
+	}
+
+	var out chatResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return Response{}, fmt.Errorf("%s", resp.Status)
+		}
+		return Response{}, fmt.Errorf("unexpected response: %v", err)
+	}
+	if out.Error != nil {
+		return Response{}, fmt.Errorf("%s: %s", resp.Status, out.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Response{}, fmt.Errorf("%s", resp.Status)
+	}
+	if len(out.Choices) == 0 {
+		return Response{}, fmt.Errorf("the response has no answer")
+	}
+	return Response{
+		Text:             out.Choices[0].Message.Content,
+		PromptTokens:     out.Usage.PromptTokens,
+		CompletionTokens: out.Usage.CompletionTokens,
+	}, nil
+}
+
+func chatRequestOf(req Request) chatRequest {
+	var messages []chatMessage
+	if req.System != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: req.System})
+	}
+	messages = append(messages, chatMessage{Role: "user", Content: req.Prompt})
+	return chatRequest{Model: req.Model, Messages: messages, Temperature: req.Temperature}
+}
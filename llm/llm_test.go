@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIAgainstMockServer(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		MockHandler().ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	p := &OpenAI{BaseURL: server.URL + "/v1/", APIKey: "sk-test"}
+	resp, err := p.Complete(context.Background(), Request{
+		Model:  "test-model",
+		System: "Summarize the input.",
+		Prompt: "Sales grew in May. Costs fell.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "mock: Sales grew in May." {
+		t.Errorf("Unexpected reply %q", resp.Text)
+	}
+	if resp.PromptTokens != 9 || resp.CompletionTokens != 5 {
+		t.Errorf("Unexpected token counts %d, %d", resp.PromptTokens, resp.CompletionTokens)
+	}
+	if auth != "Bearer sk-test" {
+		t.Errorf("Expected the API key to be sent, got %q", auth)
+	}
+
+	// Errors from the service are reported with their message
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"invalid API key"}}`))
+	}))
+	defer denied.Close()
+	p.BaseURL = denied.URL
+	if _, err := p.Complete(context.Background(), Request{Prompt: "x"}); err == nil || err.Error() != "401 Unauthorized: invalid API key" {
+		t.Errorf("Expected the service's error, got %v", err)
+	}
+}
+
+func TestMockIsDeterministic(t *testing.T) {
+	req := Request{Prompt: "  What is this?  It is a test."}
+	a, _ := Mock{}.Complete(context.Background(), req)
+	b, _ := Mock{}.Complete(context.Background(), req)
+	if a != b || a.Text != "mock: What is this?" {
+		t.Errorf("Expected the same reply twice, got %+v and %+v", a, b)
+	}
+}
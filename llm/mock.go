@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Mock is a provider answering without a model: the reply repeats the first
+// sentence of the prompt, so the same prompt always gets the same answer.
+// Tokens are counted as words.
+type Mock struct{}
+
+// Complete returns the deterministic reply to the prompt
+func (Mock) Complete(ctx context.Context, req Request) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+	text := "mock: " + firstSentence(req.Prompt)
+	return Response{
+		Text:             text,
+		PromptTokens:     len(strings.Fields(req.System)) + len(strings.Fields(req.Prompt)),
+		CompletionTokens: len(strings.Fields(text)),
+	}, nil
+}
+
+// firstSentence returns the text up to the first full stop, question or
+// exclamation mark or line break
+func firstSentence(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, ".?!\n"); i >= 0 {
+		return strings.TrimSpace(s[:i+1])
+	}
+	return s
+}
+
+// MockHandler serves the Mock provider as an OpenAI-compatible chat completions
+// endpoint, for testing the HTTP provider and flows without a real service
+func MockHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":{"message":"no endpoint %s %s"}}`, r.Method, r.URL.Path)
+			return
+		}
+		var in chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"message": err.Error()}})
+			return
+		}
+		req := Request{Model: in.Model, Temperature: in.Temperature}
+		for _, m := range in.Messages {
+			switch m.Role {
+			case "system":
+				req.System = m.Content
+			case "user":
+				req.Prompt = m.Content
+			}
+		}
+		resp, _ := Mock{}.Complete(r.Context(), req)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{
+				"message": chatMessage{Role: "assistant", Content: resp.Text},
+			}},
+			"usage": map[string]int{
+				"prompt_tokens":     resp.PromptTokens,
+				"completion_tokens": resp.CompletionTokens,
+			},
+		})
+	})
+}
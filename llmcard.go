@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"card-flows/engine"
+	"card-flows/llm"
+)
+
+// llmTasks are the instructions of the LLM card's tasks. Details typed into the
+// card, such as the categories or the fields to extract, replace %s.
+var llmTasks = map[string]string{
+	"summarize":  "Summarize the input in a few sentences. %s",
+	"categorize": "Classify the input into exactly one of these categories: %s. Answer with the category only.",
+	"extract":    "Extract these fields from the input: %s. Answer with a JSON object only.",
+	"custom":     "%s",
+}
+
+var llmTaskNames = []string{"summarize", "categorize", "extract", "custom"}
+
+var llmTones = []string{"neutral", "formal", "friendly", "concise"}
+
+// AddLLMCard adds a card asking a language model to summarize, categorize or
+// extract from its input. The model and provider are in the card's settings.
+func (g *Game) AddLLMCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "llm",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth * 1.5,
+		Height: DefaultCardHeight * 1.5,
+		Color:  ColorCardDefault,
+		Title:  "AI:llm",
+		Inputs: []Port{
+			{Name: "input", Type: "any"},
+		},
+		Outputs: []Port{
+			{Name: "text", Type: "string"},
+			{Name: "data", Type: "any"},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// llmRequest builds the prompt of an LLM card. Tables are sent as CSV.
+func llmRequest(params map[string]interface{}, input interface{}) (llm.Request, error) {
+	task := fmt.Sprint(params["task"])
+	instructions, ok := llmTasks[task]
+	if !ok {
+		return llm.Request{}, fmt.Errorf("unknown task %q", task)
+	}
+	details := strings.TrimSpace(fmt.Sprint(params["instructions"]))
+	if details == "" && task != "summarize" {
+		return llm.Request{}, fmt.Errorf("the %s task needs instructions", task)
+	}
+	system := strings.TrimSpace(fmt.Sprintf(instructions, details))
+	if tone := fmt.Sprint(params["tone"]); tone != "neutral" {
+		system += fmt.Sprintf(" Write in a %s tone.", tone)
+	}
+
+	var prompt string
+	switch v := input.(type) {
+	case nil:
+		return llm.Request{}, fmt.Errorf("the input is not connected")
+	case string:
+		prompt = v
+	case *engine.Table, []interface{}:
+		csv, err := engine.CSV(v)
+		if err != nil {
+			return llm.Request{}, err
+		}
+		prompt = csv
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return llm.Request{}, err
+		}
+		prompt = string(data)
+	}
+
+	temperature, _ := params["temperature"].(float64)
+	if n, ok := params["temperature"].(int); ok {
+		temperature = float64(n)
+	}
+	return llm.Request{
+		Model:       fmt.Sprint(params["model"]),
+		System:      system,
+		Prompt:      prompt,
+		Temperature: temperature,
+	}, nil
+}
+
+// llmProvider returns the provider chosen in the card's settings
+func llmProvider(params map[string]interface{}) llm.Provider {
+	if params["provider"] == "mock" {
+		return llm.Mock{}
+	}
+	return &llm.OpenAI{
+		BaseURL: fmt.Sprint(params["base_url"]),
+		APIKey:  fmt.Sprint(params["api_key"]),
+	}
+}
+
+// llmCall is a request to a model running in the background, so that the
+// canvas stays responsive while the model answers
+type llmCall struct {
+	key     string // hash of the request and provider it was made with
+	cancel  context.CancelFunc
+	done    chan struct{} // closed once resp or err is set
+	resp    llm.Response
+	err     error
+	latency time.Duration
+}
+
+// llmAnswered wakes the canvas to rerun the flow when a model has answered
+var llmAnswered = make(chan struct{}, 1)
+
+// askLLM returns the finished request for the key, or starts it in the background
+// and returns errPending. A request for another input or other settings cancels
+// the one still running.
+func (c *Card) askLLM(p llm.Provider, req llm.Request, key string) (*llmCall, error) {
+	if call := c.llmCall; call != nil && call.key == key {
+		select {
+		case <-call.done:
+			c.llmCall = nil
+			return call, nil
+		default:
+			return nil, errPending
+		}
+	}
+	c.cancelLLM()
+	ctx, cancel := context.WithTimeout(context.Background(), LLMTimeoutSeconds*time.Second)
+	call := &llmCall{key: key, cancel: cancel, done: make(chan struct{})}
+	c.llmCall = call
+	go func() {
+		defer cancel()
+		start := time.Now()
+		call.resp, call.err = p.Complete(ctx, req)
+		call.latency = time.Since(start)
+		close(call.done)
+		select {
+		case llmAnswered <- struct{}{}:
+		default:
+		}
+	}()
+	return nil, errPending
+}
+
+// cancelLLM stops the card's request to the model if one is running
+func (c *Card) cancelLLM() {
+	if c.llmCall != nil {
+		c.llmCall.cancel()
+		c.llmCall = nil
+	}
+}
+
+// llmPending reports whether the card is waiting for the model
+func (c *Card) llmPending() bool {
+	if c.llmCall == nil {
+		return false
+	}
+	select {
+	case <-c.llmCall.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// updateLLMCalls reruns the flow when a model has answered in the background
+func (g *Game) updateLLMCalls() {
+	select {
+	case <-llmAnswered:
+		g.RunEngine()
+	default:
+	}
+}
+
+// executeLLM asks the model. Like every card the answer is cached, so the model
+// is only asked again when the input or the settings change. Extracted JSON is
+// also output as data. On the canvas the model answers in the background and
+// the flow reruns with the answer; inner flows wait for it.
+func (e *Engine) executeLLM(c *Card, inputs map[string]interface{}) (map[string]interface{}, error) {
+	params, err := c.ScriptParams()
+	if err != nil {
+		return nil, err
+	}
+	req, err := llmRequest(params, inputs["input"])
+	if err != nil {
+		return nil, err
+	}
+	// Local model servers usually need no key, OpenAI always does
+	if params["provider"] == "openai" && params["api_key"] == "" && strings.Contains(fmt.Sprint(params["base_url"]), "api.openai.com") {
+		return nil, fmt.Errorf("name the secret holding your API key in the card's settings")
+	}
+
+	var resp llm.Response
+	var latency time.Duration
+	if e.background {
+		key := engine.ComputeInputHash(c.ID, map[string]interface{}{
+			"request":  req,
+			"provider": params["provider"],
+			"base_url": params["base_url"],
+			"api_key":  params["api_key"],
+		})
+		call, pending := c.askLLM(llmProvider(params), req, key)
+		if pending != nil {
+			c.status = fmt.Sprintf("Waiting for %s...", req.Model)
+			return nil, pending
+		}
+		resp, err, latency = call.resp, call.err, call.latency
+		if errors.Is(err, context.Canceled) {
+			err = fmt.Errorf("the request was cancelled")
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), LLMTimeoutSeconds*time.Second)
+		defer cancel()
+		start := time.Now()
+		resp, err = llmProvider(params).Complete(ctx, req)
+		latency = time.Since(start)
+	}
+	if err != nil {
+		c.status = ""
+		return nil, err
+	}
+	tokens := resp.PromptTokens + resp.CompletionTokens
+	c.status = fmt.Sprintf("%d tokens in %.1fs", tokens, latency.Seconds())
+	e.logRun(c, fmt.Sprintf("%s answered with %d prompt and %d completion tokens in %s",
+		req.Model, resp.PromptTokens, resp.CompletionTokens, latency.Round(time.Millisecond)), map[string]interface{}{
+		"provider":          params["provider"],
+		"model":             req.Model,
+		"prompt_tokens":     resp.PromptTokens,
+		"completion_tokens": resp.CompletionTokens,
+		"latency_ms":        latency.Milliseconds(),
+	})
+
+	outputs := map[string]interface{}{"text": resp.Text, "data": nil}
+	if params["task"] == "extract" {
+		// Models often wrap JSON in a markdown code block
+		var data interface{}
+		text := strings.Trim(strings.TrimPrefix(strings.TrimSpace(resp.Text), "```json"), "`")
+		if json.Unmarshal([]byte(text), &data) == nil {
+			outputs["data"] = data
+		}
+	}
+	return outputs, nil
+}
+
+func (c *Card) llmFields(g *Game) []formField {
+	set := func(name string) func(string) {
+		return func(v string) { c.setParams(map[string]interface{}{name: v}) }
+	}
+	label := map[string]string{
+		"summarize":  "Focus",
+		"categorize": "Categories",
+		"extract":    "Fields",
+		"custom":     "Instructions",
+	}[fmt.Sprint(c.Param("task"))]
+	fields := []formField{
+		{Label: "Task", Value: fmt.Sprint(c.Param("task")), Options: llmTaskNames, Set: set("task")},
+		{Label: "Tone", Value: fmt.Sprint(c.Param("tone")), Options: llmTones, Set: set("tone")},
+		{Label: label, Value: fmt.Sprint(c.Param("instructions")), Edit: true, Set: set("instructions")},
+	}
+	if c.llmPending() {
+		fields = append(fields, formField{Label: "Cancel request", Action: "llm_cancel"})
+	}
+	return fields
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"card-flows/engine"
+	"card-flows/llm"
+)
+
+// runAndAnswer runs the flow, waits for the model asked in the background and
+// reruns the flow with its answer, as the canvas does
+func runAndAnswer(t *testing.T, g *Game) {
+	t.Helper()
+	g.engine.Run()
+	select {
+	case <-llmAnswered:
+		g.engine.Run()
+	case <-time.After(5 * time.Second):
+		t.Fatal("The model did not answer")
+	}
+}
+
+func TestLLMCard(t *testing.T) {
+	useSecrets(t)
+	t.Setenv("CARD_FLOWS_SECRET_MOCK_KEY", "sk-mock-0001")
+	var calls int
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		auth = r.Header.Get("Authorization")
+		llm.MockHandler().ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	input := g.AddTextCard(100, 100)
+	input.Text = "Sales grew in May. Costs fell."
+	card := g.AddLLMCard(100, 300)
+	card.setParams(map[string]interface{}{"base_url": server.URL + "/v1", "api_key": "mock_key", "model": "test-model"})
+	g.Connect(input, "text", card, "input")
+
+	// The model answers in the background; until then the card waits
+	g.engine.Run()
+	if !g.engine.IsSkipped(card) || card.status != "Waiting for test-model..." {
+		t.Errorf("Expected the card to wait for the answer, got status %q", card.status)
+	}
+	select {
+	case <-llmAnswered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The model did not answer")
+	}
+	g.engine.Run()
+	if card.LastError != "" {
+		t.Fatal(card.LastError)
+	}
+	if got := g.engine.Memory[card.ID+":text"]; got != "mock: Sales grew in May." {
+		t.Errorf("Unexpected answer %v", got)
+	}
+	if auth != "Bearer sk-mock-0001" {
+		t.Errorf("Expected the secret to be sent as the API key, got %q", auth)
+	}
+	last := g.engine.RunLog[len(g.engine.RunLog)-1]
+	if last.Data["model"] != "test-model" || last.Data["prompt_tokens"] != 13 || last.Data["completion_tokens"] != 5 {
+		t.Errorf("Expected token stats in the run log, got %v", last.Data)
+	}
+	if _, ok := last.Data["latency_ms"]; !ok || !strings.HasPrefix(card.status, "18 tokens in ") {
+		t.Errorf("Expected the latency to be logged, got %v and status %q", last.Data, card.status)
+	}
+
+	// Unchanged input and settings reuse the cached answer
+	g.engine.Run()
+	if calls != 1 {
+		t.Errorf("Expected one request, got %d", calls)
+	}
+	card.setParams(map[string]interface{}{"tone": "formal"})
+	runAndAnswer(t, g)
+	if calls != 2 {
+		t.Errorf("Expected a new request after the tone changed, got %d", calls)
+	}
+}
+
+func TestLLMCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	input := g.AddTextCard(100, 100)
+	input.Text = "Never answered."
+	card := g.AddLLMCard(100, 300)
+	card.setParams(map[string]interface{}{"base_url": server.URL})
+	g.Connect(input, "text", card, "input")
+
+	g.engine.Run()
+	fields := card.llmFields(g)
+	if last := fields[len(fields)-1]; last.Action != "llm_cancel" {
+		t.Fatalf("Expected a cancel row while waiting, got %+v", last)
+	}
+	g.PerformCardAction(card, "llm_cancel", 0, 0)
+	runAndAnswer(t, g)
+	if card.LastError != "the request was cancelled" || card.llmPending() {
+		t.Errorf("Expected the request to be cancelled, got %q", card.LastError)
+	}
+}
+
+func TestLLMRequest(t *testing.T) {
+	g := NewGame()
+	g.engine.background = false // answer at once, as in inner flows
+	card := g.AddLLMCard(0, 0)
+	params := card.ResolvedParams()
+	params["task"] = "categorize"
+	if _, err := llmRequest(params, "text"); err == nil {
+		t.Error("Expected categorize without categories to fail")
+	}
+	params["instructions"] = "bug, feature"
+	params["tone"] = "concise"
+	table := engine.NewTable("id", "title")
+	table.AppendRow(1, "Crash on save")
+	req, err := llmRequest(params, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Classify the input into exactly one of these categories: bug, feature. Answer with the category only. Write in a concise tone."
+	if req.System != want || req.Prompt != "id,title\n1,Crash on save\n" || req.Temperature != 0.2 {
+		t.Errorf("Unexpected request %+v", req)
+	}
+
+	// The mock provider needs no key
+	card.setParams(map[string]interface{}{"provider": "mock", "task": "extract", "instructions": "name"})
+	out, err := g.engine.executeLLM(card, map[string]interface{}{"input": "Ada wrote the first program."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["text"] != "mock: Ada wrote the first program." || out["data"] != nil {
+		t.Errorf("Unexpected outputs %v", out)
+	}
+
+	// Extracted JSON, even in a code block, is also output as data
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		answer := "```json\n{\"name\": \"Ada\"}\n```"
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]string{"role": "assistant", "content": answer}}},
+		})
+	}))
+	defer server.Close()
+	card.setParams(map[string]interface{}{"provider": "openai", "base_url": server.URL})
+	out, err = g.engine.executeLLM(card, map[string]interface{}{"input": "Ada wrote the first program."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out["data"], map[string]interface{}{"name": "Ada"}) {
+		t.Errorf("Expected the extracted fields as data, got %v", out["data"])
+	}
+}
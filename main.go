@@ -1,17 +1,54 @@
 package main
 
 import (
+	"bufio"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"card-flows/llm"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
 func main() {
+	profile := flag.String("profile", "", "flow variable profile to run with, e.g. dev or prod")
+	setSecret := flag.String("set-secret", "", "store the secret of this name, read from standard input, and exit")
+	mockLLM := flag.String("mock-llm", "", "serve the mock language model at this address, e.g. :8089, instead of the canvas")
+	flag.Parse()
+
+	if *mockLLM != "" {
+		log.Printf("Mock language model at http://%s/v1", *mockLLM)
+		log.Fatal(http.ListenAndServe(*mockLLM, llm.MockHandler()))
+	}
+
+	if *setSecret != "" {
+		fmt.Fprintf(os.Stderr, "Value of %s: ", *setSecret)
+		value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && value == "" {
+			log.Fatal(err)
+		}
+		if err := secretStore.Set(*setSecret, strings.TrimRight(value, "\r\n")); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintln(os.Stderr, "Saved to", secretStore.Path)
+		return
+	}
+
 	ebiten.SetWindowSize(1024, 768)
 	ebiten.SetWindowTitle("Card Flows Infinite Canvas")
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 
-	if err := ebiten.RunGame(NewGame()); err != nil {
+	g := NewGame()
+	if *profile != "" {
+		if err := g.OverrideProfile(*profile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := ebiten.RunGame(g); err != nil {
 		log.Fatal(err)
 	}
 }
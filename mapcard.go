@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+
+	"card-flows/engine"
+)
+
+// AddMapCard adds a card that runs its inner flow once per element of a list or
+// row of a table. Inside, a flow input card named "item" receives the element and
+// one named "index" its position; the flow output card returns the result.
+func (g *Game) AddMapCard(x, y float64) *Card {
+	inner := &Game{}
+	item := inner.AddFlowInputCard(0, 0)
+	item.setParams(map[string]interface{}{"name": "item"})
+	result := inner.AddFlowOutputCard(300, 0)
+	result.setParams(map[string]interface{}{"name": "result"})
+	inner.arrows = []*Arrow{{FromCardID: item.ID, FromPort: "value", ToCardID: result.ID, ToPort: "value", Color: ColorArrowDefault}}
+
+	card := &Card{
+		ID:      NewID(),
+		Type:    "map",
+		X:       math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:       math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:   DefaultCardWidth * 1.5,
+		Height:  DefaultCardHeight * 1.5,
+		Color:   ColorCardComposite,
+		Title:   "Flow:map",
+		Inputs:  []Port{{Name: "items", Type: "any"}},
+		Outputs: []Port{{Name: "results", Type: "any"}, {Name: "errors", Type: "table"}},
+		Params:  map[string]interface{}{},
+		Subflow: &Subflow{Cards: inner.cards, Arrows: inner.arrows},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// mapItems returns the elements a map card iterates over. Table rows are passed
+// as dicts of column name to value.
+func mapItems(v interface{}) ([]interface{}, *engine.Table, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil, fmt.Errorf("the items input is not connected")
+	case []interface{}:
+		return val, nil, nil
+	case []string:
+		items := make([]interface{}, len(val))
+		for i, s := range val {
+			items[i] = s
+		}
+		return items, nil, nil
+	case *engine.Table:
+		items := make([]interface{}, val.NumRows())
+		for i := range items {
+			row := map[string]interface{}{}
+			for j, col := range val.Columns {
+				row[col] = val.Value(i, j)
+			}
+			items[i] = row
+		}
+		return items, val, nil
+	}
+	return nil, nil, fmt.Errorf("the items input must be a list or a table, got %T", v)
+}
+
+// runMapIteration runs the subflow for one element and returns its result
+func runMapIteration(sub *Subflow, vars *runVars, index int, item interface{}) (interface{}, error) {
+	child := sub.Engine()
+	child.args = map[string]interface{}{"item": item, "index": index}
+	child.vars = vars
+	child.Run()
+	for _, ic := range child.game.cards {
+		if ic.LastError != "" {
+			return nil, fmt.Errorf("%s: %s", ic.Title, ic.LastError)
+		}
+	}
+	_, outs := flowPorts(child.game.cards)
+	if len(outs) == 0 {
+		return nil, fmt.Errorf("the inner flow has no flow output card")
+	}
+	return child.Memory[outs[0].ID+":value"], nil
+}
+
+// executeMap runs the subflow for every item, in parallel when the card is set to.
+// A failed item leaves an empty result and a row in the errors table rather than
+// failing the card.
+func executeMap(c *Card, inputs map[string]interface{}) (map[string]interface{}, error) {
+	if c.Subflow == nil {
+		return nil, fmt.Errorf("the map card has no inner flow")
+	}
+	items, table, err := mapItems(inputs["items"])
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]interface{}, len(items))
+	errs := make([]error, len(items))
+	if c.Param("parallel") == "yes" && len(items) > 1 {
+		// Each worker runs its own copy of the subflow
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for range min(runtime.NumCPU(), len(items)) {
+			sub := c.Subflow.clone()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					results[i], errs[i] = runMapIteration(sub, c.vars, i, items[i])
+				}
+			}()
+		}
+		for i := range items {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+	} else {
+		for i, item := range items {
+			results[i], errs[i] = runMapIteration(c.Subflow, c.vars, i, item)
+		}
+	}
+
+	failed := engine.NewTable("index", "error")
+	for i, err := range errs {
+		if err != nil {
+			failed.AppendRow(i, err.Error())
+		}
+	}
+	c.status = fmt.Sprintf("%d items", len(items))
+	if n := failed.NumRows(); n > 0 {
+		c.status = fmt.Sprintf("%d of %d items failed", n, len(items))
+	}
+
+	var out interface{} = results
+	if table != nil {
+		out = mapResultTable(table, results, errs)
+	}
+	return map[string]interface{}{"results": out, "errors": failed}, nil
+}
+
+// mapResultTable collects the results of mapping over a table. When every result
+// is a dict their keys become the columns; otherwise the results are added to the
+// input rows as a "result" column.
+func mapResultTable(in *engine.Table, results []interface{}, errs []error) *engine.Table {
+	var columns []string
+	dicts := true
+	for i, r := range results {
+		row, ok := r.(map[string]interface{})
+		if errs[i] != nil {
+			continue
+		}
+		if !ok {
+			dicts = false
+			break
+		}
+		keys := make([]string, 0, len(row))
+		for k := range row {
+			if !slices.Contains(columns, k) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		columns = append(columns, keys...)
+	}
+
+	if !dicts || len(columns) == 0 {
+		t := engine.NewTable(append(slices.Clone(in.Columns), "result")...)
+		for i, r := range results {
+			t.AppendRow(append(in.Row(i), r)...)
+		}
+		return t
+	}
+	t := engine.NewTable(columns...)
+	for _, r := range results {
+		row, _ := r.(map[string]interface{})
+		values := make([]interface{}, len(columns))
+		for j, col := range columns {
+			values[j] = row[col]
+		}
+		t.AppendRow(values...)
+	}
+	return t
+}
+
+func (c *Card) mapFields(g *Game) []formField {
+	return []formField{
+		{Label: "Open", Action: "open_composite"},
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"card-flows/engine"
+)
+
+// mapWithScript returns a map card whose inner flow runs the script on each item
+func mapWithScript(g *Game, script string) *Card {
+	m := g.AddMapCard(400, 100)
+	item, result := m.Subflow.Cards[0], m.Subflow.Cards[1]
+	f := &Card{
+		ID:      NewID(),
+		Type:    "script",
+		Color:   ColorCardDefault,
+		Title:   "Script",
+		Text:    script,
+		Inputs:  []Port{{Name: "input", Type: "any"}},
+		Outputs: []Port{{Name: "result", Type: "any"}},
+	}
+	m.Subflow.Cards = append(m.Subflow.Cards, f)
+	m.Subflow.Arrows = []*Arrow{
+		{FromCardID: item.ID, FromPort: "value", ToCardID: f.ID, ToPort: "input"},
+		{FromCardID: f.ID, FromPort: "result", ToCardID: result.ID, ToPort: "value"},
+	}
+	return m
+}
+
+func TestMapOverList(t *testing.T) {
+	for _, parallel := range []string{"no", "yes"} {
+		g := NewGame()
+		g.cards = []*Card{}
+		g.arrows = []*Arrow{}
+		m := mapWithScript(g, "result = 100 / input")
+		m.setParams(map[string]interface{}{"parallel": parallel})
+
+		out, err := executeMap(m, map[string]interface{}{"items": []interface{}{1, 0, 4, 5}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := out["results"].([]interface{})
+		if len(results) != 4 || results[0] != 100.0 || results[1] != nil || results[2] != 25.0 || results[3] != 20.0 {
+			t.Errorf("parallel=%s: unexpected results %v", parallel, results)
+		}
+		// The failed item is reported without stopping the others
+		errs := out["errors"].(*engine.Table)
+		if errs.NumRows() != 1 || errs.Value(0, 0) != 1 || !strings.Contains(errs.Value(0, 1).(string), "division by zero") {
+			t.Errorf("parallel=%s: expected one error for item 1, got %v", parallel, errs.Data)
+		}
+		if m.status != "1 of 4 items failed" {
+			t.Errorf("parallel=%s: unexpected status %q", parallel, m.status)
+		}
+	}
+}
+
+func TestMapOverTable(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	grid := g.AddGridCard(100, 100)
+	grid.Cells = [][]string{{"name"}, {"ada"}, {"grace"}}
+	m := mapWithScript(g, "result = input[\"name\"].upper()")
+	g.arrows = append(g.arrows, &Arrow{FromCardID: grid.ID, FromPort: "table", ToCardID: m.ID, ToPort: "items"})
+
+	g.engine.Run()
+	if m.LastError != "" {
+		t.Fatal(m.LastError)
+	}
+	got, ok := g.engine.Memory[m.ID+":results"].(*engine.Table)
+	if !ok {
+		t.Fatalf("Expected a table, got %T", g.engine.Memory[m.ID+":results"])
+	}
+	want := [][]interface{}{{"ada", "grace"}, {"ADA", "GRACE"}}
+	if !reflect.DeepEqual(got.Columns, []string{"name", "result"}) || !reflect.DeepEqual(got.Data, want) {
+		t.Errorf("Expected the results beside the input rows, got %v %v", got.Columns, got.Data)
+	}
+
+	// Dict results become the columns of the output
+	rows := mapResultTable(engine.NewTable("x"), []interface{}{
+		map[string]interface{}{"b": 1, "a": 2},
+		map[string]interface{}{"a": 3, "c": 4},
+	}, make([]error, 2))
+	if !reflect.DeepEqual(rows.Columns, []string{"a", "b", "c"}) || !reflect.DeepEqual(rows.Data, [][]interface{}{{2, 3}, {1, nil}, {nil, 4}}) {
+		t.Errorf("Expected the dict keys as columns, got %v %v", rows.Columns, rows.Data)
+	}
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// markdownFonts holds the faces used to render markdown text cards
+type markdownFonts struct {
+	Regular, Bold, Italic, BoldItalic, Mono font.Face
+	Headings                                [3]font.Face // #, ## and ### and deeper
+}
+
+// LoadMarkdownFonts loads the Go fonts bundled with x/image with body text of the
+// given size, falling back to the basic font for any face that fails to load
+func LoadMarkdownFonts(size float64) *markdownFonts {
+	load := func(ttf []byte, size float64) font.Face {
+		f, err := opentype.Parse(ttf)
+		if err == nil {
+			var face font.Face
+			face, err = opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+			if err == nil {
+				return face
+			}
+		}
+		log.Println("LoadMarkdownFonts: using basic font:", err)
+		return basicfont.Face7x13
+	}
+	return &markdownFonts{
+		Regular:    load(goregular.TTF, size),
+		Bold:       load(gobold.TTF, size),
+		Italic:     load(goitalic.TTF, size),
+		BoldItalic: load(gobolditalic.TTF, size),
+		Mono:       load(gomono.TTF, size),
+		Headings: [3]font.Face{
+			load(gobold.TTF, MarkdownHeadingSizes[0]*size/MarkdownFontSize),
+			load(gobold.TTF, MarkdownHeadingSizes[1]*size/MarkdownFontSize),
+			load(gobold.TTF, MarkdownHeadingSizes[2]*size/MarkdownFontSize),
+		},
+	}
+}
+
+// mdSpan is a run of inline text with one style
+type mdSpan struct {
+	Text         string
+	Bold, Italic bool
+	Code         bool
+}
+
+// mdBlock is one block of a markdown document
+type mdBlock struct {
+	Kind   string // "heading", "paragraph", "line", "bullet", "code", "table" or "rule"
+	Level  int    // heading level, or bullet nesting depth starting at 0
+	Marker string // bullet marker, e.g. "•" or "2."
+	Spans  []mdSpan
+	Rows   [][][]mdSpan // table cells; the first row is the header
+}
+
+var (
+	mdHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdBullet   = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	mdRule     = regexp.MustCompile(`^\s*([-*_])(\s*$|(\s*[-*_]){2,}\s*$)`)
+	mdTableSep = regexp.MustCompile(`^\s*\|?(\s*:?-+:?\s*\|)*\s*:?-+:?\s*\|?\s*$`)
+)
+
+// parseMarkdown splits markdown source into blocks. It covers the common subset:
+// ATX headings, paragraphs, nested bullet and numbered lists, fenced code, pipe
+// tables and horizontal rules.
+func parseMarkdown(src string) []mdBlock {
+	var blocks []mdBlock
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			blocks = append(blocks, mdBlock{Kind: "paragraph", Spans: parseInline(strings.Join(para, " "))})
+			para = nil
+		}
+	}
+
+	inCode := false
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			flush()
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			blocks = append(blocks, mdBlock{Kind: "code", Spans: []mdSpan{{Text: strings.ReplaceAll(line, "\t", "    "), Code: true}}})
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flush()
+		case mdHeading.MatchString(trimmed):
+			flush()
+			m := mdHeading.FindStringSubmatch(trimmed)
+			blocks = append(blocks, mdBlock{Kind: "heading", Level: len(m[1]), Spans: parseInline(m[2])})
+		case strings.HasPrefix(trimmed, "|"):
+			flush()
+			if mdTableSep.MatchString(trimmed) {
+				continue
+			}
+			row := tableCells(trimmed)
+			if n := len(blocks); n > 0 && blocks[n-1].Kind == "table" {
+				blocks[n-1].Rows = append(blocks[n-1].Rows, row)
+			} else {
+				blocks = append(blocks, mdBlock{Kind: "table", Rows: [][][]mdSpan{row}})
+			}
+		case mdRule.MatchString(trimmed) && len(trimmed) >= 3:
+			flush()
+			blocks = append(blocks, mdBlock{Kind: "rule"})
+		case mdBullet.MatchString(line):
+			flush()
+			m := mdBullet.FindStringSubmatch(line)
+			marker := m[2]
+			if strings.ContainsAny(marker, "-*+") {
+				marker = "•"
+			}
+			indent := len(strings.ReplaceAll(m[1], "\t", "  "))
+			blocks = append(blocks, mdBlock{Kind: "bullet", Level: indent / 2, Marker: marker, Spans: parseInline(m[3])})
+		default:
+			para = append(para, trimmed)
+		}
+	}
+	flush()
+	return blocks
+}
+
+// plainBlocks splits text into unstyled lines, so plain text wraps like markdown
+func plainBlocks(src string) []mdBlock {
+	var blocks []mdBlock
+	for _, line := range splitLines(src) {
+		blocks = append(blocks, mdBlock{Kind: "line", Spans: []mdSpan{{Text: line}}})
+	}
+	return blocks
+}
+
+// tableCells splits a pipe table row into its cells
+func tableCells(line string) [][]mdSpan {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	var cells [][]mdSpan
+	for _, cell := range strings.Split(line, "|") {
+		cells = append(cells, parseInline(strings.TrimSpace(cell)))
+	}
+	return cells
+}
+
+// parseInline splits text into styled spans for **bold**, *italic* (or _italic_
+// at word boundaries) and `code`. A marker without a closing partner is literal.
+func parseInline(s string) []mdSpan {
+	var spans []mdSpan
+	var cur mdSpan
+	emit := func() {
+		if cur.Text != "" {
+			spans = append(spans, cur)
+		}
+		cur.Text = ""
+	}
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end >= 0 {
+				emit()
+				spans = append(spans, mdSpan{Text: rest[1 : end+1], Bold: cur.Bold, Italic: cur.Italic, Code: true})
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(rest, "**"), strings.HasPrefix(rest, "__"):
+			if cur.Bold || strings.Contains(rest[2:], rest[:2]) {
+				emit()
+				cur.Bold = !cur.Bold
+				i += 2
+				continue
+			}
+		case rest[0] == '*', rest[0] == '_' && (cur.Italic || i == 0 || s[i-1] == ' '):
+			closing := cur.Italic && (rest[0] == '*' || len(rest) == 1 || !isWordByte(rest[1]))
+			if closing || (!cur.Italic && strings.IndexByte(rest[1:], rest[0]) > 0) {
+				emit()
+				cur.Italic = !cur.Italic
+				i++
+				continue
+			}
+		}
+		cur.Text += rest[:1]
+		i++
+	}
+	emit()
+	return spans
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// mdItem is a positioned piece of laid out markdown: text when Text is set,
+// otherwise a filled rectangle such as a rule, table border or code background.
+// Positions are relative to the top left of the content area.
+type mdItem struct {
+	Text       string
+	Face       font.Face
+	X, Y, W, H float64
+	Color      color.Color
+}
+
+// textStyle is how a card's rich text is rendered
+type textStyle struct {
+	Markdown bool    // parse the text as markdown rather than plain lines
+	Size     float64 // body font size
+	Color    color.Color
+}
+
+// markdownLayout caches the laid out text of a card until its text, width or style changes
+type markdownLayout struct {
+	src    string
+	width  float64
+	style  textStyle
+	items  []mdItem
+	height float64
+}
+
+// face returns the face for a span in a block whose base face is base
+func (f *markdownFonts) face(sp mdSpan, base font.Face) font.Face {
+	switch {
+	case sp.Code:
+		return f.Mono
+	case base != f.Regular:
+		return base
+	case sp.Bold && sp.Italic:
+		return f.BoldItalic
+	case sp.Bold:
+		return f.Bold
+	case sp.Italic:
+		return f.Italic
+	}
+	return f.Regular
+}
+
+func faceHeight(face font.Face) float64 {
+	m := face.Metrics()
+	return float64((m.Ascent + m.Descent).Ceil())
+}
+
+func measure(face font.Face, s string) float64 {
+	return float64(font.MeasureString(face, s).Ceil())
+}
+
+// layoutMarkdown positions the blocks within width, wrapping words onto new lines,
+// and returns the items with the total height
+func layoutMarkdown(blocks []mdBlock, f *markdownFonts, width float64, clr color.Color) ([]mdItem, float64) {
+	var items []mdItem
+	y := 0.0
+
+	// flow lays out spans from x0, wrapping back to x0, and returns the height used
+	flow := func(spans []mdSpan, base font.Face, x0, top float64) float64 {
+		lineH := faceHeight(base)
+		x, lineY := x0, top
+		for _, sp := range spans {
+			face := f.face(sp, base)
+			space := measure(face, " ")
+			for i, word := range strings.Split(sp.Text, " ") {
+				if i > 0 {
+					x += space
+				}
+				if word == "" {
+					continue
+				}
+				w := measure(face, word)
+				if x+w > width && x > x0 {
+					x, lineY = x0, lineY+lineH
+				}
+				if sp.Code {
+					items = append(items, mdItem{X: x - 2, Y: lineY, W: w + 4, H: lineH, Color: ColorMarkdownCode})
+				}
+				items = append(items, mdItem{Text: word, Face: face, X: x, Y: lineY, W: w, H: lineH, Color: clr})
+				x += w
+			}
+		}
+		return lineY + lineH - top
+	}
+
+	for i, b := range blocks {
+		// Consecutive plain lines, list items and code lines stay together
+		if i > 0 && !(b.Kind == blocks[i-1].Kind && (b.Kind == "line" || b.Kind == "code" || b.Kind == "bullet")) {
+			y += MarkdownBlockGap
+		}
+		switch b.Kind {
+		case "heading":
+			face := f.Headings[min(b.Level, 3)-1]
+			y += flow(b.Spans, face, 0, y)
+		case "paragraph", "line":
+			y += flow(b.Spans, f.Regular, 0, y)
+		case "bullet":
+			indent := float64(b.Level) * MarkdownIndent
+			items = append(items, mdItem{Text: b.Marker, Face: f.Regular, X: indent, Y: y, W: measure(f.Regular, b.Marker), H: faceHeight(f.Regular), Color: ColorMarkdownDim})
+			y += flow(b.Spans, f.Regular, indent+MarkdownIndent, y)
+		case "code":
+			h := faceHeight(f.Mono)
+			items = append(items, mdItem{X: 0, Y: y, W: width, H: h, Color: ColorMarkdownCode})
+			items = append(items, mdItem{Text: b.Spans[0].Text, Face: f.Mono, X: MarkdownCellPadding, Y: y, W: measure(f.Mono, b.Spans[0].Text), H: h, Color: clr})
+			y += h
+		case "rule":
+			items = append(items, mdItem{X: 0, Y: y + MarkdownBlockGap/2, W: width, H: 1, Color: ColorMarkdownDim})
+			y += MarkdownBlockGap
+		case "table":
+			tableItems, h := layoutTable(b.Rows, f, clr)
+			for _, it := range tableItems {
+				it.Y += y
+				items = append(items, it)
+			}
+			y += h
+		}
+	}
+	return items, y
+}
+
+// layoutTable sizes each column to its widest cell and draws the header in bold
+func layoutTable(rows [][][]mdSpan, f *markdownFonts, clr color.Color) ([]mdItem, float64) {
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	cellFace := func(r int, sp mdSpan) font.Face {
+		if r == 0 {
+			sp.Bold = true
+		}
+		return f.face(sp, f.Regular)
+	}
+	widths := make([]float64, cols)
+	for r, row := range rows {
+		for c, cell := range row {
+			w := 0.0
+			for _, sp := range cell {
+				w += measure(cellFace(r, sp), sp.Text)
+			}
+			widths[c] = max(widths[c], w)
+		}
+	}
+
+	var items []mdItem
+	rowH := faceHeight(f.Regular) + 2*MarkdownCellPadding
+	total := 0.0
+	for _, w := range widths {
+		total += w + 2*MarkdownCellPadding
+	}
+	for r, row := range rows {
+		y := float64(r) * rowH
+		x := 0.0
+		for c := 0; c < cols; c++ {
+			cx := x + MarkdownCellPadding
+			if c < len(row) {
+				for _, sp := range row[c] {
+					face := cellFace(r, sp)
+					w := measure(face, sp.Text)
+					items = append(items, mdItem{Text: sp.Text, Face: face, X: cx, Y: y + MarkdownCellPadding, W: w, H: faceHeight(face), Color: clr})
+					cx += w
+				}
+			}
+			x += widths[c] + 2*MarkdownCellPadding
+			if c < cols-1 {
+				items = append(items, mdItem{X: x, Y: y, W: 1, H: rowH, Color: ColorMarkdownDim})
+			}
+		}
+		items = append(items, mdItem{X: 0, Y: y + rowH, W: total, H: 1, Color: ColorMarkdownDim})
+	}
+	return items, float64(len(rows)) * rowH
+}
+
+// ToggleMarkdown switches a card between plain and markdown rendering
+func (g *Game) ToggleMarkdown(card interface{}) {
+	if c, ok := card.(*Card); ok && c != nil {
+		c.Markdown = !c.Markdown
+	}
+}
+
+// fontsAt returns the markdown fonts for a body size, loading them on first use
+func (g *Game) fontsAt(size float64) *markdownFonts {
+	if g.markdownFonts == nil {
+		g.markdownFonts = map[float64]*markdownFonts{}
+	}
+	f, ok := g.markdownFonts[size]
+	if !ok {
+		f = LoadMarkdownFonts(size)
+		g.markdownFonts[size] = f
+	}
+	return f
+}
+
+// drawRichText renders src wrapped to the screen rectangle and clipped to it
+func (c *Card) drawRichText(screen *ebiten.Image, g *Game, src string, style textStyle, x, y, w, h float64) {
+	if w <= 0 || h <= 0 {
+		return
+	}
+	if l := c.markdown; l == nil || l.src != src || l.width != w || l.style != style {
+		blocks := plainBlocks(src)
+		if style.Markdown {
+			blocks = parseMarkdown(src)
+		}
+		items, height := layoutMarkdown(blocks, g.fontsAt(style.Size), w, style.Color)
+		c.markdown = &markdownLayout{src: src, width: w, style: style, items: items, height: height}
+	}
+
+	clip := screen.SubImage(image.Rect(int(x), int(y), int(x+w), int(y+h))).(*ebiten.Image)
+	for _, it := range c.markdown.items {
+		if it.Y > h {
+			break
+		}
+		if it.Text == "" {
+			vector.DrawFilledRect(clip, float32(x+it.X), float32(y+it.Y), float32(it.W), float32(it.H), it.Color, false)
+			continue
+		}
+		ascent := it.Face.Metrics().Ascent.Ceil()
+		text.Draw(clip, it.Text, it.Face, int(x+it.X), int(y+it.Y)+ascent, it.Color)
+	}
+}
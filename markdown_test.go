@@ -0,0 +1,64 @@
+package main
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/font/basicfont"
+)
+
+func TestParseMarkdown(t *testing.T) {
+	src := "# Sales *report*\n\nTotals by\nregion:\n\n- north\n  - Q1\n2. south\n\n```\nx = 1\n```\n---\n| Region | Total |\n|---|--:|\n| north | **13.5** |"
+	blocks := parseMarkdown(src)
+	var kinds []string
+	for _, b := range blocks {
+		kinds = append(kinds, b.Kind)
+	}
+	want := []string{"heading", "paragraph", "bullet", "bullet", "bullet", "code", "rule", "table"}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("Expected blocks %v, got %v", want, kinds)
+	}
+	if blocks[0].Level != 1 || !reflect.DeepEqual(blocks[0].Spans, []mdSpan{{Text: "Sales "}, {Text: "report", Italic: true}}) {
+		t.Errorf("Unexpected heading %+v", blocks[0])
+	}
+	if blocks[1].Spans[0].Text != "Totals by region:" {
+		t.Errorf("Expected paragraph lines to be joined, got %+v", blocks[1].Spans)
+	}
+	if blocks[3].Level != 1 || blocks[3].Marker != "•" || blocks[4].Marker != "2." {
+		t.Errorf("Unexpected bullets %+v %+v", blocks[3], blocks[4])
+	}
+	table := blocks[7].Rows
+	if len(table) != 2 || !reflect.DeepEqual(table[1][1], []mdSpan{{Text: "13.5", Bold: true}}) {
+		t.Errorf("Unexpected table rows %+v", table)
+	}
+}
+
+func TestParseInline(t *testing.T) {
+	tests := map[string][]mdSpan{
+		"a **b** *c* `d*e`": {{Text: "a "}, {Text: "b", Bold: true}, {Text: " "}, {Text: "c", Italic: true}, {Text: " "}, {Text: "d*e", Code: true}},
+		"snake_case_name":   {{Text: "snake_case_name"}},
+		"_italic_ word":     {{Text: "italic", Italic: true}, {Text: " word"}},
+		"2 * 3 = 6":         {{Text: "2 * 3 = 6"}},
+		"**unclosed":        {{Text: "**unclosed"}},
+	}
+	for src, want := range tests {
+		if got := parseInline(src); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: expected %+v, got %+v", src, want, got)
+		}
+	}
+}
+
+func TestLayoutMarkdownWraps(t *testing.T) {
+	face := basicfont.Face7x13
+	fonts := &markdownFonts{Regular: face, Bold: face, Italic: face, BoldItalic: face, Mono: face}
+	items, height := layoutMarkdown(parseMarkdown("aaaa bbbb cccc"), fonts, 70, color.White)
+	var ys []float64
+	for _, it := range items {
+		ys = append(ys, it.Y)
+	}
+	// Each word is 28px wide, so two fit on the first line
+	if !reflect.DeepEqual(ys, []float64{0, 0, 13}) || height != 26 {
+		t.Errorf("Expected the third word to wrap, got y %v and height %v", ys, height)
+	}
+}
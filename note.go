@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// NoteColors lists the note color choices in cycling order
+var NoteColors = []string{"yellow", "pink", "blue", "green", "gray"}
+
+// NoteSizes lists the note text sizes in cycling order
+var NoteSizes = []string{"small", "normal", "large"}
+
+var noteColorValues = map[string]color.RGBA{
+	"yellow": {250, 230, 140, 255},
+	"pink":   {250, 190, 200, 255},
+	"blue":   {175, 210, 250, 255},
+	"green":  {190, 230, 170, 255},
+	"gray":   {210, 210, 215, 255},
+}
+
+var noteSizeValues = map[string]float64{
+	"small":  12,
+	"normal": 15,
+	"large":  20,
+}
+
+// AddNoteCard adds a sticky note. Notes have no ports and are never run.
+func (g *Game) AddNoteCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "note",
+		X:      math.Round(x/SnapGridSmall) * SnapGridSmall,
+		Y:      math.Round(y/SnapGridSmall) * SnapGridSmall,
+		Width:  DefaultCardWidth,
+		Height: DefaultCardHeight * 1.5,
+		Color:  noteColorValues["yellow"],
+		Title:  "Note",
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+func (g *Game) AddNoteCardHandle(wx, wy float64) interface{} {
+	return g.AddNoteCard(wx, wy)
+}
+
+// IsNote reports whether the card is an annotation that takes no part in execution
+func (c *Card) IsNote() bool {
+	return c.Type == "note"
+}
+
+// cycleNoteParam moves a note parameter to the next of its choices
+func (c *Card) cycleNoteParam(name string, choices []string) string {
+	i := slices.Index(choices, fmt.Sprint(c.Param(name)))
+	next := choices[(i+1)%len(choices)]
+	c.setParams(map[string]interface{}{name: next})
+	return next
+}
+
+// noteButtonAt returns the note style button under the world position, or ""
+func (c *Card) noteButtonAt(wx, wy float64) string {
+	if wy < c.Y+5 || wy > c.Y+5+CardActionButtonHeight {
+		return ""
+	}
+	for i, action := range []string{"note_color", "note_size"} {
+		x := c.noteButtonX(i)
+		if wx >= x && wx <= x+CardActionButtonWidth {
+			return action
+		}
+	}
+	return ""
+}
+
+// noteButtonX returns the world x of a style button, left of the settings gear
+func (c *Card) noteButtonX(i int) float64 {
+	return c.X + c.Width - float64(i+4)*(CardActionButtonWidth+5)
+}
+
+// attachNote pins a dropped note to the card under its center, or unpins it when
+// it was dropped on empty canvas
+func (g *Game) attachNote(note *Card) {
+	note.AttachedTo = ""
+	cx, cy := note.X+note.Width/2, note.Y+note.Height/2
+	for i := len(g.cards) - 1; i >= 0; i-- {
+		c := g.cards[i]
+		if !c.IsNote() && cx >= c.X && cx < c.X+c.Width && cy >= c.Y && cy < c.Y+c.Height {
+			note.AttachedTo = c.ID
+			return
+		}
+	}
+}
+
+// moveAttachedNotes moves the notes pinned to a card along with it
+func (g *Game) moveAttachedNotes(c *Card, dx, dy float64) {
+	for _, n := range g.cards {
+		if n.AttachedTo == c.ID {
+			n.X += dx
+			n.Y += dy
+		}
+	}
+}
+
+// DropCard is called when a dragged card is released
+func (g *Game) DropCard(card interface{}) {
+	if c, ok := card.(*Card); ok && c.IsNote() {
+		g.attachNote(c)
+	}
+}
+
+// drawNote draws the note text in dark ink, with its style buttons and a pin when attached
+func (c *Card) drawNote(screen *ebiten.Image, g *Game, sx, sy, sw, sh float64) {
+	zoom := g.camera.Zoom
+	editing := g.input != nil && g.input.EditingCard == c
+	text := c.Text
+	if editing && (time.Now().UnixMilli()/CursorBlinkRate)%2 == 0 {
+		text += "|"
+	}
+	style := textStyle{
+		Markdown: c.Markdown && !editing,
+		Size:     noteSizeValues[fmt.Sprint(c.Param("size"))],
+		Color:    ColorNoteText,
+	}
+	x, y := sx+CardPaddingX*zoom, sy+HeaderHeight*zoom+CardPaddingY*zoom
+	c.drawRichText(screen, g, text, style, x, y, sw-2*CardPaddingX*zoom, sh-(HeaderHeight+2*CardPaddingY)*zoom)
+
+	// Style buttons: a swatch of the next color and the text size
+	btnW, btnH := CardActionButtonWidth*zoom, CardActionButtonHeight*zoom
+	by := sy + 5*zoom
+	next := NoteColors[(slices.Index(NoteColors, fmt.Sprint(c.Param("color")))+1)%len(NoteColors)]
+	cx := sx + (c.noteButtonX(0)-c.X)*zoom
+	vector.DrawFilledRect(screen, float32(cx), float32(by), float32(btnW), float32(btnH), noteColorValues[next], false)
+	vector.StrokeRect(screen, float32(cx), float32(by), float32(btnW), float32(btnH), 1, ColorNoteText, false)
+	ax := sx + (c.noteButtonX(1)-c.X)*zoom
+	vector.StrokeRect(screen, float32(ax), float32(by), float32(btnW), float32(btnH), 1, ColorNoteText, false)
+	DrawTextLines(screen, g.FontFace, "A", int(ax+btnW/2-5), int(by+btnH/2-9), ColorNoteText)
+
+	if c.AttachedTo != "" {
+		vector.DrawFilledCircle(screen, float32(sx+sw/2), float32(sy), float32(NotePinRadius*zoom), ColorNotePin, false)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNotesAreNotExecuted(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	text := g.AddTextCard(100, 100)
+	note := g.AddNoteCard(400, 100)
+	note.Text = "print('not code')"
+
+	order, err := g.engine.getExecutionOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 1 || order[0] != text {
+		t.Errorf("Expected only the text card to run, got %d cards", len(order))
+	}
+	g.engine.Run()
+	if _, ran := g.engine.ExecutionCache[note.ID]; ran || note.LastError != "" {
+		t.Errorf("Expected the note to be skipped")
+	}
+}
+
+func TestNoteAttachesAndMovesWithCard(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	card := g.AddTextCard(100, 100)
+	note := g.AddNoteCard(500, 500)
+
+	// Dropping the note over the card pins it there
+	g.SetCardBounds(note, card.X+50, card.Y+20, note.Width, note.Height)
+	g.DropCard(note)
+	if note.AttachedTo != card.ID {
+		t.Fatalf("Expected the note to be attached, got %q", note.AttachedTo)
+	}
+
+	g.SetCardBounds(card, card.X+30, card.Y-40, card.Width, card.Height)
+	if note.X != 180 || note.Y != 80 {
+		t.Errorf("Expected the note to move with the card to (180, 80), got (%v, %v)", note.X, note.Y)
+	}
+	// Resizing the card leaves the note in place
+	g.SetCardBounds(card, card.X-10, card.Y, card.Width+10, card.Height)
+	if note.X != 180 {
+		t.Errorf("Expected the note to stay put while resizing, got x %v", note.X)
+	}
+
+	// The pin is saved with the flow
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	if err := SaveState(g, path); err != nil {
+		t.Fatal(err)
+	}
+	g2 := NewGame()
+	if err := LoadState(g2, path); err != nil {
+		t.Fatal(err)
+	}
+	if g2.getCardByID(note.ID).AttachedTo != card.ID {
+		t.Errorf("Expected the pin to be saved")
+	}
+
+	g.SetCardBounds(note, 900, 900, note.Width, note.Height)
+	g.DropCard(note)
+	if note.AttachedTo != "" {
+		t.Errorf("Expected the note to be unpinned when dropped on empty canvas")
+	}
+
+	g.SetCardBounds(note, card.X, card.Y, note.Width, note.Height)
+	g.DropCard(note)
+	g.DeleteCard(card)
+	if note.AttachedTo != "" || g.getCardByID(note.ID) == nil {
+		t.Errorf("Expected deleting the card to keep the note but unpin it")
+	}
+}
+
+func TestNoteStyleButtons(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	note := g.AddNoteCard(100, 100)
+
+	colorX := note.noteButtonX(0) + 1
+	if action := g.CheckActionButton(note, colorX, note.Y+10); action != "note_color" {
+		t.Fatalf("Expected the color button, got %q", action)
+	}
+	g.PerformCardAction(note, "note_color", colorX, note.Y+10)
+	if note.Param("color") != "pink" || note.Color != noteColorValues["pink"] {
+		t.Errorf("Expected the note to turn pink, got %v", note.Param("color"))
+	}
+	for range NoteSizes {
+		g.PerformCardAction(note, "note_size", 0, 0)
+	}
+	if note.Param("size") != "normal" {
+		t.Errorf("Expected the sizes to cycle back to normal, got %v", note.Param("size"))
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"card-flows/engine"
+	"card-flows/secrets"
+)
+
+// ParamKind is the type of a card parameter, deciding how the settings panel
+// edits it and what value scripts receive
+type ParamKind string
+
+const (
+	ParamText   ParamKind = "text"
+	ParamNumber ParamKind = "number"
+	ParamBool   ParamKind = "bool"
+	ParamEnum   ParamKind = "enum"
+	ParamPath   ParamKind = "path"
+	ParamSecret ParamKind = "secret" // name of a secret, whose value scripts get
+)
+
+// ParamSpec describes one parameter in a card's settings panel. Its default
+// value comes from cardParamDefaults.
+type ParamSpec struct {
+	Name    string
+	Label   string
+	Kind    ParamKind
+	Options []string // choices of an enum
+}
+
+// cardParamSpecs lists the parameters of each card type edited in the settings
+// panel opened with the gear in the card header. List parameters, such as the
+// filter conditions, are edited as rows in the card body instead. A composite's
+// type is only set by loading it from the library.
+var cardParamSpecs = map[string][]ParamSpec{
+	"find_replace": {
+		{Name: "regex", Label: "Regex", Kind: ParamBool},
+		{Name: "ignore_case", Label: "Ignore case", Kind: ParamBool},
+		{Name: "whole_word", Label: "Whole word", Kind: ParamBool},
+		{Name: "max_count", Label: "Max replacements", Kind: ParamNumber},
+	},
+	"join": {
+		{Name: "keys", Label: "Keys", Kind: ParamText},
+		{Name: "mode", Label: "Mode", Kind: ParamEnum, Options: []string{"inner", "left", "right", "outer"}},
+		{Name: "left_suffix", Label: "Left suffix", Kind: ParamText},
+		{Name: "right_suffix", Label: "Right suffix", Kind: ParamText},
+	},
+	"groupby": {
+		{Name: "pivot", Label: "Pivot", Kind: ParamText},
+	},
+	"filter": {
+		{Name: "match", Label: "Match", Kind: ParamEnum, Options: []string{"all", "any"}},
+	},
+	"chart": {
+		{Name: "kind", Label: "Chart", Kind: ParamEnum, Options: ChartKinds},
+		{Name: "x", Label: "X", Kind: ParamText},
+		{Name: "y", Label: "Y", Kind: ParamText},
+	},
+	"export": {
+		{Name: "path", Label: "Path", Kind: ParamPath},
+		{Name: "format", Label: "Format", Kind: ParamEnum, Options: engine.ExportFormats},
+		{Name: "mode", Label: "Mode", Kind: ParamEnum, Options: engine.ExportModes},
+	},
+	"xlsx_import": {
+		{Name: "path", Label: "Path", Kind: ParamPath},
+		{Name: "sheet", Label: "Sheet", Kind: ParamText},
+		{Name: "header", Label: "Header row", Kind: ParamEnum, Options: []string{"yes", "no"}},
+	},
+	"xlsx_export": {
+		{Name: "path", Label: "Path", Kind: ParamPath},
+		{Name: "sheet", Label: "Sheet", Kind: ParamText},
+		{Name: "mode", Label: "Mode", Kind: ParamEnum, Options: XLSXExportModes},
+	},
+	"template": {
+		{Name: "syntax", Label: "Syntax", Kind: ParamEnum, Options: engine.TemplateSyntaxes},
+		{Name: "inputs", Label: "Inputs", Kind: ParamText},
+	},
+	"note": {
+		{Name: "color", Label: "Color", Kind: ParamEnum, Options: NoteColors},
+		{Name: "size", Label: "Size", Kind: ParamEnum, Options: NoteSizes},
+	},
+	"concat": {
+		{Name: "separator", Label: "Separator", Kind: ParamText},
+	},
+	"map": {
+		{Name: "parallel", Label: "Parallel", Kind: ParamEnum, Options: []string{"no", "yes"}},
+	},
+	"flow_input": {
+		{Name: "name", Label: "Name", Kind: ParamText},
+	},
+	"flow_output": {
+		{Name: "name", Label: "Name", Kind: ParamText},
+	},
+	"call_flow": {
+		{Name: "path", Label: "Flow", Kind: ParamPath},
+	},
+	"llm": {
+		{Name: "task", Label: "Task", Kind: ParamEnum, Options: llmTaskNames},
+		{Name: "tone", Label: "Tone", Kind: ParamEnum, Options: llmTones},
+		{Name: "instructions", Label: "Instructions", Kind: ParamText},
+		{Name: "provider", Label: "Provider", Kind: ParamEnum, Options: []string{"openai", "mock"}},
+		{Name: "model", Label: "Model", Kind: ParamText},
+		{Name: "base_url", Label: "Base URL", Kind: ParamText},
+		{Name: "api_key", Label: "API key", Kind: ParamSecret},
+		{Name: "temperature", Label: "Temperature", Kind: ParamNumber},
+	},
+}
+
+// HasSettings reports whether the card has a settings panel
+func (c *Card) HasSettings() bool {
+	return len(cardParamSpecs[c.Type]) > 0
+}
+
+// convertParam turns a saved or typed parameter value into the Go value of its
+// kind, so scripts get numbers and booleans rather than whatever the file held
+func convertParam(spec ParamSpec, v interface{}) (interface{}, error) {
+	switch spec.Kind {
+	case ParamNumber:
+		switch n := v.(type) {
+		case int, int64, float64:
+			return n, nil
+		case string:
+			s := strings.TrimSpace(n)
+			if i, err := strconv.Atoi(s); err == nil {
+				return i, nil
+			}
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("%s must be a number, got %v", spec.Label, v)
+	case ParamBool:
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(b)) {
+			case "true", "yes", "1":
+				return true, nil
+			case "false", "no", "0", "":
+				return false, nil
+			}
+		}
+		return nil, fmt.Errorf("%s must be true or false, got %v", spec.Label, v)
+	case ParamEnum:
+		s := fmt.Sprint(v)
+		if !slices.Contains(spec.Options, s) {
+			return nil, fmt.Errorf("%s must be one of %s, got %q", spec.Label, strings.Join(spec.Options, ", "), s)
+		}
+		return s, nil
+	}
+
+	var s string
+	switch val := v.(type) {
+	case nil:
+	case string:
+		s = val
+	case int, int64, float64, bool:
+		s = fmt.Sprint(val)
+	default:
+		return nil, fmt.Errorf("%s must be text, got %T", spec.Label, v)
+	}
+	if spec.Kind == ParamPath && s != "" {
+		s = filepath.Clean(s)
+	}
+	if spec.Kind == ParamSecret && s != "" && !secrets.ValidName(s) {
+		return nil, fmt.Errorf("%s must name a secret rather than hold its value", spec.Label)
+	}
+	return s, nil
+}
+
+// ScriptParams returns the card's resolved parameters as script globals, with
+// the values of the settings panel converted to their kinds. Secret parameters
+// give the value of the named secret.
+func (c *Card) ScriptParams() (map[string]interface{}, error) {
+	params := c.ResolvedParams()
+	for _, spec := range cardParamSpecs[c.Type] {
+		v, err := convertParam(spec, params[spec.Name])
+		if err == nil && spec.Kind == ParamSecret {
+			v, err = resolveSecret(spec, v.(string))
+		}
+		if err != nil {
+			return nil, err
+		}
+		params[spec.Name] = v
+	}
+	return params, nil
+}
+
+// settingsFields returns the settings panel of a card as form rows. Booleans
+// and enums are clicked through; the other kinds are typed after a double-click.
+func (c *Card) settingsFields(g *Game) []formField {
+	specs := cardParamSpecs[c.Type]
+	fields := make([]formField, 0, len(specs)+1)
+	for _, spec := range specs {
+		name := spec.Name
+		f := formField{Label: spec.Label}
+		if v := c.Param(name); v != nil {
+			f.Value = fmt.Sprint(v)
+		}
+		switch spec.Kind {
+		case ParamBool:
+			v, _ := convertParam(spec, c.Param(name))
+			f.Value = fmt.Sprint(v)
+			f.Options = []string{"false", "true"}
+			f.Set = func(v string) { g.setParam(c, name, v == "true") }
+		case ParamEnum:
+			f.Options = spec.Options
+			f.Set = func(v string) { g.setParam(c, name, v) }
+		case ParamNumber:
+			f.Edit = true
+			f.Set = func(v string) {
+				// Keep what was typed as text unless it reads back the same as a
+				// number, so that "1." or "-" can still be typed on
+				var value interface{} = v
+				if n, err := convertParam(spec, v); err == nil && fmt.Sprint(n) == v {
+					value = n
+				}
+				g.setParam(c, name, value)
+			}
+		default:
+			f.Edit = true
+			f.Secret = spec.Kind == ParamSecret
+			f.Set = func(v string) {
+				switch spec.Kind {
+				case ParamSecret:
+					v = strings.TrimSpace(v)
+				case ParamPath:
+					if v != "" {
+						v = filepath.Clean(v)
+					}
+				}
+				g.setParam(c, name, v)
+			}
+		}
+		fields = append(fields, f)
+	}
+	return append(fields, formField{Label: "Close settings", Action: "settings"})
+}
+
+// setParam sets a parameter from the settings panel and updates what the card
+// derives from it, as the card's own form does
+func (g *Game) setParam(c *Card, name string, v interface{}) {
+	switch {
+	case c.Type == "template" && name == "inputs":
+		c.setTemplateInputs(fmt.Sprint(v))
+	case c.Type == "export" && name == "format":
+		c.setExportFormat(fmt.Sprint(v))
+	default:
+		c.setParams(map[string]interface{}{name: v})
+	}
+	switch {
+	case c.Type == "call_flow" && name == "path":
+		g.refreshCallFlow(c)
+	case c.Type == "note" && name == "color":
+		c.Color = noteColorValues[fmt.Sprint(v)]
+	}
+}
+
+// settingsButton returns the world rectangle of the gear left of the header's
+// duplicate button
+func (c *Card) settingsButton() (x, y, w, h float64) {
+	return c.X + c.Width - 3*CardActionButtonWidth - 15, c.Y + 5, CardActionButtonWidth, CardActionButtonHeight
+}
+
+// toggleSettings opens or closes the settings panel, which replaces the card's
+// body while open
+func (c *Card) toggleSettings() {
+	c.settingsOpen = !c.settingsOpen
+	c.FormField = -1
+	if c.settingsOpen {
+		rows := float64(len(cardParamSpecs[c.Type]) + 2)
+		c.Height = math.Max(c.Height, HeaderHeight+CardPaddingY+rows*FormRowHeight+FooterHeight)
+	}
+}
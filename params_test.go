@@ -0,0 +1,153 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParamsConvertedForScripts(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	input := g.AddTextCard(100, 100)
+	input.Text = "a A a"
+	find := g.AddTextCard(300, 100)
+	find.Text = "a"
+	fr := g.AddFindReplaceCard(100, 300)
+	g.Connect(input, "text", fr, "input")
+	g.Connect(find, "text", fr, "find")
+
+	// Values typed or loaded as text still reach the script as their kinds
+	fr.Params = map[string]interface{}{"ignore_case": "yes", "max_count": "2"}
+	g.engine.Run()
+	if fr.LastError != "" {
+		t.Fatal(fr.LastError)
+	}
+	if got := g.engine.Memory[fr.ID+":result"]; got != "  a" {
+		t.Errorf("Expected two case-insensitive replacements, got %q", got)
+	}
+
+	fr.Params["max_count"] = "two"
+	g.engine.Run()
+	if !strings.Contains(fr.LastError, "Max replacements must be a number") {
+		t.Errorf("Expected a bad number to be reported, got %q", fr.LastError)
+	}
+
+	// Text with quotes is a value, not source code
+	concat := g.AddConcatCard(300, 500)
+	concat.setParams(map[string]interface{}{"separator": `" + "`})
+	g.Connect(input, "text", concat, "text_1")
+	g.Connect(find, "text", concat, "text_2")
+	g.engine.Run()
+	if got := g.engine.Memory[concat.ID+":text"]; got != `a A a" + "a` {
+		t.Errorf("Expected the separator to be used as is, got %v (error %q)", got, concat.LastError)
+	}
+}
+
+func TestSettingsPanel(t *testing.T) {
+	g := NewGame()
+	fr := g.AddFindReplaceCard(0, 0)
+	if !fr.HasSettings() || g.AddTextCard(0, 200).HasSettings() {
+		t.Fatal("Expected only the find/replace card to have settings")
+	}
+	x, y, _, _ := fr.settingsButton()
+	if action := g.CheckActionButton(fr, x+1, y+1); action != "settings" {
+		t.Fatalf("Expected the gear to open the settings, got %q", action)
+	}
+	g.PerformCardAction(fr, "settings", x+1, y+1)
+
+	fields := fr.formFields(g)
+	if len(fields) != 5 || fields[0].Label != "Regex" || fields[0].Value != "false" || !fields[3].Edit {
+		t.Fatalf("Expected the find/replace settings, got %+v", fields)
+	}
+	fields[0].Set(nextOption(fields[0].Value, fields[0].Options))
+	fields[3].Set("3")
+	if fr.Param("regex") != true || fr.Param("max_count") != 3 {
+		t.Errorf("Expected typed values, got %v %v", fr.Param("regex"), fr.Param("max_count"))
+	}
+	fields[3].Set("3.")
+	if fr.Param("max_count") != "3." {
+		t.Errorf("Expected the partly typed number to be kept, got %v", fr.Param("max_count"))
+	}
+
+	fr.toggleSettings()
+	if fr.formFields(g) != nil {
+		t.Errorf("Expected the card body back after closing the settings")
+	}
+}
+
+func TestConvertParam(t *testing.T) {
+	enum := ParamSpec{Name: "mode", Label: "Mode", Kind: ParamEnum, Options: []string{"a", "b"}}
+	if _, err := convertParam(enum, "c"); err == nil {
+		t.Error("Expected an unknown option to fail")
+	}
+	path := ParamSpec{Name: "path", Label: "Path", Kind: ParamPath}
+	if v, _ := convertParam(path, "data/../out.csv"); v != "out.csv" {
+		t.Errorf("Expected a clean path, got %v", v)
+	}
+	secret := ParamSpec{Name: "key", Label: "Key", Kind: ParamSecret}
+	if _, err := convertParam(secret, map[string]interface{}{}); err == nil {
+		t.Error("Expected a dict to be rejected as text")
+	}
+}
+
+func TestEveryParamHasASpec(t *testing.T) {
+	for typ, defaults := range cardParamDefaults {
+		for name, v := range defaults {
+			_, list := v.([]interface{})
+			if list || typ == "composite" {
+				continue
+			}
+			if !slices.ContainsFunc(cardParamSpecs[typ], func(s ParamSpec) bool { return s.Name == name }) {
+				t.Errorf("Expected a settings spec for the %s parameter %q", typ, name)
+			}
+		}
+	}
+}
+
+func TestSettingsUpdateTheCard(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	set := func(c *Card, label, v string) {
+		t.Helper()
+		c.toggleSettings()
+		defer c.toggleSettings()
+		for _, f := range c.formFields(g) {
+			if f.Label == label {
+				f.Set(v)
+				return
+			}
+		}
+		t.Fatalf("Expected a %q setting on the %s card", label, c.Type)
+	}
+
+	export := g.AddExportCard(0, 0)
+	set(export, "Path", "out/../report.csv")
+	set(export, "Format", "json")
+	if export.Param("path") != "report.json" {
+		t.Errorf("Expected a clean path following the format, got %v", export.Param("path"))
+	}
+
+	note := g.AddNoteCard(0, 200)
+	set(note, "Color", "blue")
+	if note.Color != noteColorValues["blue"] {
+		t.Errorf("Expected the note to turn blue, got %v", note.Color)
+	}
+
+	tmpl := g.AddTemplateCard(0, 400)
+	set(tmpl, "Inputs", "name, items")
+	if len(tmpl.Inputs) != 2 || tmpl.Inputs[1].Name != "items" {
+		t.Errorf("Expected the template ports to follow its inputs, got %+v", tmpl.Inputs)
+	}
+
+	path := filepath.Join(t.TempDir(), "shared.yaml")
+	saveSharedFlow(t, path)
+	call := g.AddCallFlowCard(0, 600)
+	set(call, "Flow", path)
+	if call.LastError != "" || len(call.Inputs) != 1 || call.Inputs[0].Name != "text" {
+		t.Errorf("Expected the called flow's ports, got %+v (%s)", call.Inputs, call.LastError)
+	}
+}
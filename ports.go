@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"card-flows/engine"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// variadicPorts lists the card types whose inputs users add, remove and rename,
+// with the base name of the numbered ports added with "+"
+var variadicPorts = map[string]string{
+	"concat":   "text",
+	"merge":    "value",
+	"template": "data",
+}
+
+// AddConcatCard adds a card joining its inputs into one text, in port order.
+// More inputs are added with the "+" under the ports.
+func (g *Game) AddConcatCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "concat",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth,
+		Height: DefaultCardHeight,
+		Color:  ColorCardDefault,
+		Title:  "Text:concat",
+		Inputs: []Port{
+			{Name: "text_1", Type: "any"},
+			{Name: "text_2", Type: "any"},
+		},
+		Outputs: []Port{
+			{Name: "text", Type: "string"},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// concatScript joins the values global, which holds the card's inputs in port
+// order, skipping unconnected ones
+const concatScript = "text = separator.join([str(v) for v in values if v != None])\n"
+
+// reservedGlobals are script globals set by the engine rather than by a port
+var reservedGlobals = []string{"vars", "values"}
+
+// concatValues returns the inputs of a concat card in port order, None for
+// unconnected ports
+func concatValues(c *Card, inputs map[string]interface{}) []interface{} {
+	values := make([]interface{}, len(c.Inputs))
+	for i, p := range c.Inputs {
+		values[i] = inputs[p.Name]
+	}
+	return values
+}
+
+// IsVariadic reports whether users can add and remove the card's input ports
+func (c *Card) IsVariadic() bool {
+	_, ok := variadicPorts[c.Type]
+	return ok
+}
+
+// AddInputPort adds the next numbered input port to a variadic card
+func (c *Card) AddInputPort() string {
+	base := variadicPorts[c.Type]
+	name := ""
+	for i := len(c.Inputs) + 1; name == "" || c.hasInput(name); i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	c.Inputs = append(c.Inputs, Port{Name: name, Type: "any"})
+	c.Height = math.Max(c.Height, HeaderHeight+FooterHeight+float64(len(c.Inputs)+1)*PortRowHeight)
+	c.portsChanged()
+	return name
+}
+
+func (c *Card) hasInput(name string) bool {
+	return slices.ContainsFunc(c.Inputs, func(p Port) bool { return p.Name == name })
+}
+
+// RenameInputPort renames an input port of a variadic card, keeping its wires.
+// An empty name removes the port, which is only allowed while nothing is wired to it.
+func (g *Game) RenameInputPort(c *Card, old, name string) error {
+	i := slices.IndexFunc(c.Inputs, func(p Port) bool { return p.Name == old })
+	if i < 0 || name == old {
+		return nil
+	}
+	if name == "" {
+		if g.IsInputPortConnected(c.ID, old) {
+			return fmt.Errorf("disconnect %s before removing it", old)
+		}
+		if len(c.Inputs) == 1 {
+			return fmt.Errorf("the card needs at least one input")
+		}
+		c.Inputs = slices.Delete(c.Inputs, i, i+1)
+		c.portsChanged()
+		return nil
+	}
+	if !templateInputName.MatchString(name) {
+		return fmt.Errorf("port name %q must start with a letter and use only letters, digits and _", name)
+	}
+	if c.hasInput(name) {
+		return fmt.Errorf("the card already has an input %q", name)
+	}
+	// Inputs are script globals, so they must not hide the language or the
+	// card's settings
+	if _, ok := c.ResolvedParams()[name]; ok || engine.IsReservedName(name) || slices.Contains(reservedGlobals, name) {
+		return fmt.Errorf("%q is a reserved name", name)
+	}
+	c.Inputs[i].Name = name
+	for _, a := range g.arrows {
+		if a.ToCardID == c.ID && a.ToPort == old {
+			g.UnregisterSubscription(a.FromCardID, c.ID, old)
+			a.ToPort = name
+			g.RegisterSubscription(a.FromCardID, c.ID, name)
+		}
+	}
+	c.portsChanged()
+	return nil
+}
+
+// portsChanged keeps settings that list the input names in step with the ports
+func (c *Card) portsChanged() {
+	if c.Type == "template" {
+		names := make([]string, len(c.Inputs))
+		for i, p := range c.Inputs {
+			names[i] = p.Name
+		}
+		c.setParams(map[string]interface{}{"inputs": strings.Join(names, ", ")})
+	}
+}
+
+// addPortButton returns the world rectangle of the "+" button under the input ports
+func (c *Card) addPortButton() (x, y, w, h float64) {
+	footer := 0.0
+	if len(c.Outputs) > 0 {
+		footer = FooterHeight
+	}
+	return c.X + 4, c.Y + c.Height - footer - PortButtonSize - 4, PortButtonSize, PortButtonSize
+}
+
+// variadicActionAt returns "port_add" over the "+" button, "port_rename" over an
+// input port label, or ""
+func (c *Card) variadicActionAt(wx, wy float64) string {
+	if !c.IsVariadic() {
+		return ""
+	}
+	if x, y, w, h := c.addPortButton(); wx >= x && wx <= x+w && wy >= y && wy <= y+h {
+		return "port_add"
+	}
+	if c.inputLabelAt(wx, wy) >= 0 {
+		return "port_rename"
+	}
+	return ""
+}
+
+// inputLabelAt returns the index of the input port whose label is at the world
+// position, or -1
+func (c *Card) inputLabelAt(wx, wy float64) int {
+	for i, p := range c.Inputs {
+		px, py := c.GetInputPortPosition(p.Name)
+		if wx >= px+PortSize && wx <= px+PortSize+PortLabelWidth && wy >= py-PortRowHeight/2 && wy <= py+PortRowHeight/2 {
+			return i
+		}
+	}
+	return -1
+}
+
+// editedPort returns the input port being renamed, or nil
+func (c *Card) editedPort() *Port {
+	if c.portEdit <= 0 || c.portEdit > len(c.Inputs) || !c.IsVariadic() {
+		return nil
+	}
+	return &c.Inputs[c.portEdit-1]
+}
+
+// commitPortEdit applies the name typed into a port label
+func (g *Game) commitPortEdit(c *Card) {
+	p := c.editedPort()
+	if p == nil {
+		return
+	}
+	if err := g.RenameInputPort(c, p.Name, strings.TrimSpace(c.portDraft)); err != nil {
+		log.Println("port not renamed:", err)
+		c.LastErrorFlash = time.Now()
+	}
+	c.portEdit, c.portDraft = 0, ""
+}
+
+// drawAddPortButton draws the "+" under the input ports of a variadic card
+func (c *Card) drawAddPortButton(screen *ebiten.Image, g *Game, cw, ch float64) {
+	zoom := g.camera.Zoom
+	x, y, w, h := c.addPortButton()
+	sx, sy := g.camera.WorldToScreen(x, y, cw, ch)
+	vector.DrawFilledRect(screen, float32(sx), float32(sy), float32(w*zoom), float32(h*zoom), ColorButtonBackground, false)
+	DrawTextLines(screen, g.FontFace, "+", int(sx+3*zoom), int(sy-2*zoom), ColorPortLabel)
+}
+
+// portLabel returns the text shown next to an input port, with the typed name
+// and a cursor while it is being renamed
+func (c *Card) portLabel(g *Game, i int) string {
+	p := c.Inputs[i]
+	if g.input != nil && g.input.EditingCard == c && c.editedPort() != nil && c.portEdit == i+1 {
+		label := c.portDraft
+		if (time.Now().UnixMilli()/CursorBlinkRate)%2 == 0 {
+			label += "|"
+		}
+		return label
+	}
+	label := fmt.Sprintf("%s:%s", p.Name, p.Type)
+	if p.Multi {
+		label += "[]"
+	}
+	return label
+}
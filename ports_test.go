@@ -0,0 +1,91 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestVariadicPorts(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	first := g.AddTextCard(100, 100)
+	first.Text = "Hello"
+	second := g.AddTextCard(300, 100)
+	second.Text = "World"
+	concat := g.AddConcatCard(200, 300)
+	concat.setParams(map[string]interface{}{"separator": " "})
+
+	if name := concat.AddInputPort(); name != "text_3" {
+		t.Errorf("Expected the new port to be text_3, got %s", name)
+	}
+	g.Connect(first, "text", concat, "text_1")
+	g.Connect(second, "text", concat, "text_3")
+	g.engine.Run()
+	if got := g.engine.Memory[concat.ID+":text"]; got != "Hello World" {
+		t.Errorf("Expected the unconnected port to be skipped, got %v (error %q)", got, concat.LastError)
+	}
+
+	// Renaming keeps the wire; a wired port cannot be removed
+	if err := g.RenameInputPort(concat, "text_3", "name"); err != nil {
+		t.Fatal(err)
+	}
+	if !g.IsInputPortConnected(concat.ID, "name") || g.IsInputPortConnected(concat.ID, "text_3") {
+		t.Errorf("Expected the wire to follow the renamed port")
+	}
+	if err := g.RenameInputPort(concat, "name", ""); err == nil {
+		t.Errorf("Expected removing a wired port to fail")
+	}
+	if err := g.RenameInputPort(concat, "name", "text_1"); err == nil {
+		t.Errorf("Expected a duplicate name to fail")
+	}
+	for _, name := range []string{"for", "str", "separator", "vars", "values"} {
+		if err := g.RenameInputPort(concat, "text_1", name); err == nil {
+			t.Errorf("Expected the reserved name %q to fail", name)
+		}
+	}
+	if err := g.RenameInputPort(concat, "text_2", ""); err != nil || len(concat.Inputs) != 2 {
+		t.Errorf("Expected the unwired port to be removed, got %v %v", err, concat.Inputs)
+	}
+
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	if err := SaveState(g, path); err != nil {
+		t.Fatal(err)
+	}
+	g2 := NewGame()
+	if err := LoadState(g2, path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := g2.getCardByID(concat.ID)
+	if loaded == nil || len(loaded.Inputs) != 2 || loaded.Inputs[1].Name != "name" {
+		t.Fatalf("Expected the ports to be saved, got %+v", loaded)
+	}
+	g2.engine.Run()
+	if got := g2.engine.Memory[concat.ID+":text"]; got != "Hello World" {
+		t.Errorf("Expected the loaded card to run, got %v", got)
+	}
+
+	// The inputs are joined in port order whatever their names
+	if err := g2.RenameInputPort(loaded, "text_1", "zzz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g2.RenameInputPort(loaded, "name", "aaa"); err != nil {
+		t.Fatal(err)
+	}
+	g2.engine.Run()
+	if got := g2.engine.Memory[concat.ID+":text"]; got != "Hello World" {
+		t.Errorf("Expected the renamed ports to keep their order, got %v (error %q)", got, loaded.LastError)
+	}
+}
+
+func TestTemplatePortsFollowInputsParam(t *testing.T) {
+	g := NewGame()
+	tmpl := g.AddTemplateCard(0, 0)
+	tmpl.AddInputPort()
+	if err := g.RenameInputPort(tmpl, "data_2", "title"); err != nil {
+		t.Fatal(err)
+	}
+	if got := tmpl.Param("inputs"); got != "data, title" {
+		t.Errorf("Expected the inputs setting to list the ports, got %v", got)
+	}
+}
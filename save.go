@@ -16,22 +16,30 @@ type ColorState struct {
 }
 
 type PortState struct {
-	Name string `yaml:"name"`
-	Type string `yaml:"type"`
+	Name    string `yaml:"name"`
+	Type    string `yaml:"type"`
+	Lateral bool   `yaml:"lateral,omitempty"`
+	Multi   bool   `yaml:"multi,omitempty"`
 }
 
 type CardState struct {
-	ID      string      `yaml:"id"`
-	Type    string      `yaml:"type"`
-	X       float64     `yaml:"x"`
-	Y       float64     `yaml:"y"`
-	Width   float64     `yaml:"width"`
-	Height  float64     `yaml:"height"`
-	Color   ColorState  `yaml:"color"`
-	Title   string      `yaml:"title"`
-	Text    string      `yaml:"text"`
-	Inputs  []PortState `yaml:"inputs"`
-	Outputs []PortState `yaml:"outputs"`
+	ID          string                 `yaml:"id"`
+	Type        string                 `yaml:"type"`
+	X           float64                `yaml:"x"`
+	Y           float64                `yaml:"y"`
+	Width       float64                `yaml:"width"`
+	Height      float64                `yaml:"height"`
+	Color       ColorState             `yaml:"color"`
+	Title       string                 `yaml:"title"`
+	Text        string                 `yaml:"text"`
+	Inputs      []PortState            `yaml:"inputs"`
+	Outputs     []PortState            `yaml:"outputs"`
+	Params      map[string]interface{} `yaml:"params,omitempty"`
+	Cells       [][]string             `yaml:"cells,omitempty"`
+	HidePreview bool                   `yaml:"hide_preview,omitempty"`
+	Markdown    bool                   `yaml:"markdown,omitempty"`
+	AttachedTo  string                 `yaml:"attached_to,omitempty"`
+	Subflow     *SubflowState          `yaml:"subflow,omitempty"`
 }
 
 type CameraState struct {
@@ -47,24 +55,68 @@ type ArrowState struct {
 	ToPort     string `yaml:"to_port"`
 }
 
+type FrameState struct {
+	ID     string  `yaml:"id"`
+	Title  string  `yaml:"title"`
+	X      float64 `yaml:"x"`
+	Y      float64 `yaml:"y"`
+	Width  float64 `yaml:"width"`
+	Height float64 `yaml:"height"`
+	Color  string  `yaml:"color"`
+}
+
 type AppState struct {
-	Cards  []CardState  `yaml:"cards"`
-	Arrows []ArrowState `yaml:"arrows"`
-	Camera CameraState  `yaml:"camera"`
+	Cards         []CardState  `yaml:"cards"`
+	Arrows        []ArrowState `yaml:"arrows"`
+	Frames        []FrameState `yaml:"frames,omitempty"`
+	Camera        CameraState  `yaml:"camera"`
+	HidePreviews  bool         `yaml:"hide_previews,omitempty"`
+	HideInspector bool         `yaml:"hide_inspector,omitempty"`
+	Variables     *Variables   `yaml:"variables,omitempty"`
 }
 
 func SaveState(g *Game, filename string) error {
+	cards, arrows, frames := g.rootFlow()
 	state := AppState{
+		Cards:         cardStates(cards),
+		Arrows:        arrowStates(arrows),
+		Frames:        frameStates(frames),
+		HidePreviews:  g.hideGhostPreviews,
+		HideInspector: g.inspector.Hidden,
 		Camera: CameraState{
 			X:    g.camera.X,
 			Y:    g.camera.Y,
 			Zoom: g.camera.Zoom,
 		},
 	}
+	if v := g.variables; v != nil && (len(v.Values) > 0 || len(v.Profiles) > 0) {
+		state.Variables = v
+	}
+	if len(g.views) > 0 {
+		// Save the view of the top-level flow, not of the open composite
+		state.Camera = CameraState{X: g.views[0].camera.X, Y: g.views[0].camera.Y, Zoom: g.views[0].camera.Zoom}
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := yaml.NewEncoder(f)
+	enc.SetIndent(2)
+	err = enc.Encode(&state)
+	if err != nil {
+		return err
+	}
+	return enc.Close()
+}
 
-	for _, c := range g.cards {
-		r, g_val, b, a := c.Color.RGBA()
-		cardState := CardState{
+func cardStates(cards []*Card) []CardState {
+	var states []CardState
+	for _, c := range cards {
+		r, g, b, a := c.Color.RGBA()
+		cs := CardState{
 			ID:     c.ID,
 			Type:   c.Type,
 			X:      c.X,
@@ -73,44 +125,60 @@ func SaveState(g *Game, filename string) error {
 			Height: c.Height,
 			Color: ColorState{
 				R: uint8(r >> 8),
-				G: uint8(g_val >> 8),
+				G: uint8(g >> 8),
 				B: uint8(b >> 8),
 				A: uint8(a >> 8),
 			},
-			Title: c.Title,
-			Text:  c.Text,
+			Title:       c.Title,
+			Text:        c.Text,
+			Params:      c.savedParams(),
+			Cells:       c.Cells,
+			HidePreview: c.HidePreview,
+			Markdown:    c.Markdown,
+			AttachedTo:  c.AttachedTo,
 		}
 		for _, p := range c.Inputs {
-			cardState.Inputs = append(cardState.Inputs, PortState{Name: p.Name, Type: p.Type})
+			cs.Inputs = append(cs.Inputs, PortState{Name: p.Name, Type: p.Type, Multi: p.Multi})
 		}
 		for _, p := range c.Outputs {
-			cardState.Outputs = append(cardState.Outputs, PortState{Name: p.Name, Type: p.Type})
+			cs.Outputs = append(cs.Outputs, PortState{Name: p.Name, Type: p.Type, Lateral: p.Lateral})
+		}
+		if c.Subflow != nil {
+			sub := subflowState(c.Subflow)
+			cs.Subflow = &sub
 		}
-		state.Cards = append(state.Cards, cardState)
+		states = append(states, cs)
 	}
+	return states
+}
 
-	for _, arrow := range g.arrows {
-		state.Arrows = append(state.Arrows, ArrowState{
+func arrowStates(arrows []*Arrow) []ArrowState {
+	var states []ArrowState
+	for _, arrow := range arrows {
+		states = append(states, ArrowState{
 			FromCardID: arrow.FromCardID,
 			FromPort:   arrow.FromPort,
 			ToCardID:   arrow.ToCardID,
 			ToPort:     arrow.ToPort,
 		})
 	}
+	return states
+}
 
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	enc := yaml.NewEncoder(f)
-	enc.SetIndent(2)
-	err = enc.Encode(&state)
-	if err != nil {
-		return err
+func frameStates(frames []*Frame) []FrameState {
+	var states []FrameState
+	for _, f := range frames {
+		states = append(states, FrameState{
+			ID:     f.ID,
+			Title:  f.Title,
+			X:      f.X,
+			Y:      f.Y,
+			Width:  f.Width,
+			Height: f.Height,
+			Color:  f.Color,
+		})
 	}
-	return enc.Close()
+	return states
 }
 
 func LoadState(g *Game, filename string) error {
@@ -127,11 +195,30 @@ func LoadState(g *Game, filename string) error {
 	g.camera.X = state.Camera.X
 	g.camera.Y = state.Camera.Y
 	g.camera.Zoom = state.Camera.Zoom
+	g.hideGhostPreviews = state.HidePreviews
+	g.inspector.Hidden = state.HideInspector
+	g.variables = state.Variables
+	if g.variables == nil {
+		g.variables = &Variables{}
+	}
 
-	g.cards = nil
-	g.arrows = nil
+	// Loading always returns to the top-level flow
+	if len(g.views) > 0 {
+		g.engine = g.rootEngine()
+		g.engine.game = g
+		g.views = nil
+	}
+	g.selection = nil
+	g.cards = cardsFromStates(state.Cards)
+	g.arrows = arrowsFromStates(state.Arrows, g.cards)
+	g.frames = framesFromStates(state.Frames)
 
-	for _, cs := range state.Cards {
+	return nil
+}
+
+func cardsFromStates(states []CardState) []*Card {
+	var cards []*Card
+	for _, cs := range states {
 		id := cs.ID
 		if id == "" {
 			id = NewID()
@@ -148,21 +235,26 @@ func LoadState(g *Game, filename string) error {
 		}
 
 		card := &Card{
-			ID:     id,
-			Type:   cardType,
-			X:      cs.X,
-			Y:      cs.Y,
-			Width:  cs.Width,
-			Height: cs.Height,
-			Color:  color.RGBA{cs.Color.R, cs.Color.G, cs.Color.B, cs.Color.A},
-			Title:  cs.Title,
-			Text:   cs.Text,
+			ID:          id,
+			Type:        cardType,
+			X:           cs.X,
+			Y:           cs.Y,
+			Width:       cs.Width,
+			Height:      cs.Height,
+			Color:       color.RGBA{cs.Color.R, cs.Color.G, cs.Color.B, cs.Color.A},
+			Title:       cs.Title,
+			Text:        cs.Text,
+			Params:      cs.Params,
+			Cells:       cs.Cells,
+			HidePreview: cs.HidePreview,
+			Markdown:    cs.Markdown,
+			AttachedTo:  cs.AttachedTo,
 		}
 		for _, ps := range cs.Inputs {
-			card.Inputs = append(card.Inputs, Port{Name: ps.Name, Type: ps.Type})
+			card.Inputs = append(card.Inputs, Port{Name: ps.Name, Type: ps.Type, Multi: ps.Multi})
 		}
 		for _, ps := range cs.Outputs {
-			card.Outputs = append(card.Outputs, Port{Name: ps.Name, Type: ps.Type})
+			card.Outputs = append(card.Outputs, Port{Name: ps.Name, Type: ps.Type, Lateral: ps.Lateral})
 		}
 
 		// Migration: Ensure Text Cards have the default output if missing
@@ -170,18 +262,39 @@ func LoadState(g *Game, filename string) error {
 			card.Outputs = append(card.Outputs, Port{Name: "text", Type: "string"})
 		}
 
-		g.cards = append(g.cards, card)
+		// Migration: find_replace cards saved before count/matches outputs existed
+		if card.Type == "find_replace" && len(card.Outputs) == 1 {
+			card.Outputs = append(card.Outputs,
+				Port{Name: "count", Type: "int"},
+				Port{Name: "matches", Type: "list"},
+			)
+		}
+
+		// Grids edited by hand may have rows shorter than the header
+		if card.Type == "grid" {
+			card.padGridRows()
+		}
+
+		if cs.Subflow != nil {
+			card.Subflow = subflowFromState(*cs.Subflow)
+		}
+
+		cards = append(cards, card)
 	}
+	return cards
+}
 
-	// Load Arrows
+// arrowsFromStates loads the arrows between the cards. Arrows from the inputs of a
+// composite card start at subflowInputID rather than a card.
+func arrowsFromStates(states []ArrowState, cards []*Card) []*Arrow {
 	// Note: If IDs changed during load (due to empty IDs), arrows will be broken.
 	// We filter out any arrows that point to non-existent cards to prevent "Miss Draw" errors.
 	validArrows := []*Arrow{}
-	for _, as := range state.Arrows {
+	for _, as := range states {
 		// Check validity
-		fromExists := false
+		fromExists := as.FromCardID == subflowInputID
 		toExists := false
-		for _, c := range g.cards {
+		for _, c := range cards {
 			if c.ID == as.FromCardID {
 				fromExists = true
 			}
@@ -204,7 +317,17 @@ func LoadState(g *Game, filename string) error {
 			// fmt.Printf("Dropping invalid arrow: %s->%s\n", as.FromCardID, as.ToCardID)
 		}
 	}
-	g.arrows = validArrows
+	return validArrows
+}
 
-	return nil
+func framesFromStates(states []FrameState) []*Frame {
+	var frames []*Frame
+	for _, fs := range states {
+		f := &Frame{ID: fs.ID, Title: fs.Title, X: fs.X, Y: fs.Y, Width: fs.Width, Height: fs.Height, Color: fs.Color}
+		if _, ok := frameColorValues[f.Color]; !ok {
+			f.Color = FrameColors[0]
+		}
+		frames = append(frames, f)
+	}
+	return frames
 }
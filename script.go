@@ -0,0 +1,22 @@
+package main
+
+import "fmt"
+
+// EjectToScript turns a form-configured card into a script card running the
+// Starlark its form generated, keeping the card's ports and wires.
+func (g *Game) EjectToScript(c *Card) error {
+	script, err := g.engine.getCardScript(c)
+	if err != nil {
+		return err
+	}
+	if script == "" {
+		return fmt.Errorf("%s cards have no script to eject", c.Type)
+	}
+	c.Type = "script"
+	c.Title = "Script"
+	c.Text = script
+	c.Params = nil
+	c.LastError = ""
+	delete(g.engine.ExecutionCache, c.ID)
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"maps"
+
+	"card-flows/secrets"
+)
+
+// secretStore holds the values of the secrets that card parameters name
+var secretStore = secrets.Open(secrets.DefaultPath())
+
+// resolveSecret returns the value of the secret a parameter names. Flows only
+// save the name, so sharing a flow file never shares the key.
+func resolveSecret(spec ParamSpec, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	v, ok, err := secretStore.Lookup(name)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", spec.Label, err)
+	}
+	if !ok {
+		return "", fmt.Errorf("%s: secret %q is not set; set %s or run with -set-secret %s", spec.Label, name, secrets.EnvName(name), name)
+	}
+	return v, nil
+}
+
+// secretDigest fingerprints the values of the secrets a card uses, so that a
+// changed key reruns the card without the key itself entering the cache
+func (c *Card) secretDigest() string {
+	h := sha256.New()
+	for _, spec := range cardParamSpecs[c.Type] {
+		if spec.Kind != ParamSecret {
+			continue
+		}
+		v, _, _ := secretStore.Lookup(c.ParamText(spec.Name))
+		fmt.Fprintf(h, "%s=%s\n", spec.Name, v)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// hasSecrets reports whether any parameter of the card names a secret
+func (c *Card) hasSecrets() bool {
+	for _, spec := range cardParamSpecs[c.Type] {
+		if spec.Kind == ParamSecret {
+			return true
+		}
+	}
+	return false
+}
+
+// secretLabel shows a secret parameter as its name with the value masked
+func secretLabel(name string) string {
+	if name == "" {
+		return ""
+	}
+	if _, ok, _ := secretStore.Lookup(name); !ok {
+		return name + " (not set)"
+	}
+	return name + " " + secrets.Mask
+}
+
+// savedParams returns the parameters written to the flow file. A secret
+// parameter is written only while it names a secret that is set, or refers to a
+// variable, so that a key typed in its place stays out of the file even when it
+// is shaped like a name.
+func (c *Card) savedParams() map[string]interface{} {
+	params := c.Params
+	for _, spec := range cardParamSpecs[c.Type] {
+		s, _ := c.Params[spec.Name].(string)
+		if spec.Kind != ParamSecret || s == "" || varReference.MatchString(s) {
+			continue
+		}
+		if secrets.ValidName(s) {
+			// Keep names while the store cannot be read, rather than lose them
+			if _, ok, err := secretStore.Lookup(s); ok || err != nil {
+				continue
+			}
+		}
+		params = maps.Clone(params)
+		delete(params, spec.Name)
+	}
+	return params
+}
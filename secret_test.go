@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"card-flows/secrets"
+)
+
+// useSecrets points the secret store at an empty file for the test, and gives
+// script cards an api_key secret parameter
+func useSecrets(t *testing.T) {
+	t.Setenv(secrets.KeyEnv, "")
+	saved := secretStore
+	secretStore = secrets.Open(filepath.Join(t.TempDir(), "secrets.enc"))
+	cardParamSpecs["script"] = []ParamSpec{{Name: "api_key", Label: "API key", Kind: ParamSecret}}
+	t.Cleanup(func() {
+		secretStore = saved
+		delete(cardParamSpecs, "script")
+	})
+}
+
+func TestSecretParams(t *testing.T) {
+	useSecrets(t)
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	script := &Card{
+		ID:      NewID(),
+		Type:    "script",
+		Color:   ColorCardDefault,
+		Title:   "Script",
+		Text:    `result = "key " + api_key`,
+		Outputs: []Port{{Name: "result", Type: "any"}},
+		Params:  map[string]interface{}{"api_key": "service_key"},
+	}
+	g.cards = append(g.cards, script)
+
+	g.engine.Run()
+	if !strings.Contains(script.LastError, `secret "service_key" is not set`) {
+		t.Errorf("Expected a missing secret to be reported, got %q", script.LastError)
+	}
+
+	if err := secretStore.Set("service_key", "sk-live-5678"); err != nil {
+		t.Fatal(err)
+	}
+	g.engine.Run()
+	if got := g.engine.Memory[script.ID+":result"]; got != "key sk-live-5678" {
+		t.Errorf("Expected the script to get the secret's value, got %v (error %q)", got, script.LastError)
+	}
+	if lines := ghostPreviewLines(g.engine.Memory[script.ID+":result"]); lines[0] != "key "+secrets.Mask {
+		t.Errorf("Expected the preview to mask the secret, got %v", lines)
+	}
+
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	if err := SaveState(g, path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "sk-live") || !strings.Contains(string(data), "service_key") {
+		t.Errorf("Expected only the secret's name in the saved flow:\n%s", data)
+	}
+
+	// A value pasted where the name belongs is refused
+	script.Params["api_key"] = "sk-live-5678"
+	g.engine.Run()
+	if !strings.Contains(script.LastError, "must name a secret") {
+		t.Errorf("Expected a pasted key to be refused, got %q", script.LastError)
+	}
+	if err := SaveState(g, path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "sk-live") {
+		t.Errorf("Expected the pasted key to be left out of the saved flow:\n%s", data)
+	}
+}
+
+func TestSecretNameSavedOnlyWhenSet(t *testing.T) {
+	useSecrets(t)
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	script := &Card{ID: NewID(), Type: "script", Color: ColorCardDefault, Title: "Script", Params: map[string]interface{}{"api_key": "ghp_0123456789abcdef"}}
+	g.cards = append(g.cards, script)
+
+	// A token shaped like a name is not the name of a set secret
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	if err := SaveState(g, path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "ghp_") {
+		t.Errorf("Expected the token to be left out of the saved flow:\n%s", data)
+	}
+
+	script.Params["api_key"] = "github_token"
+	t.Setenv(secrets.EnvName("github_token"), "ghp_0123456789abcdef")
+	if err := SaveState(g, path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "github_token") {
+		t.Errorf("Expected the name of a set secret to be saved:\n%s", data)
+	}
+}
@@ -0,0 +1,258 @@
+// Package secrets keeps the API keys and passwords that cards refer to by name.
+// Values come from environment variables or from a local file encrypted with
+// AES-GCM, so that flow files only ever hold the names. A passphrase for the file
+// is stretched into its key with argon2id and a random salt kept in the file.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// KeyEnv names the environment variable holding a passphrase for the file.
+// Without it a random key is kept in a file beside the secrets.
+const KeyEnv = "CARD_FLOWS_SECRETS_KEY"
+
+// EnvPrefix starts the environment variables that secrets are read from, so that
+// a flow can only name variables meant for it
+const EnvPrefix = "CARD_FLOWS_SECRET_"
+
+// The argon2id cost of deriving the file key from a passphrase
+const (
+	saltSize     = 16
+	argonTime    = 1
+	argonMemory  = 64 * 1024 // KiB
+	argonThreads = 4
+)
+
+// Mask replaces secret values in text shown to the user
+const Mask = "••••••"
+
+// minRedactLength is the length below which values are not masked in text,
+// as short ones would mask unrelated words
+const minRedactLength = 4
+
+var validName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidName reports whether a secret name can be used. Names are identifiers,
+// so a key pasted where its name belongs is refused rather than saved.
+func ValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
+// EnvName returns the environment variable that overrides a secret, its name in
+// upper case after EnvPrefix: openai_api_key is read from
+// CARD_FLOWS_SECRET_OPENAI_API_KEY
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(name)
+}
+
+// Store is an encrypted secrets file. It remembers the values it hands out so
+// they can be masked wherever text is shown or logged.
+type Store struct {
+	Path    string // the encrypted file
+	KeyPath string // the random key, used when KeyEnv is not set
+
+	mu     sync.Mutex
+	values map[string]string // file contents, read on first use
+	seen   []string          // values looked up so far, longest first
+}
+
+// Open returns the store kept in the file at path. Nothing is read until a
+// secret is looked up.
+func Open(path string) *Store {
+	return &Store{Path: path, KeyPath: path + ".key"}
+}
+
+// DefaultPath returns the secrets file in the user's config directory
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "card-flows", "secrets.enc")
+}
+
+// Lookup returns the value of a secret from the environment or the file, and
+// whether it is set
+func (s *Store) Lookup(name string) (string, bool, error) {
+	if !ValidName(name) {
+		return "", false, fmt.Errorf("secret name %q must start with a letter and use only letters, digits and _", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := os.LookupEnv(EnvName(name))
+	if !ok {
+		if err := s.load(); err != nil {
+			return "", false, err
+		}
+		v, ok = s.values[name]
+	}
+	if ok {
+		s.remember(v)
+	}
+	return v, ok, nil
+}
+
+// Set stores a secret in the file, creating the file and its key if needed
+func (s *Store) Set(name, value string) error {
+	if !ValidName(name) {
+		return fmt.Errorf("secret name %q must start with a letter and use only letters, digits and _", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.values[name] = value
+	return s.save()
+}
+
+// Delete removes a secret from the file
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	delete(s.values, name)
+	return s.save()
+}
+
+// Names returns the secrets stored in the file, sorted
+func (s *Store) Names() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(s.values))
+	for name := range s.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Redact masks the secret values looked up so far in a text
+func (s *Store) Redact(text string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.seen {
+		text = strings.ReplaceAll(text, v, Mask)
+	}
+	return text
+}
+
+func (s *Store) remember(v string) {
+	if len(v) < minRedactLength || slices.Contains(s.seen, v) {
+		return
+	}
+	s.seen = append(s.seen, v)
+	// Longer values first, so one containing another is masked whole
+	sort.Slice(s.seen, func(i, j int) bool { return len(s.seen[i]) > len(s.seen[j]) })
+}
+
+// load reads and decrypts the file once; a missing file is an empty store
+func (s *Store) load() error {
+	if s.values != nil {
+		return nil
+	}
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.values = map[string]string{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(data) < saltSize {
+		return fmt.Errorf("%s is damaged", filepath.Base(s.Path))
+	}
+	salt, data := data[:saltSize], data[saltSize:]
+	gcm, err := s.cipher(salt, false)
+	if err != nil {
+		return err
+	}
+	if len(data) < gcm.NonceSize() {
+		return fmt.Errorf("%s is damaged", filepath.Base(s.Path))
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt %s: wrong key?", filepath.Base(s.Path))
+	}
+	values := map[string]string{}
+	if err := json.Unmarshal(plain, &values); err != nil {
+		return err
+	}
+	s.values = values
+	return nil
+}
+
+// save writes the file as a fresh salt, the nonce and the encrypted values
+func (s *Store) save() error {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	gcm, err := s.cipher(salt, true)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(s.values)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path, append(salt, gcm.Seal(nonce, nonce, plain, nil)...), 0o600)
+}
+
+// cipher returns the AES-GCM cipher of the store, deriving the key from the
+// passphrase and salt, or else creating the key file when asked to
+func (s *Store) cipher(salt []byte, create bool) (cipher.AEAD, error) {
+	var key []byte
+	if pass := os.Getenv(KeyEnv); pass != "" {
+		key = argon2.IDKey([]byte(pass), salt, argonTime, argonMemory, argonThreads, 32)
+	} else {
+		var err error
+		key, err = os.ReadFile(s.KeyPath)
+		if errors.Is(err, fs.ErrNotExist) && create {
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+			if err := os.MkdirAll(filepath.Dir(s.KeyPath), 0o700); err != nil {
+				return nil, err
+			}
+			err = os.WriteFile(s.KeyPath, key, 0o600)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading the secrets key: %w", err)
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,92 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	t.Setenv(KeyEnv, "")
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	s := Open(path)
+	if err := s.Set("api_key", "sk-test-1234"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-test-1234") || strings.Contains(string(data), "api_key") {
+		t.Error("Expected the file to be encrypted")
+	}
+
+	// A fresh store reads the file with the saved key
+	v, ok, err := Open(path).Lookup("api_key")
+	if err != nil || !ok || v != "sk-test-1234" {
+		t.Errorf("Expected the stored value, got %q %v %v", v, ok, err)
+	}
+	if _, ok, _ := Open(path).Lookup("other"); ok {
+		t.Error("Expected an unknown secret to be unset")
+	}
+	if names, _ := s.Names(); len(names) != 1 || names[0] != "api_key" {
+		t.Errorf("Unexpected names %v", names)
+	}
+	if err := s.Delete("api_key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := Open(path).Lookup("api_key"); ok {
+		t.Error("Expected the deleted secret to be gone")
+	}
+}
+
+func TestEnvironmentAndPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	t.Setenv(KeyEnv, "correct horse")
+	if err := Open(path).Set("token", "from-file"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".key"); err == nil {
+		t.Error("Expected no key file when a passphrase is set")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Open(path).Set("token", "from-file"); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(path); string(again[:saltSize]) == string(data[:saltSize]) {
+		t.Error("Expected each save to use a new salt")
+	}
+
+	t.Setenv("CARD_FLOWS_SECRET_TOKEN", "from-env")
+	t.Setenv("TOKEN", "unrelated")
+	if v, _, _ := Open(path).Lookup("token"); v != "from-env" {
+		t.Errorf("Expected the prefixed environment variable to win, got %q", v)
+	}
+	os.Unsetenv("CARD_FLOWS_SECRET_TOKEN")
+	if v, _, _ := Open(path).Lookup("token"); v != "from-file" {
+		t.Errorf("Expected other environment variables to be ignored, got %q", v)
+	}
+	t.Setenv(KeyEnv, "wrong")
+	if _, _, err := Open(path).Lookup("token"); err == nil {
+		t.Error("Expected a wrong passphrase to fail")
+	}
+}
+
+func TestRedactAndNames(t *testing.T) {
+	t.Setenv(EnvName("long_key"), "abcdefgh")
+	t.Setenv(EnvName("short"), "ab")
+	s := Open(filepath.Join(t.TempDir(), "secrets.enc"))
+	s.Lookup("long_key")
+	s.Lookup("short")
+	if got := s.Redact("key abcdefgh, tab"); got != "key "+Mask+", tab" {
+		t.Errorf("Unexpected redaction %q", got)
+	}
+	if _, _, err := s.Lookup("sk-1234"); err == nil {
+		t.Error("Expected a value pasted as a name to be refused")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"card-flows/engine"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// templateInputName matches port names usable in both template syntaxes
+var templateInputName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+func (g *Game) AddTemplateCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "template",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth * 2,
+		Height: DefaultCardHeight * 2,
+		Color:  ColorCardDefault,
+		Title:  "Text:template",
+		Text:   "{{range .data}}- {{.}}\n{{end}}",
+		Inputs: []Port{
+			{Name: "data", Type: "any"},
+		},
+		Outputs: []Port{
+			{Name: "text", Type: "string"},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// templateInputNames parses the comma-separated input port names of a template card
+func templateInputNames(spec string) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !templateInputName.MatchString(name) {
+			return nil, fmt.Errorf("input name %q must start with a letter and use only letters, digits and _", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("input %q is listed twice", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// setTemplateInputs stores the input names and rebuilds the input ports when they
+// are valid. Wires to a removed port are kept and reattach if the name comes back.
+func (c *Card) setTemplateInputs(spec string) {
+	c.setParams(map[string]interface{}{"inputs": spec})
+	names, err := templateInputNames(spec)
+	if err != nil {
+		return
+	}
+	c.Inputs = nil
+	for _, name := range names {
+		c.Inputs = append(c.Inputs, Port{Name: name, Type: "any"})
+	}
+}
+
+// executeTemplate fills the card's template with its inputs. Tables are passed as
+// lists of rows, and unconnected inputs are empty.
+func executeTemplate(c *Card, inputs map[string]interface{}) (map[string]interface{}, error) {
+	names, err := templateInputNames(fmt.Sprint(c.Param("inputs")))
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		data[name] = engine.TemplateValue(inputs[name])
+	}
+
+	var text string
+	switch syntax := fmt.Sprint(c.Param("syntax")); syntax {
+	case "go":
+		text, err = engine.RenderGoTemplate(c.Title, c.Text, data)
+	case "starlark":
+		var script string
+		script, err = engine.FStringScript(c.Text, "_text")
+		if err != nil {
+			break
+		}
+		var out map[string]interface{}
+		out, err = engine.ExecuteStarlark(c.Title, script, data)
+		if err == nil {
+			text, _ = out["_text"].(string)
+		}
+	default:
+		err = fmt.Errorf("unknown template syntax %q (use %s)", syntax, strings.Join(engine.TemplateSyntaxes, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"text": text}, nil
+}
+
+func (c *Card) templateFields(g *Game) []formField {
+	spec := fmt.Sprint(c.Param("inputs"))
+	_, err := templateInputNames(spec)
+	return []formField{
+		{Label: "Syntax", Value: fmt.Sprint(c.Param("syntax")), Options: engine.TemplateSyntaxes, Set: func(v string) {
+			c.setParams(map[string]interface{}{"syntax": v})
+		}},
+		{Label: "Inputs", Value: spec, Edit: true, Set: c.setTemplateInputs, Invalid: err != nil},
+	}
+}
+
+// templateTextTop returns the world y where the template text starts, below the
+// form and its error line
+func (c *Card) templateTextTop(g *Game) float64 {
+	_, y := c.formOrigin()
+	return y + float64(len(c.formFields(g))+1)*FormRowHeight
+}
+
+// templateTextAt reports whether the world position is on the template text
+func (c *Card) templateTextAt(g *Game, wx, wy float64) bool {
+	x, _ := c.formOrigin()
+	return wx >= x && wx <= c.X+c.Width && wy >= c.templateTextTop(g) && wy <= c.Y+c.Height-FooterHeight
+}
+
+func (c *Card) drawTemplateText(screen *ebiten.Image, g *Game, cw, ch float64) {
+	x, _ := c.formOrigin()
+	sx, sy := g.camera.WorldToScreen(x, c.templateTextTop(g), cw, ch)
+	text := c.Text
+	if g.input != nil && g.input.EditingCard == c && c.editedFormField(g) == nil &&
+		(time.Now().UnixMilli()/CursorBlinkRate)%2 == 0 {
+		text += "|"
+	}
+	DrawTextLines(screen, g.FontFace, text, int(sx), int(sy), color.White)
+}
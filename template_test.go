@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"card-flows/engine"
+)
+
+// newTemplateFlow builds a grid card wired into a template card's data input
+func newTemplateFlow(syntax, text string) (*Game, *Card) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+
+	grid := g.AddGridCard(100, 100)
+	grid.Cells = salesCells
+	tc := g.AddTemplateCard(100, 400)
+	tc.Text = text
+	tc.setParams(map[string]interface{}{"syntax": syntax})
+	wireCards(g, grid, "table", tc, "data")
+	return g, tc
+}
+
+func TestTemplateCardGoSyntax(t *testing.T) {
+	g, tc := newTemplateFlow("go", "{{range .data}}{{upper .region}} {{.quarter}}={{.total}}\n{{end}}")
+	g.engine.Run()
+	if tc.LastError != "" {
+		t.Fatalf("Unexpected error: %s", tc.LastError)
+	}
+	want := "NORTH Q1=10\nSOUTH Q1=5\nNORTH Q2=2.5\nNORTH Q1=1\nSOUTH Q2=\n"
+	if got := g.engine.Memory[tc.ID+":text"]; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// Editing the template reruns the card
+	tc.Text = "{{len .data}} rows"
+	g.engine.Run()
+	if got := g.engine.Memory[tc.ID+":text"]; got != "5 rows" {
+		t.Errorf("Expected the edited template to run, got %q", got)
+	}
+
+	tc.Text = "{{.missing}}"
+	g.engine.Run()
+	if !strings.Contains(tc.LastError, "missing") {
+		t.Errorf("Expected a missing key error, got %q", tc.LastError)
+	}
+}
+
+func TestTemplateCardStarlarkSyntax(t *testing.T) {
+	g, tc := newTemplateFlow("starlark", "{{{len(data)}}} regions: {\", \".join(sorted(set([r[\"region\"] for r in data])))}")
+	g.engine.Run()
+	if tc.LastError != "" {
+		t.Fatalf("Unexpected error: %s", tc.LastError)
+	}
+	if got, want := g.engine.Memory[tc.ID+":text"], "{5} regions: north, south"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestFStringScriptErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		"a }":         "single '}'",
+		"line\n{data": "line 2: '{' is never closed",
+		"{ }":         "empty {}",
+	} {
+		if _, err := engine.FStringScript(src, "_text"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected an error containing %q, got %v", src, want, err)
+		}
+	}
+}
+
+func TestTemplateInputs(t *testing.T) {
+	names, err := templateInputNames(" orders, customers ,")
+	if err != nil || !reflect.DeepEqual(names, []string{"orders", "customers"}) {
+		t.Errorf("Unexpected names %v, %v", names, err)
+	}
+	for _, spec := range []string{"a, a", "_hidden", "two words"} {
+		if _, err := templateInputNames(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	first := g.AddTextCard(100, 100)
+	first.Text = "Ann"
+	second := g.AddTextCard(100, 300)
+	second.Text = "Bob"
+	tc := g.AddTemplateCard(400, 100)
+	tc.Text = "{{.first}} & {{.second}}"
+	tc.setTemplateInputs("first, second")
+	if len(tc.Inputs) != 2 || tc.Inputs[1].Name != "second" {
+		t.Fatalf("Expected the ports to follow the input names, got %+v", tc.Inputs)
+	}
+	wireCards(g, first, "text", tc, "first")
+	wireCards(g, second, "text", tc, "second")
+	g.engine.Run()
+	if got := g.engine.Memory[tc.ID+":text"]; got != "Ann & Bob" {
+		t.Errorf("Expected both inputs to be filled in, got %q (%s)", got, tc.LastError)
+	}
+
+	// Invalid names are kept for editing but leave the ports alone
+	tc.setTemplateInputs("first, first")
+	if len(tc.Inputs) != 2 || !tc.templateFields(g)[1].Invalid {
+		t.Errorf("Expected the ports to be kept and the field flagged, got %+v", tc.Inputs)
+	}
+}
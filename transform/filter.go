@@ -0,0 +1,169 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FilterOps lists the supported comparison operators in form order
+var FilterOps = []string{"=", "!=", "<", "<=", ">", ">=", "contains", "starts with", "ends with", "is empty", "is not empty"}
+
+// Condition compares one column of each row with a value
+type Condition struct {
+	Column string
+	Op     string
+	Value  string // typed text; numbers compare numerically
+}
+
+// NeedsValue reports whether the operator takes a value
+func (c Condition) NeedsValue() bool {
+	return c.Op != "is empty" && c.Op != "is not empty"
+}
+
+// Filter keeps the rows matching all (or any) of its conditions
+type Filter struct {
+	Conditions []Condition
+	MatchAny   bool
+}
+
+// FilterFromParams reads a filter spec from card parameters
+func FilterFromParams(params map[string]interface{}) Filter {
+	f := Filter{MatchAny: fmt.Sprint(params["match"]) == "any"}
+	for _, m := range mapList(params["conditions"]) {
+		f.Conditions = append(f.Conditions, Condition{Column: str(m, "column"), Op: str(m, "op"), Value: str(m, "value")})
+	}
+	return f
+}
+
+// Params converts the spec back to card parameters
+func (f Filter) Params() map[string]interface{} {
+	conds := make([]interface{}, len(f.Conditions))
+	for i, c := range f.Conditions {
+		conds[i] = map[string]interface{}{"column": c.Column, "op": c.Op, "value": c.Value}
+	}
+	match := "all"
+	if f.MatchAny {
+		match = "any"
+	}
+	return map[string]interface{}{"conditions": conds, "match": match}
+}
+
+// Validate checks the spec against the input columns; nil columns skip the column checks
+func (f Filter) Validate(columns []string) error {
+	for _, c := range f.Conditions {
+		if !contains(FilterOps, c.Op) {
+			return fmt.Errorf("unknown operator %q (use %s)", c.Op, strings.Join(FilterOps, ", "))
+		}
+		if err := checkColumn(c.Column, columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Script returns Starlark that filters the table global input into output
+func (f Filter) Script(input, output string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Keep rows where %s\n", f.describe())
+	b.WriteString(prelude(input))
+	b.WriteString(`
+def _is_number(v):
+    return type(v) in ("int", "float")
+
+def _text(v):
+    return "" if v == None else str(v).lower()
+
+def _test(v, op, value):
+    if op == "is empty":
+        return v == None or v == ""
+    if op == "is not empty":
+        return v != None and v != ""
+    if op in ("=", "!="):
+        if _is_number(v) and _is_number(value):
+            equal = v == value
+        else:
+            equal = _text(v) == _text(value)
+        return equal if op == "=" else not equal
+    if op == "contains":
+        return _text(value) in _text(v)
+    if op == "starts with":
+        return _text(v).startswith(_text(value))
+    if op == "ends with":
+        return _text(v).endswith(_text(value))
+    # Ordering only compares numbers with numbers and text with text
+    if _is_number(v) and _is_number(value):
+        a, b = v, value
+    elif type(v) == "string" and type(value) == "string":
+        a, b = v.lower(), value.lower()
+    else:
+        return False
+    if op == "<":
+        return a < b
+    if op == "<=":
+        return a <= b
+    if op == ">":
+        return a > b
+    return a >= b
+`)
+
+	b.WriteString("\nconditions = [\n")
+	for _, c := range f.Conditions {
+		fmt.Fprintf(&b, "    (_column(%s), %s, %s),\n", strconv.Quote(c.Column), strconv.Quote(c.Op), literal(c.Value))
+	}
+	b.WriteString("]\n")
+
+	combine := "all"
+	if f.MatchAny {
+		combine = "any"
+	}
+	fmt.Fprintf(&b, `
+rows = []
+for row in %[1]s["rows"]:
+    if not conditions or %[2]s([_test(row[col], op, value) for col, op, value in conditions]):
+        rows.append(row)
+
+%[3]s = {"columns": %[1]s["columns"], "rows": rows}
+`, input, combine, output)
+	return b.String()
+}
+
+func (f Filter) describe() string {
+	if len(f.Conditions) == 0 {
+		return "(keep all rows)"
+	}
+	parts := make([]string, len(f.Conditions))
+	for i, c := range f.Conditions {
+		parts[i] = c.Column + " " + c.Op
+		if c.NeedsValue() {
+			parts[i] += " " + c.Value
+		}
+	}
+	join := " and "
+	if f.MatchAny {
+		join = " or "
+	}
+	return strings.Join(parts, join)
+}
+
+// literal converts typed form text to a Starlark literal, reading numbers as numbers
+func literal(s string) string {
+	trimmed := strings.TrimSpace(s)
+	if i, err := strconv.Atoi(trimmed); err == nil {
+		return strconv.Itoa(i)
+	}
+	if f, err := strconv.ParseFloat(trimmed, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return strconv.Quote(s)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AggregateOps lists the supported aggregations in form order
+var AggregateOps = []string{"sum", "count", "mean", "min", "max", "distinct"}
+
+// Aggregation summarizes one column of each group
+type Aggregation struct {
+	Column string
+	Op     string
+}
+
+// Name returns the output column name, e.g. "total_sum"
+func (a Aggregation) Name() string {
+	return a.Column + "_" + a.Op
+}
+
+// GroupBy groups rows by key columns and aggregates the others.
+// With a Pivot column, each distinct pivot value becomes a set of output columns.
+type GroupBy struct {
+	Keys         []string
+	Aggregations []Aggregation
+	Pivot        string
+}
+
+// GroupByFromParams reads a group-by spec from card parameters
+func GroupByFromParams(params map[string]interface{}) GroupBy {
+	s := GroupBy{
+		Keys:  StringList(params["group_by"]),
+		Pivot: fmt.Sprint(params["pivot"]),
+	}
+	if params["pivot"] == nil || s.Pivot == None {
+		s.Pivot = ""
+	}
+	for _, m := range mapList(params["aggregations"]) {
+		s.Aggregations = append(s.Aggregations, Aggregation{Column: str(m, "column"), Op: str(m, "op")})
+	}
+	return s
+}
+
+// Params converts the spec back to card parameters
+func (s GroupBy) Params() map[string]interface{} {
+	keys := make([]interface{}, len(s.Keys))
+	for i, k := range s.Keys {
+		keys[i] = k
+	}
+	aggs := make([]interface{}, len(s.Aggregations))
+	for i, a := range s.Aggregations {
+		aggs[i] = map[string]interface{}{"column": a.Column, "op": a.Op}
+	}
+	return map[string]interface{}{
+		"group_by":     keys,
+		"aggregations": aggs,
+		"pivot":        s.Pivot,
+	}
+}
+
+// Validate checks the spec against the input columns; nil columns skip the column checks
+func (s GroupBy) Validate(columns []string) error {
+	if len(s.Aggregations) == 0 {
+		return fmt.Errorf("add at least one aggregation")
+	}
+	for _, k := range s.Keys {
+		if err := checkColumn(k, columns); err != nil {
+			return err
+		}
+	}
+	for _, a := range s.Aggregations {
+		if !contains(AggregateOps, a.Op) {
+			return fmt.Errorf("unknown aggregation %q (use %s)", a.Op, strings.Join(AggregateOps, ", "))
+		}
+		if err := checkColumn(a.Column, columns); err != nil {
+			return err
+		}
+	}
+	if s.Pivot != "" {
+		return checkColumn(s.Pivot, columns)
+	}
+	return nil
+}
+
+// Script returns Starlark that reads the table global input and stores the result in output
+func (s GroupBy) Script(input, output string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Group by %s\n", s.describe())
+	b.WriteString(prelude(input))
+	b.WriteString(`
+def _aggregate(op, values):
+    values = [v for v in values if v != None and v != ""]
+    if op == "count":
+        return len(values)
+    if op == "distinct":
+        return len({v: True for v in values})
+    if not values:
+        return None
+    if op == "min":
+        return min(values)
+    if op == "max":
+        return max(values)
+    total = 0
+    for v in values:
+        if type(v) not in ("int", "float"):
+            fail("%s needs numbers, got %s" % (op, repr(v)))
+        total += v
+    if op == "mean":
+        return total / len(values)
+    return total
+`)
+
+	fmt.Fprintf(&b, "\nkeys = [_column(name) for name in %s]\n", quoteList(s.Keys))
+	b.WriteString("aggregations = [\n")
+	for _, a := range s.Aggregations {
+		fmt.Fprintf(&b, "    (_column(%s), %s, %s),\n", strconv.Quote(a.Column), strconv.Quote(a.Op), strconv.Quote(a.Name()))
+	}
+	b.WriteString("]\n")
+	if s.Pivot != "" {
+		fmt.Fprintf(&b, "pivot = _column(%s)\n", strconv.Quote(s.Pivot))
+	} else {
+		b.WriteString("pivot = None\n")
+	}
+
+	fmt.Fprintf(&b, `
+groups = {}
+pivots = {}
+for row in %[1]s["rows"]:
+    key = tuple([row[i] for i in keys])
+    p = row[pivot] if pivot != None else None
+    pivots[p] = True
+    groups.setdefault(key, {}).setdefault(p, []).append(row)
+if pivot == None:
+    pivots = {None: True}
+
+columns = [%[1]s["columns"][i] for i in keys]
+for p in pivots:
+    label = "(blank)" if p == None else str(p)
+    for _, op, name in aggregations:
+        if pivot == None:
+            columns.append(name)
+        elif len(aggregations) == 1:
+            columns.append(label)
+        else:
+            columns.append(label + "_" + name)
+
+rows = []
+for key, by_pivot in groups.items():
+    row = list(key)
+    for p in pivots:
+        group = by_pivot.get(p, [])
+        for col, op, _ in aggregations:
+            row.append(_aggregate(op, [r[col] for r in group]))
+    rows.append(row)
+
+%[2]s = {"columns": columns, "rows": rows}
+`, input, output)
+	return b.String()
+}
+
+// describe summarizes the spec for the script header
+func (s GroupBy) describe() string {
+	keys := "(all rows)"
+	if len(s.Keys) > 0 {
+		keys = strings.Join(s.Keys, ", ")
+	}
+	aggs := make([]string, len(s.Aggregations))
+	for i, a := range s.Aggregations {
+		aggs[i] = fmt.Sprintf("%s(%s)", a.Op, a.Column)
+	}
+	desc := keys + "; " + strings.Join(aggs, ", ")
+	if s.Pivot != "" {
+		desc += "; pivot on " + s.Pivot
+	}
+	return desc
+}
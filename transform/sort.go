@@ -0,0 +1,103 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SortDirections lists the sort orders in form order
+var SortDirections = []string{"ascending", "descending"}
+
+// SortKey orders rows by one column
+type SortKey struct {
+	Column     string
+	Descending bool
+}
+
+// Sort orders rows by several keys; earlier keys take precedence.
+// Empty values always sort last and numbers sort before text.
+type Sort struct {
+	Keys []SortKey
+}
+
+// SortFromParams reads a sort spec from card parameters
+func SortFromParams(params map[string]interface{}) Sort {
+	var s Sort
+	for _, m := range mapList(params["keys"]) {
+		s.Keys = append(s.Keys, SortKey{Column: str(m, "column"), Descending: str(m, "direction") == "descending"})
+	}
+	return s
+}
+
+// Params converts the spec back to card parameters
+func (s Sort) Params() map[string]interface{} {
+	keys := make([]interface{}, len(s.Keys))
+	for i, k := range s.Keys {
+		keys[i] = map[string]interface{}{"column": k.Column, "direction": k.Direction()}
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// Direction returns "ascending" or "descending"
+func (k SortKey) Direction() string {
+	if k.Descending {
+		return "descending"
+	}
+	return "ascending"
+}
+
+// Validate checks the spec against the input columns; nil columns skip the column checks
+func (s Sort) Validate(columns []string) error {
+	for _, k := range s.Keys {
+		if err := checkColumn(k.Column, columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Script returns Starlark that sorts the table global input into output
+func (s Sort) Script(input, output string) string {
+	var b strings.Builder
+	desc := make([]string, len(s.Keys))
+	for i, k := range s.Keys {
+		desc[i] = k.Column + " " + k.Direction()
+	}
+	if len(desc) == 0 {
+		desc = []string{"(keep row order)"}
+	}
+	fmt.Fprintf(&b, "# Sort by %s\n", strings.Join(desc, ", "))
+	b.WriteString(prelude(input))
+	b.WriteString(`
+def _sort_key(v, descending):
+    present = v != None and v != ""
+    if not present:
+        return (not descending, 0, 0)
+    rank = 0 if type(v) in ("int", "float") else 1
+    return (descending, rank, v)
+`)
+
+	b.WriteString("\nkeys = [\n")
+	for _, k := range s.Keys {
+		fmt.Fprintf(&b, "    (_column(%s), %s),\n", strconv.Quote(k.Column), starlarkBool(k.Descending))
+	}
+	b.WriteString("]\n")
+
+	fmt.Fprintf(&b, `
+# sorted is stable, so sorting by the last key first gives a multi-key sort
+rows = list(%[1]s["rows"])
+for col, descending in reversed(keys):
+    rows = sorted(rows, key = lambda row: _sort_key(row[col], descending), reverse = descending)
+
+%[2]s = {"columns": %[1]s["columns"], "rows": rows}
+`, input, output)
+	return b.String()
+}
+
+func starlarkBool(v bool) string {
+	if v {
+		return "True"
+	}
+	return "False"
+}
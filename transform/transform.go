@@ -0,0 +1,96 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Form-configured table operations are stored as structured card parameters and
+// compile to self-contained Starlark, so a card can be ejected into a script card.
+
+// None is the form value for an empty choice
+const None = "(none)"
+
+// ColumnError reports a reference to a column the input table does not have
+type ColumnError struct {
+	Column  string
+	Columns []string
+}
+
+func (e *ColumnError) Error() string {
+	return fmt.Sprintf("column %q is not in the input table (columns: %s)", e.Column, strings.Join(e.Columns, ", "))
+}
+
+// checkColumn returns a ColumnError if name is not one of columns.
+// A nil column list means the schema is not known yet and everything passes.
+func checkColumn(name string, columns []string) error {
+	if columns == nil {
+		return nil
+	}
+	for _, c := range columns {
+		if c == name {
+			return nil
+		}
+	}
+	return &ColumnError{Column: name, Columns: columns}
+}
+
+// HasColumn reports whether name is a known column; unknown schemas accept every name
+func HasColumn(name string, columns []string) bool {
+	return checkColumn(name, columns) == nil
+}
+
+// StringList reads a list parameter of strings
+func StringList(v interface{}) []string {
+	var out []string
+	switch list := v.(type) {
+	case []string:
+		out = append(out, list...)
+	case []interface{}:
+		for _, item := range list {
+			out = append(out, fmt.Sprint(item))
+		}
+	}
+	return out
+}
+
+// mapList reads a list parameter of maps
+func mapList(v interface{}) []map[string]interface{} {
+	var out []map[string]interface{}
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			if m, ok := item.(map[string]interface{}); ok {
+				out = append(out, m)
+			}
+		}
+	}
+	return out
+}
+
+func str(m map[string]interface{}, key string) string {
+	if v, ok := m[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+func quoteList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = strconv.Quote(n)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// prelude checks the input and resolves column names to indexes
+func prelude(input string) string {
+	return fmt.Sprintf(`if %[1]s == None:
+    fail("the %[1]s input is not connected")
+
+def _column(name):
+    if name not in %[1]s["columns"]:
+        fail("column %%s is not in the input table (columns: %%s)" %% (repr(name), ", ".join(%[1]s["columns"])))
+    return %[1]s["columns"].index(name)
+`, input)
+}
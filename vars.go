@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Variables are the named values of a flow, readable from every card. A profile
+// overrides some of them, e.g. to point the same flow at dev or prod folders.
+type Variables struct {
+	Values   map[string]interface{}            `yaml:"values,omitempty"`
+	Profiles map[string]map[string]interface{} `yaml:"profiles,omitempty"`
+	Profile  string                            `yaml:"profile,omitempty"` // active profile, "" for none
+
+	override string // profile chosen for this session only, e.g. on the command line
+}
+
+// runVars are the variables of a run with the active profile applied
+type runVars struct {
+	profile string
+	values  map[string]interface{}
+}
+
+// varReference matches a ${name} reference to a variable in a card parameter
+var varReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ProfileNames returns the profiles of the flow in alphabetical order
+func (v *Variables) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(v.Profiles))
+}
+
+// active returns the profile the flow runs with: the session's override or the saved one
+func (v *Variables) active() string {
+	if v.override != "" {
+		return v.override
+	}
+	return v.Profile
+}
+
+// resolve returns the values of the variables with the active profile applied
+func (v *Variables) resolve() *runVars {
+	values := maps.Clone(v.Values)
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	profile := v.active()
+	maps.Copy(values, v.Profiles[profile])
+	return &runVars{profile: profile, values: values}
+}
+
+// expand replaces the ${name} references in a text with the variable values.
+// References to unknown variables are left as they are.
+func (r *runVars) expand(s string) string {
+	if r == nil {
+		return s
+	}
+	return varReference.ReplaceAllStringFunc(s, func(ref string) string {
+		if v, ok := r.values[ref[2:len(ref)-1]]; ok {
+			return fmt.Sprint(v)
+		}
+		return ref
+	})
+}
+
+// dict returns the variables as the "vars" global of scripts
+func (r *runVars) dict() map[string]interface{} {
+	if r == nil {
+		return map[string]interface{}{}
+	}
+	return maps.Clone(r.values)
+}
+
+// expandValue expands the variables in text, and in the text inside lists and
+// maps such as filter conditions, leaving the original value unchanged
+func (r *runVars) expandValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return r.expand(val)
+	case []interface{}:
+		list := make([]interface{}, len(val))
+		for i, item := range val {
+			list[i] = r.expandValue(item)
+		}
+		return list
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[k] = r.expandValue(item)
+		}
+		return m
+	}
+	return v
+}
+
+// ParamText returns a parameter as ResolvedParams gives it, as text
+func (c *Card) ParamText(name string) string {
+	return fmt.Sprint(c.vars.expandValue(c.Param(name)))
+}
+
+// SetProfile switches the flow to a profile, or to the plain values for "".
+// The choice is saved with the flow.
+func (g *Game) SetProfile(name string) error {
+	v := g.rootVariables()
+	if err := v.checkProfile(name); err != nil {
+		return err
+	}
+	v.Profile, v.override = name, ""
+	return nil
+}
+
+// OverrideProfile runs the flow with a profile for this session without
+// changing the profile saved with it, as the -profile flag does
+func (g *Game) OverrideProfile(name string) error {
+	v := g.rootVariables()
+	if err := v.checkProfile(name); err != nil {
+		return err
+	}
+	v.override = name
+	return nil
+}
+
+func (v *Variables) checkProfile(name string) error {
+	if _, ok := v.Profiles[name]; name != "" && !ok {
+		return fmt.Errorf("unknown profile %q, the flow has %v", name, v.ProfileNames())
+	}
+	return nil
+}
+
+// rootVariables returns the variables of the top-level flow, also while a
+// composite is open
+func (g *Game) rootVariables() *Variables {
+	if g.variables == nil {
+		g.variables = &Variables{}
+	}
+	return g.variables
+}
+
+// updateProfiles switches to the next profile with F8 and reruns the flow
+func (g *Game) updateProfiles() {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyF8) || g.input.EditingCard != nil {
+		return
+	}
+	v := g.rootVariables()
+	names := append([]string{""}, v.ProfileNames()...)
+	next := names[(slices.Index(names, v.active())+1)%len(names)]
+	if err := g.SetProfile(next); err == nil {
+		g.RunEngine()
+	}
+}
+
+// profileLabel describes the active profile for the status text
+func (g *Game) profileLabel() string {
+	v := g.rootVariables()
+	if len(v.Profiles) == 0 {
+		return "none"
+	}
+	if v.active() == "" {
+		return "default"
+	}
+	return v.active()
+}
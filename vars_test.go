@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"card-flows/transform"
+)
+
+func TestFlowVariablesAndProfiles(t *testing.T) {
+	dir := t.TempDir()
+	g, ec := newExportFlow("${out}/sales.csv", "csv", "overwrite")
+	g.variables = &Variables{
+		Values: map[string]interface{}{"out": filepath.Join(dir, "dev"), "greeting": "hello"},
+		Profiles: map[string]map[string]interface{}{
+			"prod": {"out": filepath.Join(dir, "prod")},
+		},
+	}
+	for _, name := range []string{"dev", "prod"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := &Card{
+		ID:      NewID(),
+		Type:    "script",
+		Color:   ColorCardDefault,
+		Title:   "Script",
+		Text:    `result = vars["greeting"] + " from " + vars["out"]`,
+		Outputs: []Port{{Name: "result", Type: "any"}},
+	}
+	g.cards = append(g.cards, script)
+
+	g.engine.Run()
+	if ec.LastError != "" {
+		t.Fatal(ec.LastError)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dev", "sales.csv")); err != nil {
+		t.Errorf("Expected the path variable to be expanded: %v", err)
+	}
+
+	// Switching profile overrides the value and reruns the cached cards
+	if err := g.SetProfile("prod"); err != nil {
+		t.Fatal(err)
+	}
+	g.engine.Run()
+	if _, err := os.Stat(filepath.Join(dir, "prod", "sales.csv")); err != nil {
+		t.Errorf("Expected the prod profile to write to its folder: %v", err)
+	}
+	if got, want := g.engine.Memory[script.ID+":result"], "hello from "+filepath.Join(dir, "prod"); got != want {
+		t.Errorf("Expected scripts to read the profile's values, got %v (error %q)", got, script.LastError)
+	}
+	if err := g.SetProfile("staging"); err == nil {
+		t.Error("Expected an unknown profile to be refused")
+	}
+
+	path := filepath.Join(dir, "state.yaml")
+	if err := SaveState(g, path); err != nil {
+		t.Fatal(err)
+	}
+	g2 := NewGame()
+	if err := LoadState(g2, path); err != nil {
+		t.Fatal(err)
+	}
+	if v := g2.variables; v.Profile != "prod" || v.Values["greeting"] != "hello" || len(v.ProfileNames()) != 1 {
+		t.Errorf("Expected the variables to be saved with the flow, got %+v", v)
+	}
+}
+
+func TestExpandVariables(t *testing.T) {
+	v := (&Variables{Values: map[string]interface{}{"n": 3, "dir": "data"}}).resolve()
+	if got := v.expand("${dir}/part_${n}.csv ${missing}"); got != "data/part_3.csv ${missing}" {
+		t.Errorf("Unexpected expansion %q", got)
+	}
+	var none *runVars
+	if got := none.expand("${dir}"); got != "${dir}" {
+		t.Errorf("Expected no expansion without variables, got %q", got)
+	}
+}
+
+func TestVariablesInListParams(t *testing.T) {
+	g, fc := newTableFlow(func(g *Game) *Card { return g.AddFilterCard(100, 400) })
+	g.variables = &Variables{Values: map[string]interface{}{"rep": "bob"}}
+	fc.setParams(transform.Filter{Conditions: []transform.Condition{{Column: "rep", Op: "=", Value: "${rep}"}}}.Params())
+	g.engine.Run()
+	if got := resultColumn(t, g, fc, "rep"); !reflect.DeepEqual(got, []interface{}{"bob", "bob"}) {
+		t.Errorf("Expected the condition value to be expanded, got %v", got)
+	}
+
+	// The form edits the reference, not the value of this run
+	if spec := transform.FilterFromParams(fc.paramsWithDefaults()); spec.Conditions[0].Value != "${rep}" {
+		t.Errorf("Expected the form to keep the reference, got %q", spec.Conditions[0].Value)
+	}
+}
+
+func TestOverrideProfileIsNotSaved(t *testing.T) {
+	g := NewGame()
+	g.variables = &Variables{
+		Values:   map[string]interface{}{"env": "dev"},
+		Profiles: map[string]map[string]interface{}{"prod": {"env": "prod"}},
+	}
+	if err := g.OverrideProfile("prod"); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.variables.resolve().values["env"]; got != "prod" || g.profileLabel() != "prod" {
+		t.Errorf("Expected the run to use the prod profile, got %v", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	if err := SaveState(g, path); err != nil {
+		t.Fatal(err)
+	}
+	g2 := NewGame()
+	if err := LoadState(g2, path); err != nil {
+		t.Fatal(err)
+	}
+	if g2.variables.Profile != "" || g2.variables.resolve().values["env"] != "dev" {
+		t.Errorf("Expected the override to stay out of the saved flow, got %+v", g2.variables)
+	}
+
+	// Choosing a profile on the canvas replaces the override
+	if err := g.SetProfile(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.variables.resolve().values["env"]; got != "dev" {
+		t.Errorf("Expected the plain values after switching, got %v", got)
+	}
+}
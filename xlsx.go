@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"card-flows/engine"
+	"card-flows/xlsx"
+)
+
+// XLSXExportModes lists the write modes of the xlsx export card; workbooks are not appended to
+var XLSXExportModes = []string{"overwrite", "timestamped"}
+
+// firstSheet is the sheet choice shown when the import reads the first sheet
+const firstSheet = "(first)"
+
+func (g *Game) AddXLSXImportCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "xlsx_import",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth * 1.5,
+		Height: DefaultCardHeight * 1.5,
+		Color:  ColorCardDefault,
+		Title:  "Excel:import",
+		Outputs: []Port{
+			{Name: "table", Type: "table"},
+			{Name: "sheets", Type: "map"},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+func (g *Game) AddXLSXExportCard(x, y float64) *Card {
+	card := &Card{
+		ID:     NewID(),
+		Type:   "xlsx_export",
+		X:      math.Round(x/SnapGridLarge) * SnapGridLarge,
+		Y:      math.Round(y/SnapGridLarge) * SnapGridLarge,
+		Width:  DefaultCardWidth * 1.5,
+		Height: DefaultCardHeight * 1.5,
+		Color:  ColorCardDefault,
+		Title:  "Excel:export",
+		Inputs: []Port{
+			{Name: "data", Type: "any"},
+		},
+		Params: map[string]interface{}{},
+	}
+	g.cards = append(g.cards, card)
+	return card
+}
+
+// executeXLSXImport reads the workbook. The table output is the chosen sheet (the first
+// when none is chosen) and the sheets output maps every sheet name to its table.
+func executeXLSXImport(c *Card) (map[string]interface{}, error) {
+	path := c.ParamText("path")
+	sheets, err := xlsx.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("%s has no sheets", filepath.Base(path))
+	}
+
+	header := c.ParamText("header") != "no"
+	want := c.ParamText("sheet")
+	c.sheetNames = nil
+	all := map[string]interface{}{}
+	var table *engine.Table
+	for _, s := range sheets {
+		c.sheetNames = append(c.sheetNames, s.Name)
+		t := sheetTable(s.Rows, header)
+		all[s.Name] = t
+		if s.Name == want || (table == nil && want == "") {
+			table = t
+		}
+	}
+	if table == nil {
+		return nil, fmt.Errorf("sheet %q is not in %s (sheets: %s)", want, filepath.Base(path), strings.Join(c.sheetNames, ", "))
+	}
+	return map[string]interface{}{"table": table, "sheets": all}, nil
+}
+
+// sheetTable converts sheet rows to a table. With a header the first row names the
+// columns like a grid card's header; without one columns are named A, B, C...
+func sheetTable(rows [][]interface{}, header bool) *engine.Table {
+	cols := 0
+	for _, row := range rows {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+	var names []string
+	if header && len(rows) > 0 {
+		for _, v := range rows[0] {
+			names = append(names, previewValue(v))
+		}
+		rows = rows[1:]
+	}
+	t := engine.NewTable(columnNames(names, cols)...)
+	for _, row := range rows {
+		t.AppendRow(row...)
+	}
+	return t
+}
+
+// executeXLSXExport writes its input to a workbook: a table becomes one sheet, a map
+// of tables one sheet per key and a list of tables one sheet per item
+func (e *Engine) executeXLSXExport(c *Card, inputs map[string]interface{}) (map[string]interface{}, error) {
+	v, ok := inputs["data"]
+	if !ok || v == nil {
+		return nil, fmt.Errorf("the data input is not connected")
+	}
+	sheets, err := workbookSheets(v, c.ParamText("sheet"))
+	if err != nil {
+		return nil, err
+	}
+
+	path := c.ParamText("path")
+	if path == "" {
+		return nil, fmt.Errorf("set a file path to export to")
+	}
+	if c.ParamText("mode") == "timestamped" {
+		path = engine.TimestampedPath(path, time.Now())
+	}
+	var buf bytes.Buffer
+	if err := xlsx.Write(&buf, sheets); err != nil {
+		return nil, err
+	}
+	if err := engine.WriteFileAtomic(path, buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	rows := 0
+	for _, s := range sheets {
+		rows += len(s.Rows) - 1
+	}
+	c.status = fmt.Sprintf("Wrote %d sheets to %s", len(sheets), filepath.Base(path))
+	e.logRun(c, fmt.Sprintf("Wrote %d rows in %d sheets to %s", rows, len(sheets), path), map[string]interface{}{
+		"path":   path,
+		"rows":   rows,
+		"sheets": len(sheets),
+	})
+	return map[string]interface{}{}, nil
+}
+
+// workbookSheets converts the export input to sheets with a header row
+func workbookSheets(v interface{}, name string) ([]xlsx.Sheet, error) {
+	switch val := v.(type) {
+	case *engine.Table:
+		return []xlsx.Sheet{tableSheet(name, val)}, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var sheets []xlsx.Sheet
+		for _, k := range keys {
+			t, ok := val[k].(*engine.Table)
+			if !ok {
+				return nil, fmt.Errorf("%q must be a table, got %T", k, val[k])
+			}
+			sheets = append(sheets, tableSheet(k, t))
+		}
+		return sheets, nil
+	case []interface{}:
+		var sheets []xlsx.Sheet
+		for i, item := range val {
+			t, ok := item.(*engine.Table)
+			if !ok {
+				return nil, fmt.Errorf("item %d must be a table, got %T", i, item)
+			}
+			sheets = append(sheets, tableSheet(fmt.Sprintf("%s %d", name, i+1), t))
+		}
+		return sheets, nil
+	}
+	return nil, fmt.Errorf("the data input must be a table, a map of tables or a list of tables, got %T", v)
+}
+
+func tableSheet(name string, t *engine.Table) xlsx.Sheet {
+	header := make([]interface{}, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = c
+	}
+	rows := [][]interface{}{header}
+	for r := 0; r < t.NumRows(); r++ {
+		rows = append(rows, t.Row(r))
+	}
+	return xlsx.Sheet{Name: name, Rows: rows}
+}
+
+// fileStamp identifies a version of a file by its size and modification time
+func fileStamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+}
+
+func (c *Card) xlsxImportFields(g *Game) []formField {
+	sheet := fmt.Sprint(c.Param("sheet"))
+	if sheet == "" {
+		sheet = firstSheet
+	}
+	set := func(name string) func(string) {
+		return func(v string) { c.setParams(map[string]interface{}{name: v}) }
+	}
+	return []formField{
+		{Label: "Path", Value: fmt.Sprint(c.Param("path")), Edit: true, Set: set("path")},
+		{
+			Label:   "Sheet",
+			Value:   sheet,
+			Options: append([]string{firstSheet}, c.sheetNames...),
+			Set: func(v string) {
+				if v == firstSheet {
+					v = ""
+				}
+				c.setParams(map[string]interface{}{"sheet": v})
+			},
+			Invalid: sheet != firstSheet && c.sheetNames != nil && !slices.Contains(c.sheetNames, sheet),
+		},
+		{Label: "Header row", Value: fmt.Sprint(c.Param("header")), Options: []string{"yes", "no"}, Set: set("header")},
+	}
+}
+
+func (c *Card) xlsxExportFields(g *Game) []formField {
+	set := func(name string) func(string) {
+		return func(v string) { c.setParams(map[string]interface{}{name: v}) }
+	}
+	return []formField{
+		{Label: "Path", Value: fmt.Sprint(c.Param("path")), Edit: true, Set: set("path")},
+		{Label: "Sheet", Value: fmt.Sprint(c.Param("sheet")), Edit: true, Set: set("sheet")},
+		{Label: "Mode", Value: fmt.Sprint(c.Param("mode")), Options: XLSXExportModes, Set: set("mode")},
+		{Label: "Write now", Action: "export_now"},
+	}
+}
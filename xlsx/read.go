@@ -0,0 +1,372 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sheet is one worksheet: its name and rows of cell values.
+// Values are string, int, float64, bool or nil for empty cells.
+type Sheet struct {
+	Name string
+	Rows [][]interface{}
+}
+
+// ReadFile reads every worksheet of an .xlsx workbook
+func ReadFile(filename string) ([]Sheet, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Read(f, info.Size())
+}
+
+// Read reads every worksheet of an .xlsx workbook in workbook order.
+// Dates are returned as text, e.g. "2024-01-31" or "2024-01-31 15:04:05".
+func Read(r io.ReaderAt, size int64) ([]Sheet, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not an xlsx workbook: %v", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	// The package relationships point at the workbook part
+	workbookPath := "xl/workbook.xml"
+	var rootRels relationships
+	if err := decodePart(files, "_rels/.rels", &rootRels); err == nil {
+		for _, rel := range rootRels.Items {
+			if strings.HasSuffix(rel.Type, "/officeDocument") {
+				workbookPath = resolvePart("", rel.Target)
+			}
+		}
+	}
+
+	var wb workbook
+	if err := decodePart(files, workbookPath, &wb); err != nil {
+		return nil, err
+	}
+	dir := path.Dir(workbookPath)
+	var wbRels relationships
+	if err := decodePart(files, path.Join(dir, "_rels", path.Base(workbookPath)+".rels"), &wbRels); err != nil {
+		return nil, err
+	}
+	targets := map[string]string{}
+	for _, rel := range wbRels.Items {
+		targets[rel.ID] = resolvePart(dir, rel.Target)
+	}
+
+	var strs []string
+	var dates map[int]bool
+	for _, rel := range wbRels.Items {
+		switch {
+		case strings.HasSuffix(rel.Type, "/sharedStrings"):
+			var sst sharedStrings
+			if err := decodePart(files, targets[rel.ID], &sst); err != nil {
+				return nil, err
+			}
+			for _, si := range sst.Items {
+				strs = append(strs, si.text())
+			}
+		case strings.HasSuffix(rel.Type, "/styles"):
+			var st styles
+			if err := decodePart(files, targets[rel.ID], &st); err != nil {
+				return nil, err
+			}
+			dates = st.dateStyles()
+		}
+	}
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if wb.Properties.Date1904 == "1" || wb.Properties.Date1904 == "true" {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	var sheets []Sheet
+	for _, s := range wb.Sheets {
+		target, ok := targets[s.relID()]
+		if !ok {
+			return nil, fmt.Errorf("sheet %q has no worksheet part", s.Name)
+		}
+		var ws worksheet
+		if err := decodePart(files, target, &ws); err != nil {
+			return nil, err
+		}
+		rows, err := ws.values(strs, dates, epoch)
+		if err != nil {
+			return nil, fmt.Errorf("sheet %q: %v", s.Name, err)
+		}
+		sheets = append(sheets, Sheet{Name: s.Name, Rows: rows})
+	}
+	return sheets, nil
+}
+
+func decodePart(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("workbook part %s is missing", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("reading %s: %v", name, err)
+	}
+	return nil
+}
+
+// resolvePart turns a relationship target into a zip path; absolute targets start at the package root
+func resolvePart(dir, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(target, "/")
+	}
+	return path.Clean(path.Join(dir, target))
+}
+
+type relationships struct {
+	Items []struct {
+		ID     string `xml:"Id,attr"`
+		Type   string `xml:"Type,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type workbook struct {
+	Properties struct {
+		Date1904 string `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []sheetEntry `xml:"sheets>sheet"`
+}
+
+type sheetEntry struct {
+	Name  string     `xml:"name,attr"`
+	Attrs []xml.Attr `xml:",any,attr"`
+}
+
+// relID returns the r:id attribute, whichever relationships namespace the file uses
+func (s sheetEntry) relID() string {
+	for _, a := range s.Attrs {
+		if a.Name.Local == "id" && a.Name.Space != "" {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+type sharedStrings struct {
+	Items []richText `xml:"si"`
+}
+
+// richText is a string that is either plain or split into formatted runs
+type richText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (r richText) text() string {
+	if len(r.Runs) == 0 {
+		return r.T
+	}
+	var b strings.Builder
+	for _, run := range r.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+type styles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+// dateStyles returns the cell style indexes whose number format shows a date or time
+func (s styles) dateStyles() map[int]bool {
+	custom := map[int]string{}
+	for _, f := range s.NumFmts {
+		custom[f.ID] = f.Code
+	}
+	dates := map[int]bool{}
+	for i, xf := range s.CellXfs {
+		if code, ok := custom[xf.NumFmtID]; ok {
+			dates[i] = isDateFormat(code)
+		} else {
+			dates[i] = isBuiltinDateFormat(xf.NumFmtID)
+		}
+	}
+	return dates
+}
+
+func isBuiltinDateFormat(id int) bool {
+	return (id >= 14 && id <= 22) || (id >= 27 && id <= 36) || (id >= 45 && id <= 47) || (id >= 50 && id <= 58)
+}
+
+// isDateFormat reports whether a custom number format has date or time parts,
+// ignoring quoted text, escaped characters and [color] or [$-locale] sections
+func isDateFormat(code string) bool {
+	inQuote, inBracket := false, false
+	for i := 0; i < len(code); i++ {
+		ch := code[i]
+		switch {
+		case inQuote:
+			inQuote = ch != '"'
+		case inBracket:
+			inBracket = ch != ']'
+		case ch == '"':
+			inQuote = true
+		case ch == '[':
+			inBracket = true
+		case ch == '\\' || ch == '_' || ch == '*':
+			i++ // the next character is literal or padding
+		case strings.ContainsRune("dmyhsDMYHS", rune(ch)):
+			return true
+		}
+	}
+	return false
+}
+
+type worksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R      string   `xml:"r,attr"`
+			T      string   `xml:"t,attr"`
+			S      int      `xml:"s,attr"`
+			V      *string  `xml:"v"`
+			Inline richText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// values converts the sheet's cells to rows, placing each cell by its reference
+func (ws worksheet) values(strs []string, dates map[int]bool, epoch time.Time) ([][]interface{}, error) {
+	var rows [][]interface{}
+	next := 0
+	for _, row := range ws.Rows {
+		r := next
+		if row.R > 0 {
+			r = row.R - 1
+		}
+		next = r + 1
+		col := 0
+		for _, c := range row.Cells {
+			if c.R != "" {
+				cr, cc, err := ParseRef(c.R)
+				if err != nil {
+					return nil, err
+				}
+				r, col = cr, cc
+			}
+			v, err := cellValue(c.T, c.V, c.Inline, c.S, strs, dates, epoch)
+			if err != nil {
+				return nil, fmt.Errorf("cell %s: %v", CellRef(r, col), err)
+			}
+			if v != nil {
+				for len(rows) <= r {
+					rows = append(rows, nil)
+				}
+				for len(rows[r]) <= col {
+					rows[r] = append(rows[r], nil)
+				}
+				rows[r][col] = v
+			}
+			col++
+		}
+	}
+	return rows, nil
+}
+
+func cellValue(t string, v *string, inline richText, style int, strs []string, dates map[int]bool, epoch time.Time) (interface{}, error) {
+	if t == "inlineStr" {
+		return inline.text(), nil
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch t {
+	case "s":
+		i, err := strconv.Atoi(*v)
+		if err != nil || i < 0 || i >= len(strs) {
+			return nil, fmt.Errorf("bad shared string index %q", *v)
+		}
+		return strs[i], nil
+	case "str", "e", "d":
+		return *v, nil
+	case "b":
+		return *v == "1" || *v == "true", nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(*v), 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad number %q", *v)
+	}
+	if dates[style] {
+		return formatDate(f, epoch), nil
+	}
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int(f), nil
+	}
+	return f, nil
+}
+
+// formatDate converts an Excel serial date to text
+func formatDate(serial float64, epoch time.Time) string {
+	t := epoch.Add(time.Duration(math.Round(serial*86400)) * time.Second)
+	switch {
+	case serial < 1:
+		return t.Format("15:04:05")
+	case serial == math.Trunc(serial):
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// ParseRef converts a cell reference such as "B3" to zero-based row and column indexes
+func ParseRef(ref string) (row, col int, err error) {
+	i := 0
+	for i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z' {
+		col = col*26 + int(ref[i]-'A') + 1
+		i++
+	}
+	row, err = strconv.Atoi(ref[i:])
+	if i == 0 || err != nil || row < 1 {
+		return 0, 0, fmt.Errorf("bad cell reference %q", ref)
+	}
+	return row - 1, col - 1, nil
+}
+
+// CellRef returns the reference of a zero-based cell, e.g. (2, 1) -> "B3"
+func CellRef(row, col int) string {
+	return ColumnName(col) + strconv.Itoa(row+1)
+}
+
+// ColumnName returns the letters of a zero-based column index (0 -> A, 26 -> AA)
+func ColumnName(col int) string {
+	s := ""
+	for col >= 0 {
+		s = string(rune('A'+col%26)) + s
+		col = col/26 - 1
+	}
+	return s
+}
@@ -0,0 +1,169 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxSheetNameLength is the longest sheet name Excel accepts
+const MaxSheetNameLength = 31
+
+// Write writes the sheets as an .xlsx workbook. Sheet names are made valid and
+// unique. Strings are stored inline so no shared string table is needed.
+func Write(w io.Writer, sheets []Sheet) error {
+	if len(sheets) == 0 {
+		return fmt.Errorf("a workbook needs at least one sheet")
+	}
+	zw := zip.NewWriter(w)
+
+	names := SheetNames(sheets)
+	var wbSheets, wbRels, types strings.Builder
+	for i, name := range names {
+		n := i + 1
+		fmt.Fprintf(&wbSheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(name), n, n)
+		fmt.Fprintf(&wbRels, `<Relationship Id="rId%d" Type="%s/worksheet" Target="worksheets/sheet%d.xml"/>`, n, relNS, n)
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+	}
+	stylesID := len(names) + 1
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			types.String() + `</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="` + relNS + `/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="` + mainNS + `" xmlns:r="` + relNS + `"><sheets>` + wbSheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + wbRels.String() +
+			fmt.Sprintf(`<Relationship Id="rId%d" Type="%s/styles" Target="styles.xml"/>`, stylesID, relNS) + `</Relationships>`},
+		{"xl/styles.xml", `<styleSheet xmlns="` + mainNS + `">` +
+			`<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/></cellXfs>` +
+			`</styleSheet>`},
+	}
+	for _, p := range parts {
+		if err := writePart(zw, p.name, []byte(p.body)); err != nil {
+			return err
+		}
+	}
+	for i, s := range sheets {
+		if err := writePart(zw, fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), worksheetXML(s.Rows)); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+const (
+	mainNS = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	relNS  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships" // also the prefix of relationship types
+)
+
+func writePart(zw *zip.Writer, name string, body []byte) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, xml.Header); err != nil {
+		return err
+	}
+	_, err = f.Write(body)
+	return err
+}
+
+func worksheetXML(rows [][]interface{}) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<worksheet xmlns="` + mainNS + `"><sheetData>`)
+	for r, row := range rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, v := range row {
+			writeCell(&b, CellRef(r, c), v)
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.Bytes()
+}
+
+func writeCell(b *bytes.Buffer, ref string, v interface{}) {
+	switch val := v.(type) {
+	case nil:
+		return
+	case bool:
+		n := 0
+		if val {
+			n = 1
+		}
+		fmt.Fprintf(b, `<c r="%s" t="b"><v>%d</v></c>`, ref, n)
+		return
+	case int:
+		fmt.Fprintf(b, `<c r="%s"><v>%d</v></c>`, ref, val)
+		return
+	case int64:
+		fmt.Fprintf(b, `<c r="%s"><v>%d</v></c>`, ref, val)
+		return
+	case float64:
+		if !math.IsNaN(val) && !math.IsInf(val, 0) {
+			fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(val, 'g', -1, 64))
+			return
+		}
+	}
+	s := fmt.Sprint(v)
+	space := ""
+	if strings.TrimSpace(s) != s {
+		space = ` xml:space="preserve"`
+	}
+	fmt.Fprintf(b, `<c r="%s" t="inlineStr"><is><t%s>%s</t></is></c>`, ref, space, escape(s))
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// SheetNames returns valid, unique sheet names for the sheets. Characters Excel
+// forbids become "_", long names are cut to 31 characters and empty names become Sheet1...
+func SheetNames(sheets []Sheet) []string {
+	names := make([]string, len(sheets))
+	seen := map[string]bool{}
+	for i, s := range sheets {
+		name := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`[]:*?/\`, r) {
+				return '_'
+			}
+			return r
+		}, strings.TrimSpace(s.Name))
+		name = strings.Trim(name, "'")
+		if name == "" {
+			name = "Sheet" + strconv.Itoa(i+1)
+		}
+		name = truncate(name, MaxSheetNameLength)
+		for base, n := name, 2; seen[strings.ToLower(name)]; n++ {
+			suffix := " (" + strconv.Itoa(n) + ")"
+			name = truncate(base, MaxSheetNameLength-len(suffix)) + suffix
+		}
+		seen[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+package xlsx
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// handwritten.xlsx was put together by hand rather than saved by Excel. It has
+// shared and inline strings, number styles, dates and a sheet not starting at A1.
+func TestReadWorkbook(t *testing.T) {
+	sheets, err := ReadFile("testdata/handwritten.xlsx")
+	if err != nil {
+		t.Fatalf("Failed to read workbook: %v", err)
+	}
+	if len(sheets) != 2 || sheets[0].Name != "Sales" || sheets[1].Name != "Notes & more" {
+		t.Fatalf("Unexpected sheets %+v", sheets)
+	}
+
+	want := [][]interface{}{
+		{"region", "quarter", "total", "date", "paid"},
+		{"north", "Q1", 10, "2024-01-31", true},
+		{"south", "Q1", 2.5, "2024-01-31 15:00:00", false},
+		nil, // row 4 is missing from the file
+		{"north", "Q2", 12.5, nil, "#DIV/0!"},
+		{"inline", nil, 7, "12:00:00"},
+	}
+	if got := sheets[0].Rows; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected Sales rows\n got: %#v\nwant: %#v", got, want)
+	}
+
+	// Cells keep their position when the sheet does not start at A1
+	want = [][]interface{}{nil, {nil, "rich text", " padded "}, {nil, nil, "x"}}
+	if got := sheets[1].Rows; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected Notes rows\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestRead1904Dates(t *testing.T) {
+	sheets, err := ReadFile("testdata/mac1904.xlsx")
+	if err != nil {
+		t.Fatalf("Failed to read workbook: %v", err)
+	}
+	if got := sheets[0].Rows[1][0]; got != "2024-01-31" {
+		t.Errorf("Expected the 1904 date system to be used, got %v", got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, fixture := range []string{"testdata/handwritten.xlsx", "testdata/mac1904.xlsx"} {
+		t.Run(fixture, func(t *testing.T) {
+			sheets, err := ReadFile(fixture)
+			if err != nil {
+				t.Fatalf("Failed to read workbook: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := Write(&buf, sheets); err != nil {
+				t.Fatalf("Failed to write workbook: %v", err)
+			}
+			again, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("Failed to read written workbook: %v", err)
+			}
+			if !reflect.DeepEqual(again, sheets) {
+				t.Errorf("Round trip changed the workbook\n got: %#v\nwant: %#v", again, sheets)
+			}
+		})
+	}
+}
+
+func TestWriteValues(t *testing.T) {
+	in := []Sheet{{Name: "Data", Rows: [][]interface{}{
+		{"text", "<tags> & \"quotes\"", "line\nbreak", "  spaced"},
+		{1, -2.25, 1e20, true},
+		{nil, "", int64(7), 0.1},
+	}}}
+	var buf bytes.Buffer
+	if err := Write(&buf, in); err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+	out, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read written workbook: %v", err)
+	}
+	want := [][]interface{}{
+		{"text", "<tags> & \"quotes\"", "line\nbreak", "  spaced"},
+		{1, -2.25, 1e20, true},
+		{nil, "", 7, 0.1},
+	}
+	if !reflect.DeepEqual(out[0].Rows, want) {
+		t.Errorf("Unexpected values\n got: %#v\nwant: %#v", out[0].Rows, want)
+	}
+}
+
+func TestSheetNames(t *testing.T) {
+	sheets := []Sheet{
+		{Name: "Sales"},
+		{Name: "sales"},
+		{Name: "a/b:c?"},
+		{Name: ""},
+		{Name: "A very long sheet name that Excel would reject"},
+	}
+	want := []string{"Sales", "sales (2)", "a_b_c_", "Sheet4", "A very long sheet name that Exc"}
+	if got := SheetNames(sheets); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	for _, ref := range []string{"A1", "Z9", "AA10", "XFD1048576"} {
+		row, col, err := ParseRef(ref)
+		if err != nil || CellRef(row, col) != ref {
+			t.Errorf("Expected %s to round trip, got %d, %d, %v", ref, row, col, err)
+		}
+	}
+	if _, _, err := ParseRef("12"); err == nil {
+		t.Errorf("Expected an error for a reference without a column")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"card-flows/engine"
+	"card-flows/xlsx"
+)
+
+func TestXLSXImportCard(t *testing.T) {
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	ic := g.AddXLSXImportCard(100, 100)
+	ic.setParams(map[string]interface{}{"path": "xlsx/testdata/handwritten.xlsx"})
+	g.engine.Run()
+	if ic.LastError != "" {
+		t.Fatalf("Unexpected error: %s", ic.LastError)
+	}
+
+	table := g.engine.Memory[ic.ID+":table"].(*engine.Table)
+	if want := []string{"region", "quarter", "total", "date", "paid"}; !reflect.DeepEqual(table.Columns, want) {
+		t.Errorf("Expected columns %v, got %v", want, table.Columns)
+	}
+	if table.NumRows() != 5 || table.Value(0, 2) != 10 || table.Value(1, 3) != "2024-01-31 15:00:00" {
+		t.Errorf("Unexpected rows %v", table.Data)
+	}
+	if sheets := g.engine.Memory[ic.ID+":sheets"].(map[string]interface{}); len(sheets) != 2 {
+		t.Errorf("Expected both sheets, got %v", sheets)
+	}
+
+	// The second sheet has a blank header cell, which gets its column letter
+	ic.setParams(map[string]interface{}{"sheet": "Notes & more", "header": "no"})
+	g.engine.Run()
+	table = g.engine.Memory[ic.ID+":table"].(*engine.Table)
+	if want := []string{"A", "B", "C"}; !reflect.DeepEqual(table.Columns, want) || table.NumRows() != 3 {
+		t.Errorf("Expected columns %v and 3 rows, got %v", want, table)
+	}
+
+	ic.setParams(map[string]interface{}{"sheet": "Missing"})
+	g.engine.Run()
+	if !strings.Contains(ic.LastError, `sheet "Missing" is not in handwritten.xlsx (sheets: Sales, Notes & more)`) {
+		t.Errorf("Expected a missing sheet error, got %q", ic.LastError)
+	}
+	if f := ic.xlsxImportFields(g)[1]; !f.Invalid || len(f.Options) != 3 {
+		t.Errorf("Expected the sheet field to be flagged, got %+v", f)
+	}
+}
+
+func TestXLSXImportRereadsChangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.xlsx")
+	write := func(v interface{}) {
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := xlsx.Write(f, []xlsx.Sheet{{Name: "S", Rows: [][]interface{}{{"n"}, {v}}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g := NewGame()
+	g.cards = []*Card{}
+	g.arrows = []*Arrow{}
+	ic := g.AddXLSXImportCard(100, 100)
+	ic.setParams(map[string]interface{}{"path": path})
+
+	write(1)
+	g.engine.Run()
+	write(22)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+	g.engine.Run()
+	if got := g.engine.Memory[ic.ID+":table"].(*engine.Table).Value(0, 0); got != 22 {
+		t.Errorf("Expected the changed file to be read again, got %v", got)
+	}
+}
+
+func TestXLSXExportCard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.xlsx")
+	g, sc := newTableFlow(func(g *Game) *Card { return g.AddSortCard(100, 400) })
+	ec := g.AddXLSXExportCard(100, 700)
+	ec.setParams(map[string]interface{}{"path": path, "sheet": "Sales"})
+	wireCards(g, sc, "result", ec, "data")
+	g.engine.Run()
+	if ec.LastError != "" {
+		t.Fatalf("Unexpected error: %s", ec.LastError)
+	}
+
+	sheets, err := xlsx.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read the export: %v", err)
+	}
+	if len(sheets) != 1 || sheets[0].Name != "Sales" || len(sheets[0].Rows) != 6 {
+		t.Fatalf("Unexpected workbook %+v", sheets)
+	}
+	if want := []interface{}{"south", "Q2", nil, "bob"}; !reflect.DeepEqual(sheets[0].Rows[5], want) {
+		t.Errorf("Expected the last row %v, got %v", want, sheets[0].Rows[5])
+	}
+	entry := g.engine.RunLog[len(g.engine.RunLog)-1]
+	if entry.Data["path"] != path || entry.Data["rows"] != 5 {
+		t.Errorf("Unexpected run log entry %+v", entry)
+	}
+
+	// A map of tables, e.g. every sheet of an import, becomes one sheet per key
+	got, err := workbookSheets(map[string]interface{}{"b": engine.NewTable("x"), "a": engine.NewTable("y")}, "")
+	if err != nil || len(got) != 2 || got[0].Name != "a" {
+		t.Errorf("Expected sheets a and b, got %+v, %v", got, err)
+	}
+	if _, err := workbookSheets("text", ""); err == nil {
+		t.Errorf("Expected an error for a non-table input")
+	}
+}